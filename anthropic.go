@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 
 	openai "github.com/sashabaranov/go-openai"
 )
@@ -55,15 +56,128 @@ func DefaultAnthropicConfig(authToken string) AnthropicClientConfig {
 
 // AnthropicMessageCompletionRequest represents the request body for the chat completion API.
 type AnthropicMessageCompletionRequest struct {
-	Model         string                         `json:"model"`
-	System        string                         `json:"system"`
-	Messages      []openai.ChatCompletionMessage `json:"messages"`
-	MaxTokens     int                            `json:"max_tokens"`
-	Temperature   float32                        `json:"temperature,omitempty"`
-	TopP          float32                        `json:"top_p,omitempty"`
-	TopK          int                            `json:"top_k,omitempty"`
-	Stream        bool                           `json:"stream,omitempty"`
-	StopSequences []string                       `json:"stop_sequences,omitempty"`
+	Model         string                  `json:"model"`
+	System        []AnthropicInputContent `json:"system,omitempty"`
+	Messages      []AnthropicInputMessage `json:"messages"`
+	MaxTokens     int                     `json:"max_tokens"`
+	Temperature   float32                 `json:"temperature,omitempty"`
+	TopP          float32                 `json:"top_p,omitempty"`
+	TopK          int                     `json:"top_k,omitempty"`
+	Stream        bool                    `json:"stream,omitempty"`
+	StopSequences []string                `json:"stop_sequences,omitempty"`
+	Thinking      *AnthropicThinking      `json:"thinking,omitempty"`
+}
+
+// AnthropicThinking turns on extended thinking, with a budget of tokens the
+// model may use to think before answering.
+type AnthropicThinking struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens"`
+}
+
+// AnthropicInputMessage represents a message sent to the Anthropic API.
+type AnthropicInputMessage struct {
+	Role    string                  `json:"role"`
+	Content []AnthropicInputContent `json:"content"`
+}
+
+// AnthropicInputContent represents a content block in a message sent to the
+// Anthropic API.
+type AnthropicInputContent struct {
+	Type         string                 `json:"type"`
+	Text         string                 `json:"text,omitempty"`
+	Source       *AnthropicImageSource  `json:"source,omitempty"`
+	CacheControl *AnthropicCacheControl `json:"cache_control,omitempty"`
+}
+
+// AnthropicCacheControl marks the prompt up to a content block as cacheable.
+type AnthropicCacheControl struct {
+	Type string `json:"type"`
+}
+
+// anthropicCacheControlEphemeral caches the prompt for a few minutes, which
+// is the only kind of caching Anthropic supports.
+var anthropicCacheControlEphemeral = &AnthropicCacheControl{Type: "ephemeral"}
+
+// anthropicCacheControl marks the last system block and the last user
+// message of the request as cacheable, so that follow ups with the same
+// system prompt and conversation are billed at the cache rate.
+func anthropicCacheControl(req *AnthropicMessageCompletionRequest) {
+	if n := len(req.System); n > 0 {
+		req.System[n-1].CacheControl = anthropicCacheControlEphemeral
+	}
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		msg := req.Messages[i]
+		if msg.Role != openai.ChatMessageRoleUser || len(msg.Content) == 0 {
+			continue
+		}
+		msg.Content[len(msg.Content)-1].CacheControl = anthropicCacheControlEphemeral
+		return
+	}
+}
+
+// AnthropicImageSource represents the source of an image content block.
+type AnthropicImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+// anthropicMessages converts the given messages into Anthropic's format,
+// turning image parts into image content blocks.
+func anthropicMessages(messages []openai.ChatCompletionMessage) ([]AnthropicInputMessage, error) {
+	result := make([]AnthropicInputMessage, 0, len(messages))
+	for _, message := range messages {
+		msg := AnthropicInputMessage{Role: message.Role}
+		if message.Content != "" {
+			msg.Content = append(msg.Content, AnthropicInputContent{
+				Type: "text",
+				Text: message.Content,
+			})
+		}
+		for _, part := range message.MultiContent {
+			switch part.Type {
+			case openai.ChatMessagePartTypeText:
+				if part.Text == "" {
+					continue
+				}
+				msg.Content = append(msg.Content, AnthropicInputContent{
+					Type: "text",
+					Text: part.Text,
+				})
+			case openai.ChatMessagePartTypeImageURL:
+				if part.ImageURL == nil {
+					continue
+				}
+				source, err := anthropicImageSource(part.ImageURL.URL)
+				if err != nil {
+					return nil, err
+				}
+				msg.Content = append(msg.Content, AnthropicInputContent{
+					Type:   "image",
+					Source: source,
+				})
+			}
+		}
+		result = append(result, msg)
+	}
+	return result, nil
+}
+
+func anthropicImageSource(u string) (*AnthropicImageSource, error) {
+	if !strings.HasPrefix(u, "data:") {
+		return &AnthropicImageSource{Type: "url", URL: u}, nil
+	}
+	mediaType, data, ok := strings.Cut(strings.TrimPrefix(u, "data:"), ",")
+	if !ok || !strings.HasSuffix(mediaType, ";base64") {
+		return nil, fmt.Errorf("anthropicImageSource: invalid data URI")
+	}
+	return &AnthropicImageSource{
+		Type:      "base64",
+		MediaType: strings.TrimSuffix(mediaType, ";base64"),
+		Data:      data,
+	}, nil
 }
 
 // AnthropicRequestBuilder is an interface for building HTTP requests for the Anthropic API.
@@ -138,8 +252,10 @@ func (c *AnthropicClient) handleErrorResp(resp *http.Response) error {
 
 // AnthropicMessageUsage represents the usage of an Anthropic message.
 type AnthropicMessageUsage struct {
-	InputTokens  int `json:"input_tokens,omitempty"`
-	OutputTokens int `json:"output_tokens,omitempty"`
+	InputTokens              int `json:"input_tokens,omitempty"`
+	OutputTokens             int `json:"output_tokens,omitempty"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens,omitempty"`
 }
 
 // AnthropicMessage represents an Anthropic message.
@@ -257,6 +373,18 @@ func (stream *anthropicStreamReader) processLines() (openai.ChatCompletionStream
 			return *new(openai.ChatCompletionStreamResponse), fmt.Errorf("anthropicStreamReader.processLines: %w", unmarshalErr)
 		}
 
+		if chunk.Type == "message_start" && chunk.Message != nil && chunk.Message.Usage != nil {
+			usage := chunk.Message.Usage
+			return openai.ChatCompletionStreamResponse{
+				Usage: &openai.Usage{
+					PromptTokens: usage.InputTokens + usage.CacheCreationInputTokens + usage.CacheReadInputTokens,
+					PromptTokensDetails: &openai.PromptTokensDetails{
+						CachedTokens: usage.CacheReadInputTokens,
+					},
+				},
+			}, nil
+		}
+
 		if chunk.Type != "content_block_delta" {
 			continue
 		}
@@ -325,3 +453,70 @@ func (c *AnthropicClient) CreateChatCompletionStream(
 	}
 	return
 }
+
+// AnthropicMessageResponse represents a complete, non-streamed Anthropic
+// message.
+type AnthropicMessageResponse struct {
+	ID         string                         `json:"id,omitempty"`
+	Type       string                         `json:"type"`
+	Role       string                         `json:"role,omitempty"`
+	Model      string                         `json:"model,omitempty"`
+	Content    []AnthropicMessageContentBlock `json:"content,omitempty"`
+	StopReason *string                        `json:"stop_reason,omitempty"`
+	Usage      *AnthropicMessageUsage         `json:"usage,omitempty"`
+}
+
+// text returns the text blocks of the message, leaving out thinking ones.
+func (r AnthropicMessageResponse) text() string {
+	var sb strings.Builder
+	for _, block := range r.Content {
+		if block.Type == "text" {
+			sb.WriteString(block.Text)
+		}
+	}
+	return sb.String()
+}
+
+// usage converts the usage of the message into OpenAI's.
+func (r AnthropicMessageResponse) usage() openai.Usage {
+	if r.Usage == nil {
+		return openai.Usage{}
+	}
+	prompt := r.Usage.InputTokens + r.Usage.CacheCreationInputTokens + r.Usage.CacheReadInputTokens
+	return openai.Usage{
+		PromptTokens:     prompt,
+		CompletionTokens: r.Usage.OutputTokens,
+		TotalTokens:      prompt + r.Usage.OutputTokens,
+		PromptTokensDetails: &openai.PromptTokensDetails{
+			CachedTokens: r.Usage.CacheReadInputTokens,
+		},
+	}
+}
+
+// CreateChatCompletion — API call to create a chat completion without
+// streaming, waiting for the complete message.
+func (c *AnthropicClient) CreateChatCompletion(
+	ctx context.Context,
+	request AnthropicMessageCompletionRequest,
+) (response AnthropicMessageResponse, err error) {
+	request.Stream = false
+	req, err := c.newRequest(ctx, http.MethodPost, c.config.BaseURL+anthropicChatCompletionsSuffix, withBody(request))
+	if err != nil {
+		return response, err
+	}
+	req.Header.Set("content-type", "application/json")
+	req.Header.Set("anthropic-beta", string(c.config.Beta))
+
+	resp, err := c.config.HTTPClient.Do(req)
+	if err != nil {
+		return response, err //nolint:wrapcheck
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if isFailureStatusCode(resp) {
+		return response, c.handleErrorResp(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return response, fmt.Errorf("anthropic: could not decode response: %w", err)
+	}
+	return response, nil
+}
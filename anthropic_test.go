@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnthropicCacheControl(t *testing.T) {
+	req := AnthropicMessageCompletionRequest{
+		Model:  "claude-3-5-sonnet-latest",
+		System: []AnthropicInputContent{{Type: "text", Text: "a long system prompt"}},
+		Messages: []AnthropicInputMessage{
+			{Role: openai.ChatMessageRoleUser, Content: []AnthropicInputContent{{Type: "text", Text: "first"}}},
+			{Role: openai.ChatMessageRoleAssistant, Content: []AnthropicInputContent{{Type: "text", Text: "answer"}}},
+			{Role: openai.ChatMessageRoleUser, Content: []AnthropicInputContent{{Type: "text", Text: "second"}}},
+		},
+	}
+	anthropicCacheControl(&req)
+
+	bts, err := json.Marshal(req)
+	require.NoError(t, err)
+	var body struct {
+		System   []map[string]any `json:"system"`
+		Messages []struct {
+			Content []map[string]any `json:"content"`
+		} `json:"messages"`
+	}
+	require.NoError(t, json.Unmarshal(bts, &body))
+
+	ephemeral := map[string]any{"type": "ephemeral"}
+	require.Equal(t, ephemeral, body.System[0]["cache_control"])
+	require.NotContains(t, body.Messages[0].Content[0], "cache_control")
+	require.NotContains(t, body.Messages[1].Content[0], "cache_control")
+	require.Equal(t, ephemeral, body.Messages[2].Content[0]["cache_control"])
+}
+
+func TestAnthropicStreamCacheUsage(t *testing.T) {
+	stream := &anthropicStreamReader{
+		emptyMessagesLimit: defaultEmptyMessagesLimit,
+		reader: bufio.NewReader(strings.NewReader(strings.Join([]string{
+			`event: message_start`,
+			`data: {"type":"message_start","message":{"usage":{"input_tokens":5,"cache_read_input_tokens":2000}}}`,
+			``,
+		}, "\n"))),
+		response:       &http.Response{},
+		errAccumulator: NewErrorAccumulator(),
+		unmarshaler:    &JSONUnmarshaler{},
+	}
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, 2005, resp.Usage.PromptTokens)
+	require.Equal(t, 2000, resp.Usage.PromptTokensDetails.CachedTokens)
+}
+
+func TestAnthropicThinkingBudget(t *testing.T) {
+	var body map[string]any
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "event: content_block_delta\n")
+		_, _ = fmt.Fprint(w, "data: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"42\"}}\n\n")
+	}))
+	t.Cleanup(ts.Close)
+
+	newAnthropicMods := func(budget int) *Mods {
+		return newMods(lipgloss.DefaultRenderer(), &Config{
+			Model:          "claude",
+			Quiet:          true,
+			NoLimit:        true,
+			Temperature:    1,
+			ThinkingBudget: budget,
+			Models: map[string]Model{
+				"claude": {Name: "claude-3-7-sonnet-latest", API: "anthropic", MaxChars: 100000, ThinkingBudget: 1024},
+				"gpt":    {Name: "gpt-4o", API: "openai"},
+			},
+			APIs: APIs{
+				{Name: "anthropic", BaseURL: ts.URL, APIKey: "anthropic-key"},
+				{Name: "openai", BaseURL: ts.URL, APIKey: "openai-key"},
+			},
+		}, nil, nil)
+	}
+
+	t.Run("model budget", func(t *testing.T) {
+		mods := newAnthropicMods(0)
+		require.Nil(t, mods.runHeadless("what is the answer?"))
+		require.Equal(t, "42", mods.Output)
+		require.Equal(t, map[string]any{"type": "enabled", "budget_tokens": 1024.0}, body["thinking"])
+		require.Equal(t, 4096.0+1024, body["max_tokens"])
+		require.NotContains(t, body, "temperature")
+	})
+
+	t.Run("flag overrides model", func(t *testing.T) {
+		mods := newAnthropicMods(2048)
+		require.Nil(t, mods.runHeadless("what is the answer?"))
+		require.Equal(t, map[string]any{"type": "enabled", "budget_tokens": 2048.0}, body["thinking"])
+	})
+
+	t.Run("larger than max-input-chars", func(t *testing.T) {
+		mods := newAnthropicMods(200000)
+		err := mods.runHeadless("what is the answer?")
+		require.NotNil(t, err)
+		require.Contains(t, err.reason, "too large")
+	})
+
+	t.Run("other APIs", func(t *testing.T) {
+		mods := newAnthropicMods(2048)
+		mods.Config.Model = "gpt"
+		err := mods.runHeadless("what is the answer?")
+		require.NotNil(t, err)
+		require.Contains(t, err.reason, "does not support")
+	})
+}
+
+func TestAnthropicNoStream(t *testing.T) {
+	var body map[string]any
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, `{"type":"message","role":"assistant","content":[
+			{"type":"thinking","thinking":"hmm"},
+			{"type":"text","text":"4"},
+			{"type":"text","text":"2"}
+		],"usage":{"input_tokens":10,"output_tokens":3,"cache_read_input_tokens":5}}`)
+	}))
+	t.Cleanup(ts.Close)
+
+	mods := newMods(lipgloss.DefaultRenderer(), &Config{
+		Model:    "claude",
+		Quiet:    true,
+		NoLimit:  true,
+		NoStream: true,
+		Models: map[string]Model{
+			"claude": {Name: "claude-3-5-sonnet-latest", API: "anthropic", MaxChars: 100000},
+		},
+		APIs: APIs{{Name: "anthropic", BaseURL: ts.URL, APIKey: "anthropic-key"}},
+	}, nil, nil)
+	require.Nil(t, mods.runHeadless("what is the answer?"))
+	require.Equal(t, "42", mods.Output)
+	require.NotContains(t, body, "stream")
+	input, output := mods.usage()
+	require.Equal(t, 15, input)
+	require.Equal(t, 3, output)
+}
+
+func TestAnthropicImages(t *testing.T) {
+	var body struct {
+		Messages []struct {
+			Role    string           `json:"role"`
+			Content []map[string]any `json:"content"`
+		} `json:"messages"`
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "event: content_block_delta\n")
+		_, _ = fmt.Fprint(w, "data: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"a pixel\"}}\n\n")
+	}))
+	t.Cleanup(ts.Close)
+
+	image := filepath.Join("testdata", "image.png")
+	mods := newMods(lipgloss.DefaultRenderer(), &Config{
+		Model:   "claude",
+		Quiet:   true,
+		NoLimit: true,
+		Images:  []string{image},
+		Models: map[string]Model{
+			"claude": {Name: "claude-3-5-sonnet-latest", API: "anthropic", MaxChars: 100000},
+		},
+		APIs: APIs{{Name: "anthropic", BaseURL: ts.URL, APIKey: "anthropic-key"}},
+	}, nil, nil)
+	require.Nil(t, mods.runHeadless("describe this"))
+	require.Equal(t, "a pixel", mods.Output)
+
+	bts, err := os.ReadFile(image)
+	require.NoError(t, err)
+	require.Len(t, body.Messages, 1)
+	require.Equal(t, []map[string]any{
+		{"type": "text", "text": "describe this"},
+		{"type": "image", "source": map[string]any{
+			"type":       "base64",
+			"media_type": "image/png",
+			"data":       base64.StdEncoding.EncodeToString(bts),
+		}},
+	}, body.Messages[0].Content)
+}
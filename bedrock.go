@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/caarlos0/go-shellwords"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const defaultBedrockRegion = "us-east-1"
+
+// BedrockClientConfig represents the configuration for the Amazon Bedrock
+// API client.
+type BedrockClientConfig struct {
+	BaseURL            string
+	Region             string
+	Credentials        aws.Credentials
+	HTTPClient         *http.Client
+	EmptyMessagesLimit uint
+}
+
+// DefaultBedrockConfig returns the default configuration for the Amazon
+// Bedrock API client.
+func DefaultBedrockConfig(region string, creds aws.Credentials) BedrockClientConfig {
+	if region == "" {
+		region = defaultBedrockRegion
+	}
+	return BedrockClientConfig{
+		BaseURL:            fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com", region),
+		Region:             region,
+		Credentials:        creds,
+		HTTPClient:         &http.Client{},
+		EmptyMessagesLimit: defaultEmptyMessagesLimit,
+	}
+}
+
+// bedrockRegion returns the region set in the API settings, or the one in
+// the standard AWS environment variables.
+func bedrockRegion(api API) string {
+	for _, region := range []string{api.Region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION")} {
+		if region != "" {
+			return region
+		}
+	}
+	return ""
+}
+
+// bedrockProcessCredentials is the output of an AWS credential_process, like
+// `aws configure export-credentials`.
+type bedrockProcessCredentials struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	SessionToken    string `json:"SessionToken"`
+}
+
+// bedrockCredentials returns the AWS credentials to sign requests with. They
+// come from api-key-cmd, which must print them like a credential_process,
+// from the AWS CLI if a profile is set, or from the standard AWS environment
+// variables.
+func bedrockCredentials(api API) (aws.Credentials, error) {
+	cmd := api.APIKeyCmd
+	if cmd == "" && api.Profile != "" {
+		cmd = "aws configure export-credentials --format process --profile " + api.Profile
+	}
+	if cmd == "" {
+		creds := aws.Credentials{
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}
+		if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+			return creds, errors.New("bedrockCredentials: AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are not set")
+		}
+		return creds, nil
+	}
+
+	args, err := shellwords.Parse(cmd)
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("bedrockCredentials: %w", err)
+	}
+	out, err := exec.Command(args[0], args[1:]...).Output() //nolint:gosec
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("bedrockCredentials: %w", err)
+	}
+	return parseBedrockCredentials(out)
+}
+
+func parseBedrockCredentials(bts []byte) (aws.Credentials, error) {
+	var pc bedrockProcessCredentials
+	if err := json.Unmarshal(bts, &pc); err != nil {
+		return aws.Credentials{}, fmt.Errorf("bedrockCredentials: %w", err)
+	}
+	if pc.AccessKeyID == "" || pc.SecretAccessKey == "" {
+		return aws.Credentials{}, errors.New("bedrockCredentials: missing AccessKeyId or SecretAccessKey")
+	}
+	return aws.Credentials{
+		AccessKeyID:     pc.AccessKeyID,
+		SecretAccessKey: pc.SecretAccessKey,
+		SessionToken:    pc.SessionToken,
+	}, nil
+}
+
+// BedrockContent is a content block of a Bedrock message.
+type BedrockContent struct {
+	Text string `json:"text"`
+}
+
+// BedrockMessage is a message in the Bedrock Converse API.
+type BedrockMessage struct {
+	Role    string           `json:"role"`
+	Content []BedrockContent `json:"content"`
+}
+
+// BedrockInferenceConfig are the generation parameters common to all
+// Bedrock models.
+type BedrockInferenceConfig struct {
+	MaxTokens     int      `json:"maxTokens,omitempty"`
+	Temperature   *float32 `json:"temperature,omitempty"`
+	TopP          *float32 `json:"topP,omitempty"`
+	StopSequences []string `json:"stopSequences,omitempty"`
+}
+
+// BedrockConverseRequest is the request to the Bedrock Converse API.
+type BedrockConverseRequest struct {
+	Messages        []BedrockMessage       `json:"messages"`
+	System          []BedrockContent       `json:"system,omitempty"`
+	InferenceConfig BedrockInferenceConfig `json:"inferenceConfig,omitempty"`
+}
+
+// BedrockClient is a client for the Amazon Bedrock API.
+type BedrockClient struct {
+	config BedrockClientConfig
+}
+
+// NewBedrockClientWithConfig creates a new BedrockClient with the given
+// configuration.
+func NewBedrockClientWithConfig(config BedrockClientConfig) *BedrockClient {
+	return &BedrockClient{config: config}
+}
+
+// CreateChatCompletionStream sends the request to the Converse API of the
+// given model and streams back its response.
+func (c *BedrockClient) CreateChatCompletionStream(
+	ctx context.Context,
+	model string,
+	request BedrockConverseRequest,
+) (*bedrockStreamReader, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("CreateChatCompletionStream: %w", err)
+	}
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		c.config.BaseURL+"/model/"+url.PathEscape(model)+"/converse-stream",
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("CreateChatCompletionStream: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/vnd.amazon.eventstream")
+
+	hash := sha256.Sum256(body)
+	if err := v4.NewSigner().SignHTTP(
+		ctx,
+		c.config.Credentials,
+		req,
+		hex.EncodeToString(hash[:]),
+		"bedrock",
+		c.config.Region,
+		time.Now(),
+	); err != nil {
+		return nil, fmt.Errorf("CreateChatCompletionStream: %w", err)
+	}
+
+	resp, err := c.config.HTTPClient.Do(req) //nolint:bodyclose // body is closed in stream.Close()
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	if isFailureStatusCode(resp) {
+		defer resp.Body.Close() //nolint:errcheck
+		return nil, bedrockErrorResp(resp)
+	}
+	return &bedrockStreamReader{
+		emptyMessagesLimit: c.config.EmptyMessagesLimit,
+		reader:             bufio.NewReader(resp.Body),
+		response:           resp,
+	}, nil
+}
+
+func bedrockErrorResp(resp *http.Response) error {
+	var errRes struct {
+		Message string `json:"message"`
+	}
+	bts, _ := io.ReadAll(resp.Body)
+	if err := json.Unmarshal(bts, &errRes); err != nil || errRes.Message == "" {
+		errRes.Message = strings.TrimSpace(string(bts))
+	}
+	return &openai.APIError{
+		HTTPStatusCode: resp.StatusCode,
+		Message:        errRes.Message,
+	}
+}
+
+// bedrockEvent is the payload of the events streamed by the Converse API.
+type bedrockEvent struct {
+	Delta struct {
+		Text string `json:"text"`
+	} `json:"delta"`
+	StopReason string `json:"stopReason"`
+	Message    string `json:"message"`
+	Usage      struct {
+		InputTokens  int `json:"inputTokens"`
+		OutputTokens int `json:"outputTokens"`
+		TotalTokens  int `json:"totalTokens"`
+	} `json:"usage"`
+}
+
+type bedrockStreamReader struct {
+	emptyMessagesLimit uint
+	isFinished         bool
+
+	reader   *bufio.Reader
+	response *http.Response
+}
+
+var _ chatCompletionReceiver = &bedrockStreamReader{}
+
+// Recv reads the next response from the stream.
+func (stream *bedrockStreamReader) Recv() (openai.ChatCompletionStreamResponse, error) {
+	var emptyMessagesCount uint
+	for !stream.isFinished {
+		headers, payload, err := readEventStreamMessage(stream.reader)
+		if err != nil {
+			return openai.ChatCompletionStreamResponse{}, fmt.Errorf("bedrockStreamReader.Recv: %w", err)
+		}
+
+		var event bedrockEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return openai.ChatCompletionStreamResponse{}, fmt.Errorf("bedrockStreamReader.Recv: %w", err)
+		}
+
+		if headers[":message-type"] == "exception" {
+			return openai.ChatCompletionStreamResponse{}, &openai.APIError{
+				Type:    headers[":exception-type"],
+				Message: event.Message,
+			}
+		}
+
+		switch headers[":event-type"] {
+		case "contentBlockDelta":
+			return openai.ChatCompletionStreamResponse{
+				Choices: []openai.ChatCompletionStreamChoice{
+					{
+						Index: 0,
+						Delta: openai.ChatCompletionStreamChoiceDelta{
+							Content: event.Delta.Text,
+							Role:    "assistant",
+						},
+					},
+				},
+			}, nil
+		case "messageStop":
+			return openai.ChatCompletionStreamResponse{
+				Choices: []openai.ChatCompletionStreamChoice{
+					{
+						Index:        0,
+						FinishReason: bedrockFinishReason(event.StopReason),
+					},
+				},
+			}, nil
+		case "metadata":
+			stream.isFinished = true
+			return openai.ChatCompletionStreamResponse{
+				Usage: &openai.Usage{
+					PromptTokens:     event.Usage.InputTokens,
+					CompletionTokens: event.Usage.OutputTokens,
+					TotalTokens:      event.Usage.TotalTokens,
+				},
+			}, nil
+		}
+
+		emptyMessagesCount++
+		if emptyMessagesCount > stream.emptyMessagesLimit {
+			return openai.ChatCompletionStreamResponse{}, ErrTooManyEmptyStreamMessages
+		}
+	}
+	return openai.ChatCompletionStreamResponse{}, io.EOF
+}
+
+// Close closes the stream.
+func (stream *bedrockStreamReader) Close() error {
+	return stream.response.Body.Close() //nolint:wrapcheck
+}
+
+func bedrockFinishReason(reason string) openai.FinishReason {
+	switch reason {
+	case "max_tokens":
+		return openai.FinishReasonLength
+	case "content_filtered", "guardrail_intervened":
+		return openai.FinishReasonContentFilter
+	default:
+		return openai.FinishReasonStop
+	}
+}
+
+// readEventStreamMessage reads a message in the AWS event stream encoding,
+// returning its string headers and payload.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/RESTSelectObjectAppendix.html
+func readEventStreamMessage(r io.Reader) (map[string]string, []byte, error) {
+	const preludeLen, crcLen = 12, 4
+
+	prelude := make([]byte, preludeLen)
+	if _, err := io.ReadFull(r, prelude); err != nil {
+		return nil, nil, err //nolint:wrapcheck
+	}
+	totalLen := binary.BigEndian.Uint32(prelude[0:4])
+	headersLen := binary.BigEndian.Uint32(prelude[4:8])
+	if totalLen < preludeLen+crcLen+headersLen {
+		return nil, nil, fmt.Errorf("invalid event stream message length %d", totalLen)
+	}
+
+	rest := make([]byte, totalLen-preludeLen)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return nil, nil, err //nolint:wrapcheck
+	}
+	headers, err := parseEventStreamHeaders(rest[:headersLen])
+	if err != nil {
+		return nil, nil, err
+	}
+	return headers, rest[headersLen : len(rest)-crcLen], nil
+}
+
+// eventStreamValueLen is the size of the fixed size header value types.
+var eventStreamValueLen = map[byte]int{
+	0: 0,  // true
+	1: 0,  // false
+	2: 1,  // byte
+	3: 2,  // short
+	4: 4,  // int
+	5: 8,  // long
+	8: 8,  // timestamp
+	9: 16, // uuid
+}
+
+func parseEventStreamHeaders(b []byte) (map[string]string, error) {
+	errInvalid := errors.New("invalid event stream headers")
+	headers := map[string]string{}
+	for len(b) > 0 {
+		nameLen := int(b[0])
+		if len(b) < 1+nameLen+1 {
+			return nil, errInvalid
+		}
+		name := string(b[1 : 1+nameLen])
+		typ := b[1+nameLen]
+		b = b[1+nameLen+1:]
+
+		// bytes and strings are prefixed with their length.
+		if typ == 6 || typ == 7 {
+			if len(b) < 2 { //nolint:mnd
+				return nil, errInvalid
+			}
+			valueLen := int(binary.BigEndian.Uint16(b[:2]))
+			if len(b) < 2+valueLen {
+				return nil, errInvalid
+			}
+			if typ == 7 {
+				headers[name] = string(b[2 : 2+valueLen])
+			}
+			b = b[2+valueLen:]
+			continue
+		}
+
+		valueLen, ok := eventStreamValueLen[typ]
+		if !ok || len(b) < valueLen {
+			return nil, errInvalid
+		}
+		b = b[valueLen:]
+	}
+	return headers, nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+// eventStreamMessage encodes an AWS event stream message with the given
+// string headers. The CRCs are not checked, so they are left empty.
+func eventStreamMessage(headers map[string]string, payload string) []byte {
+	var hb bytes.Buffer
+	for k, v := range headers {
+		hb.WriteByte(byte(len(k)))
+		hb.WriteString(k)
+		hb.WriteByte(7)
+		_ = binary.Write(&hb, binary.BigEndian, uint16(len(v)))
+		hb.WriteString(v)
+	}
+	var b bytes.Buffer
+	_ = binary.Write(&b, binary.BigEndian, uint32(12+hb.Len()+len(payload)+4))
+	_ = binary.Write(&b, binary.BigEndian, uint32(hb.Len()))
+	_ = binary.Write(&b, binary.BigEndian, uint32(0))
+	b.Write(hb.Bytes())
+	b.WriteString(payload)
+	_ = binary.Write(&b, binary.BigEndian, uint32(0))
+	return b.Bytes()
+}
+
+func bedrockEventMessage(event, payload string) []byte {
+	return eventStreamMessage(map[string]string{
+		":message-type": "event",
+		":event-type":   event,
+		":content-type": "application/json",
+	}, payload)
+}
+
+func TestBedrockStream(t *testing.T) {
+	var auth, path string
+	var body BedrockConverseRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		path = r.URL.EscapedPath()
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+		_, _ = w.Write(bedrockEventMessage("messageStart", `{"role":"assistant"}`))
+		_, _ = w.Write(bedrockEventMessage("contentBlockDelta", `{"contentBlockIndex":0,"delta":{"text":"Hello"}}`))
+		_, _ = w.Write(bedrockEventMessage("contentBlockDelta", `{"contentBlockIndex":0,"delta":{"text":" there"}}`))
+		_, _ = w.Write(bedrockEventMessage("contentBlockStop", `{"contentBlockIndex":0}`))
+		_, _ = w.Write(bedrockEventMessage("messageStop", `{"stopReason":"max_tokens"}`))
+		_, _ = w.Write(bedrockEventMessage("metadata", `{"usage":{"inputTokens":3,"outputTokens":2,"totalTokens":5}}`))
+	}))
+	t.Cleanup(ts.Close)
+
+	cfg := DefaultBedrockConfig("eu-west-1", aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"})
+	cfg.BaseURL = ts.URL
+	temp := float32(0.5)
+	stream, err := NewBedrockClientWithConfig(cfg).CreateChatCompletionStream(
+		context.Background(),
+		"anthropic.claude-3-5-sonnet-20240620-v1:0",
+		BedrockConverseRequest{
+			Messages:        []BedrockMessage{{Role: "user", Content: []BedrockContent{{Text: "hi"}}}},
+			InferenceConfig: BedrockInferenceConfig{MaxTokens: 10, Temperature: &temp},
+		},
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = stream.Close() })
+
+	require.True(t, strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/"), auth)
+	require.Contains(t, auth, "/eu-west-1/bedrock/aws4_request")
+	require.Equal(t, "/model/anthropic.claude-3-5-sonnet-20240620-v1:0/converse-stream", path)
+	require.Equal(t, 10, body.InferenceConfig.MaxTokens)
+	require.Equal(t, "hi", body.Messages[0].Content[0].Text)
+
+	var content string
+	var finish openai.FinishReason
+	var usage *openai.Usage
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if resp.Usage != nil {
+			usage = resp.Usage
+		}
+		for _, choice := range resp.Choices {
+			content += choice.Delta.Content
+			if choice.FinishReason != "" {
+				finish = choice.FinishReason
+			}
+		}
+	}
+	require.Equal(t, "Hello there", content)
+	require.Equal(t, openai.FinishReasonLength, finish)
+	require.Equal(t, 5, usage.TotalTokens)
+}
+
+func TestBedrockStreamException(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(eventStreamMessage(map[string]string{
+			":message-type":   "exception",
+			":exception-type": "throttlingException",
+		}, `{"message":"slow down"}`))
+	}))
+	t.Cleanup(ts.Close)
+
+	cfg := DefaultBedrockConfig("", aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"})
+	cfg.BaseURL = ts.URL
+	stream, err := NewBedrockClientWithConfig(cfg).CreateChatCompletionStream(context.Background(), "model", BedrockConverseRequest{})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = stream.Close() })
+
+	_, err = stream.Recv()
+	var apiErr *openai.APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, "throttlingException", apiErr.Type)
+	require.Equal(t, "slow down", apiErr.Message)
+}
+
+func TestBedrockCredentials(t *testing.T) {
+	creds, err := parseBedrockCredentials([]byte(`{"Version":1,"AccessKeyId":"AKID","SecretAccessKey":"secret","SessionToken":"token"}`))
+	require.NoError(t, err)
+	require.Equal(t, aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "token"}, creds)
+
+	_, err = parseBedrockCredentials([]byte(`{"Version":1}`))
+	require.Error(t, err)
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "env-id")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	creds, err = bedrockCredentials(API{})
+	require.NoError(t, err)
+	require.Equal(t, "env-id", creds.AccessKeyID)
+
+	t.Setenv("AWS_REGION", "ap-south-1")
+	require.Equal(t, "ap-south-1", bedrockRegion(API{}))
+	require.Equal(t, "us-west-2", bedrockRegion(API{Region: "us-west-2"}))
+}
@@ -1,17 +1,28 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	openai "github.com/sashabaranov/go-openai"
 )
 
-const cacheExt = ".gob"
+const (
+	cacheExt = ".gob"
+	ttlExt   = ".ttl"
+)
+
+// countsFile keeps the message and token counts of the conversations, so
+// --list doesn't need to read them all every time.
+const countsFile = "counts.json"
 
 var errInvalidID = errors.New("invalid id")
 
@@ -64,6 +75,219 @@ func (c *convoCache) delete(id string) error {
 	if err := os.Remove(filepath.Join(c.dir, id+cacheExt)); err != nil {
 		return fmt.Errorf("delete: %w", err)
 	}
+	if err := os.Remove(filepath.Join(c.dir, id+ttlExt)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("delete: %w", err)
+	}
+	return nil
+}
+
+// writeTTL records when the given conversation expires, in a sidecar file
+// next to it.
+func (c *convoCache) writeTTL(id string, expires time.Time) error {
+	if id == "" {
+		return fmt.Errorf("writeTTL: %w", errInvalidID)
+	}
+	if err := os.WriteFile(
+		filepath.Join(c.dir, id+ttlExt),
+		[]byte(expires.UTC().Format(time.RFC3339)),
+		0o600, //nolint:mnd
+	); err != nil {
+		return fmt.Errorf("writeTTL: %w", err)
+	}
+	return nil
+}
+
+// expired returns the IDs of the conversations that expired before now.
+func (c *convoCache) expired(now time.Time, pinned map[string]bool) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(c.dir, "*"+ttlExt))
+	if err != nil {
+		return nil, fmt.Errorf("expired: %w", err)
+	}
+	var ids []string
+	for _, file := range files {
+		bts, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("expired: %w", err)
+		}
+		expires, err := time.Parse(time.RFC3339, strings.TrimSpace(string(bts)))
+		if err != nil {
+			// ignore sidecars we can't make sense of.
+			continue
+		}
+		id := strings.TrimSuffix(filepath.Base(file), ttlExt)
+		if expires.Before(now) && !pinned[id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// evictExpiredConversations deletes the conversations saved with a
+// --cache-ttl that has passed, unless they are pinned.
+func evictExpiredConversations(db *convoDB, cache *convoCache) error {
+	pinned, err := db.Pinned()
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	ids, err := cache.expired(time.Now(), pinned)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := db.Delete(id); err != nil {
+			return err //nolint:wrapcheck
+		}
+		if err := cache.delete(id); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		// the conversation might be gone already, but its sidecar not.
+		_ = os.Remove(filepath.Join(cache.dir, id+ttlExt))
+	}
+	return nil
+}
+
+// oversized returns the IDs of the oldest conversations that have to go for
+// the rest to fit in maxSize bytes and maxCount conversations, leaving keep
+// and the pinned ones alone. A limit of 0 means there is none.
+func (c *convoCache) oversized(keep string, pinned map[string]bool, maxSize int64, maxCount int) ([]string, error) {
+	if maxSize <= 0 && maxCount <= 0 {
+		return nil, nil
+	}
+	files, err := filepath.Glob(filepath.Join(c.dir, "*"+cacheExt))
+	if err != nil {
+		return nil, fmt.Errorf("oversized: %w", err)
+	}
+	infos := make([]os.FileInfo, 0, len(files))
+	var size int64
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			// deleted in the meantime.
+			continue
+		}
+		infos = append(infos, info)
+		size += info.Size()
+	}
+	sort.SliceStable(infos, func(i, j int) bool {
+		return infos[i].ModTime().Before(infos[j].ModTime())
+	})
+
+	count := len(infos)
+	var ids []string
+	for _, info := range infos {
+		if (maxSize <= 0 || size <= maxSize) && (maxCount <= 0 || count <= maxCount) {
+			break
+		}
+		id := strings.TrimSuffix(info.Name(), cacheExt)
+		if id == keep || pinned[id] {
+			continue
+		}
+		ids = append(ids, id)
+		size -= info.Size()
+		count--
+	}
+	return ids, nil
+}
+
+// evictOversizedConversations deletes the oldest conversations until the rest
+// fit in --cache-max-size and --cache-max-count, keeping the one just saved
+// and the pinned ones.
+func evictOversizedConversations(db *convoDB, cache *convoCache, keep string, maxSize int64, maxCount int) error {
+	if maxSize <= 0 && maxCount <= 0 {
+		return nil
+	}
+	pinned, err := db.Pinned()
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	ids, err := cache.oversized(keep, pinned, maxSize, maxCount)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := db.Delete(id); err != nil {
+			return err //nolint:wrapcheck
+		}
+		if err := cache.delete(id); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+type convoCounts struct {
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mod_time"`
+	Messages int       `json:"messages"`
+	Tokens   int       `json:"tokens"`
+}
+
+// counts sets the number of messages and approximate tokens of the given
+// conversations, reading only those that changed since they were last
+// counted. Conversations that can't be read are left at 0.
+func (c *convoCache) counts(conversations []Conversation) error {
+	index := map[string]convoCounts{}
+	path := filepath.Join(c.dir, countsFile)
+	if bts, err := os.ReadFile(path); err == nil {
+		// start over if the index is broken.
+		_ = json.Unmarshal(bts, &index)
+	}
+
+	changed := false
+	for i, convo := range conversations {
+		info, err := os.Stat(filepath.Join(c.dir, convo.ID+cacheExt))
+		if err != nil {
+			continue
+		}
+		counts, ok := index[convo.ID]
+		if !ok || counts.Size != info.Size() || !counts.ModTime.Equal(info.ModTime()) {
+			var messages []openai.ChatCompletionMessage
+			if err := c.read(convo.ID, &messages); err != nil {
+				continue
+			}
+			counts = convoCounts{
+				Size:     info.Size(),
+				ModTime:  info.ModTime(),
+				Messages: len(messages),
+				Tokens:   estimateTokens(messagesText(messages)),
+			}
+			index[convo.ID] = counts
+			changed = true
+		}
+		conversations[i].MessageCount = counts.Messages
+		conversations[i].ApproxTokens = counts.Tokens
+	}
+	if !changed {
+		return nil
+	}
+
+	bts, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("counts: %w", err)
+	}
+	if err := os.WriteFile(path, bts, 0o600); err != nil { //nolint:mnd
+		return fmt.Errorf("counts: %w", err)
+	}
+	return nil
+}
+
+// indexConversations adds the conversations saved before search existed to
+// the search index.
+func indexConversations(db *convoDB, cache *convoCache) error {
+	ids, err := db.Unindexed()
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	for _, id := range ids {
+		var messages []openai.ChatCompletionMessage
+		if err := cache.read(id, &messages); err != nil {
+			// the conversation can still be found by its title.
+			messages = nil
+		}
+		if err := db.Index(id, messagesText(messages)); err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
 	return nil
 }
 
@@ -85,27 +309,12 @@ func (c *cachedCompletionStream) Recv() (openai.ChatCompletionStreamResponse, er
 	}
 
 	msg := c.messages[c.read]
-	prefix := ""
-
-	switch msg.Role {
-	case openai.ChatMessageRoleSystem:
-		prefix += "\n**System**: "
-	case openai.ChatMessageRoleUser:
-		prefix += "\n**Prompt**: "
-	case openai.ChatMessageRoleAssistant:
-		prefix += "\n**Assistant**: "
-	case openai.ChatMessageRoleFunction:
-		prefix += "\n**Function**: "
-	case openai.ChatMessageRoleTool:
-		prefix += "\n**Tool**: "
-	}
-
 	c.read++
 	return openai.ChatCompletionStreamResponse{
 		Choices: []openai.ChatCompletionStreamChoice{
 			{
 				Delta: openai.ChatCompletionStreamChoiceDelta{
-					Content: prefix + msg.Content + "\n",
+					Content: messageMarkdown(msg),
 					Role:    msg.Role,
 				},
 			},
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/sashabaranov/go-openai"
 	"github.com/stretchr/testify/require"
@@ -124,3 +125,181 @@ func TestCachedCompletionStream(t *testing.T) {
 
 	require.Equal(t, string(bytes.ReplaceAll(bts, []byte("\r\n"), []byte("\n"))), content)
 }
+
+func TestIndexConversations(t *testing.T) {
+	const testid1 = "fc5012d8c67073ea0a46a3c05488a0e1d87df74b"
+	const testid2 = "6c33f71694bf41a18c844a96d1f62f153e5f6f44"
+
+	db := testDB(t)
+	cache := newCache(t.TempDir())
+	require.NoError(t, db.Save(testid1, "numbers", "gpt-4o"))
+	require.NoError(t, db.Save(testid2, "no cache file", "gpt-4o"))
+	require.NoError(t, cache.write(testid1, &[]openai.ChatCompletionMessage{
+		{
+			Role:    openai.ChatMessageRoleUser,
+			Content: "first 4 natural numbers",
+		},
+		{
+			Role:    openai.ChatMessageRoleAssistant,
+			Content: "1, 2, 3, 4",
+		},
+	}))
+
+	require.NoError(t, indexConversations(db, cache))
+
+	ids, err := db.Unindexed()
+	require.NoError(t, err)
+	require.Empty(t, ids)
+
+	results, err := db.Search("natural")
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, testid1, results[0].ID)
+}
+
+func TestEvictExpiredConversations(t *testing.T) {
+	const expired = "fc5012d8c67073ea0a46a3c05488a0e1d87df74b"
+	const alive = "6c33f71694bf41a18c844a96d1f62f153e5f6f44"
+	const forever = "df31ae23ab8b75b5643c2f846c570997edc71333"
+
+	db := testDB(t)
+	cache := newCache(t.TempDir())
+	for _, id := range []string{expired, alive, forever} {
+		require.NoError(t, db.Save(id, id, "gpt-4o"))
+		require.NoError(t, cache.write(id, &[]openai.ChatCompletionMessage{}))
+	}
+	require.NoError(t, cache.writeTTL(expired, time.Now().Add(-time.Minute)))
+	require.NoError(t, cache.writeTTL(alive, time.Now().Add(time.Hour)))
+
+	require.NoError(t, evictExpiredConversations(db, cache))
+
+	convos, err := db.List()
+	require.NoError(t, err)
+	require.Len(t, convos, 2)
+	for _, c := range convos {
+		require.NotEqual(t, expired, c.ID)
+	}
+	require.ErrorIs(t, cache.read(expired, nil), os.ErrNotExist)
+	require.NoFileExists(t, filepath.Join(cache.dir, expired+ttlExt))
+	require.FileExists(t, filepath.Join(cache.dir, alive+ttlExt))
+
+	t.Run("pinned", func(t *testing.T) {
+		require.NoError(t, cache.writeTTL(alive, time.Now().Add(-time.Minute)))
+		require.NoError(t, db.Pin(alive))
+		require.NoError(t, evictExpiredConversations(db, cache))
+		convos, err := db.List()
+		require.NoError(t, err)
+		require.Len(t, convos, 2)
+		require.NoError(t, cache.read(alive, &[]openai.ChatCompletionMessage{}))
+	})
+}
+
+func TestEvictOversizedConversations(t *testing.T) {
+	const (
+		oldest = "fc5012d8c67073ea0a46a3c05488a0e1d87df74b"
+		older  = "6c33f71694bf41a18c844a96d1f62f153e5f6f44"
+		newest = "df31ae23ab8b75b5643c2f846c570997edc71333"
+	)
+	ids := []string{oldest, older, newest}
+
+	setup := func(t *testing.T) (*convoDB, *convoCache) {
+		t.Helper()
+		db := testDB(t)
+		cache := newCache(t.TempDir())
+		now := time.Now()
+		for i, id := range ids {
+			require.NoError(t, db.Save(id, id, "gpt-4o"))
+			path := filepath.Join(cache.dir, id+cacheExt)
+			require.NoError(t, os.WriteFile(path, make([]byte, 100), 0o600))
+			mtime := now.Add(time.Duration(i-len(ids)) * time.Minute)
+			require.NoError(t, os.Chtimes(path, mtime, mtime))
+		}
+		return db, cache
+	}
+	left := func(t *testing.T, db *convoDB) []string {
+		t.Helper()
+		convos, err := db.List()
+		require.NoError(t, err)
+		var ids []string
+		for _, c := range convos {
+			ids = append(ids, c.ID)
+		}
+		return ids
+	}
+
+	t.Run("no limits", func(t *testing.T) {
+		db, cache := setup(t)
+		require.NoError(t, evictOversizedConversations(db, cache, newest, 0, 0))
+		require.ElementsMatch(t, ids, left(t, db))
+	})
+
+	t.Run("max size", func(t *testing.T) {
+		db, cache := setup(t)
+		require.NoError(t, evictOversizedConversations(db, cache, newest, 250, 0))
+		require.ElementsMatch(t, []string{older, newest}, left(t, db))
+		require.NoFileExists(t, filepath.Join(cache.dir, oldest+cacheExt))
+		require.FileExists(t, filepath.Join(cache.dir, older+cacheExt))
+	})
+
+	t.Run("max count", func(t *testing.T) {
+		db, cache := setup(t)
+		require.NoError(t, evictOversizedConversations(db, cache, newest, 0, 1))
+		require.ElementsMatch(t, []string{newest}, left(t, db))
+	})
+
+	t.Run("keeps the one just saved", func(t *testing.T) {
+		db, cache := setup(t)
+		require.NoError(t, evictOversizedConversations(db, cache, oldest, 50, 0))
+		require.ElementsMatch(t, []string{oldest}, left(t, db))
+		require.FileExists(t, filepath.Join(cache.dir, oldest+cacheExt))
+	})
+
+	t.Run("keeps the pinned ones", func(t *testing.T) {
+		db, cache := setup(t)
+		require.NoError(t, db.Pin(oldest))
+		require.NoError(t, evictOversizedConversations(db, cache, newest, 0, 1))
+		require.ElementsMatch(t, []string{oldest, newest}, left(t, db))
+		require.FileExists(t, filepath.Join(cache.dir, oldest+cacheExt))
+	})
+}
+
+func TestCounts(t *testing.T) {
+	const id = "df31ae23ab8b75b5643c2f846c570997edc71333"
+	cache := newCache(t.TempDir())
+	require.NoError(t, cache.write(id, &[]openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleUser, Content: "12345678"},
+		{Role: openai.ChatMessageRoleAssistant, Content: "abcdefg"},
+	}))
+
+	convos := []Conversation{{ID: id}, {ID: "missing"}}
+	require.NoError(t, cache.counts(convos))
+	require.Equal(t, 2, convos[0].MessageCount)
+	require.Equal(t, 4, convos[0].ApproxTokens)
+	require.Zero(t, convos[1].MessageCount)
+	require.FileExists(t, filepath.Join(cache.dir, countsFile))
+
+	t.Run("from the index", func(t *testing.T) {
+		// a broken conversation with unchanged size and time is not read again.
+		path := filepath.Join(cache.dir, id+cacheExt)
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path, make([]byte, info.Size()), 0o600))
+		require.NoError(t, os.Chtimes(path, info.ModTime(), info.ModTime()))
+
+		convos := []Conversation{{ID: id}}
+		require.NoError(t, cache.counts(convos))
+		require.Equal(t, 2, convos[0].MessageCount)
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		require.NoError(t, cache.write(id, &[]openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleUser, Content: "hi"},
+		}))
+		future := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(filepath.Join(cache.dir, id+cacheExt), future, future))
+
+		convos := []Conversation{{ID: id}}
+		require.NoError(t, cache.counts(convos))
+		require.Equal(t, 1, convos[0].MessageCount)
+	})
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// defaultCircuitBreakerTimeout is how long an API is left alone after too
+// many failed requests, unless circuit-breaker-timeout says otherwise.
+const defaultCircuitBreakerTimeout = 30 * time.Second
+
+type circuitState int
+
+const (
+	// circuitClosed lets requests through.
+	circuitClosed circuitState = iota
+	// circuitOpen fails requests right away.
+	circuitOpen
+	// circuitHalfOpen lets one request through to see if the API is back.
+	circuitHalfOpen
+)
+
+// circuitBreaker stops sending requests to an API that keeps failing, so
+// --parallel, --times and --watch runs don't keep hammering it.
+type circuitBreaker struct {
+	mu       sync.Mutex
+	failures map[string]int
+	openedAt map[string]time.Time
+	now      func() time.Time
+}
+
+func newCircuitBreaker() *circuitBreaker {
+	return &circuitBreaker{
+		failures: map[string]int{},
+		openedAt: map[string]time.Time{},
+		now:      time.Now,
+	}
+}
+
+// breaker is shared by all the requests of the process.
+var breaker = newCircuitBreaker()
+
+func (b *circuitBreaker) state(api string, timeout time.Duration) circuitState {
+	opened, ok := b.openedAt[api]
+	switch {
+	case !ok:
+		return circuitClosed
+	case b.now().Sub(opened) < timeout:
+		return circuitOpen
+	default:
+		return circuitHalfOpen
+	}
+}
+
+// allow returns an error if requests to api should not be sent yet.
+func (b *circuitBreaker) allow(api string, timeout time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state(api, timeout) {
+	case circuitOpen:
+		wait := timeout - b.now().Sub(b.openedAt[api])
+		return fmt.Errorf("too many failed requests, trying again in %s", wait.Round(time.Second))
+	case circuitHalfOpen:
+		// let this one through, and fail the others until we know more.
+		b.openedAt[api] = b.now()
+	}
+	return nil
+}
+
+// failure records a failed request to api, opening the circuit once there
+// were maxFailures of them in a row, or right away if it was half open.
+func (b *circuitBreaker) failure(api string, maxFailures int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures[api]++
+	if _, ok := b.openedAt[api]; ok || b.failures[api] >= maxFailures {
+		b.openedAt[api] = b.now()
+	}
+}
+
+// success closes the circuit of api.
+func (b *circuitBreaker) success(api string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.failures, api)
+	delete(b.openedAt, api)
+}
+
+func circuitBreakerTimeout(cfg *Config) time.Duration {
+	if cfg.CircuitBreakerTimeout > 0 {
+		return cfg.CircuitBreakerTimeout
+	}
+	return defaultCircuitBreakerTimeout
+}
+
+// isAPIFailure reports whether err means the API itself is in trouble: it
+// rate limited us, had a server error or could not be reached. Errors about
+// the request, like a missing model or a prompt too long, are not.
+func isAPIFailure(err error) bool {
+	ae := &openai.APIError{}
+	if errors.As(err, &ae) {
+		return ae.HTTPStatusCode == http.StatusTooManyRequests ||
+			ae.HTTPStatusCode >= http.StatusInternalServerError
+	}
+	re := &openai.RequestError{}
+	if errors.As(err, &re) {
+		return re.HTTPStatusCode == http.StatusTooManyRequests ||
+			re.HTTPStatusCode >= http.StatusInternalServerError
+	}
+	var nerr net.Error
+	return errors.As(err, &nerr)
+}
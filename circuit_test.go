@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	b := newCircuitBreaker()
+	b.now = func() time.Time { return now }
+	const timeout = 30 * time.Second
+
+	require.Equal(t, circuitClosed, b.state("openai", timeout))
+	require.NoError(t, b.allow("openai", timeout))
+
+	// opens after max failures in a row.
+	b.failure("openai", 2)
+	require.Equal(t, circuitClosed, b.state("openai", timeout))
+	b.failure("openai", 2)
+	require.Equal(t, circuitOpen, b.state("openai", timeout))
+	require.EqualError(t, b.allow("openai", timeout), "too many failed requests, trying again in 30s")
+
+	// other APIs are not affected.
+	require.NoError(t, b.allow("anthropic", timeout))
+
+	// half open once the timeout passed, letting a single request through.
+	now = now.Add(timeout)
+	require.Equal(t, circuitHalfOpen, b.state("openai", timeout))
+	require.NoError(t, b.allow("openai", timeout))
+	require.Error(t, b.allow("openai", timeout))
+
+	// a failure while half open opens it again right away.
+	b.failure("openai", 2)
+	require.Equal(t, circuitOpen, b.state("openai", timeout))
+
+	// a success closes it.
+	now = now.Add(timeout)
+	require.NoError(t, b.allow("openai", timeout))
+	b.success("openai")
+	require.Equal(t, circuitClosed, b.state("openai", timeout))
+	b.failure("openai", 2)
+	require.Equal(t, circuitClosed, b.state("openai", timeout))
+}
+
+// testBreaker gives the test a circuit breaker of its own, so the failures it
+// causes don't fail the requests of other tests.
+func testBreaker(t *testing.T) {
+	t.Helper()
+	oldBreaker := breaker
+	t.Cleanup(func() { breaker = oldBreaker })
+	breaker = newCircuitBreaker()
+}
+
+func TestCircuitBreakerRequests(t *testing.T) {
+	testBreaker(t)
+
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"error":{"message":"overloaded","type":"server_error"}}`))
+	}))
+	t.Cleanup(ts.Close)
+
+	cfg := &Config{
+		Model:      "gpt-4o",
+		Prefix:     "hello",
+		Quiet:      true,
+		NoCache:    true,
+		MaxRetries: 2,
+		Models: map[string]Model{
+			"gpt-4o": {Name: "gpt-4o", API: "openai", MaxChars: 1000},
+		},
+		APIs: APIs{
+			{Name: "openai", BaseURL: ts.URL, APIKey: "fake"},
+		},
+	}
+	run := func() *modsError {
+		return newMods(lipgloss.DefaultRenderer(), cfg, nil, nil).runHeadless("")
+	}
+
+	require.NotNil(t, run())
+	require.Equal(t, int32(2), hits.Load())
+
+	err := run()
+	require.NotNil(t, err)
+	require.Equal(t, "The openai API keeps failing.", err.reason)
+	require.Equal(t, int32(2), hits.Load())
+}
+
+func TestIsAPIFailure(t *testing.T) {
+	require.True(t, isAPIFailure(&openai.APIError{HTTPStatusCode: http.StatusTooManyRequests}))
+	require.True(t, isAPIFailure(&openai.APIError{HTTPStatusCode: http.StatusBadGateway}))
+	require.True(t, isAPIFailure(&openai.RequestError{HTTPStatusCode: http.StatusServiceUnavailable}))
+	require.True(t, isAPIFailure(&net.OpError{Op: "dial", Err: errors.New("connection refused")}))
+	require.False(t, isAPIFailure(&openai.APIError{HTTPStatusCode: http.StatusNotFound}))
+	require.False(t, isAPIFailure(&openai.APIError{HTTPStatusCode: http.StatusBadRequest, Code: "context_length_exceeded"}))
+	require.False(t, isAPIFailure(errors.New("something else")))
+}
+
+func TestCircuitBreakerFallback(t *testing.T) {
+	testBreaker(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if req.Model == "missing" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"message":"no such model","type":"invalid_request_error"}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":{"message":"bad request","type":"invalid_request_error"}}`))
+	}))
+	t.Cleanup(ts.Close)
+
+	mods := newMods(lipgloss.DefaultRenderer(), &Config{
+		Model:      "missing",
+		Prefix:     "hello",
+		Quiet:      true,
+		NoCache:    true,
+		MaxRetries: 2,
+		Models: map[string]Model{
+			"missing": {Name: "missing", API: "openai", MaxChars: 1000, Fallback: "gpt-4o"},
+			"gpt-4o":  {Name: "gpt-4o", API: "openai", MaxChars: 1000},
+		},
+		APIs: APIs{{Name: "openai", BaseURL: ts.URL, APIKey: "fake"}},
+	}, nil, nil)
+	err := mods.runHeadless("")
+	require.NotNil(t, err)
+	require.Equal(t, "openai API request error.", err.reason)
+	// neither the missing model nor the bad request are the API's fault.
+	require.Zero(t, breaker.failures["openai"])
+}
+
+func TestCircuitBreakerBrokenStream(t *testing.T) {
+	testBreaker(t)
+	now := time.Now()
+	breaker.now = func() time.Time { return now }
+	breaker.failure("openai", 1)
+	now = now.Add(time.Hour)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hi\"}}]}\n\ndata: {broken\n\n"))
+	}))
+	t.Cleanup(ts.Close)
+
+	mods := newMods(lipgloss.DefaultRenderer(), &Config{
+		Model:      "gpt-4o",
+		Prefix:     "hello",
+		Quiet:      true,
+		NoCache:    true,
+		MaxRetries: 1,
+		Models:     map[string]Model{"gpt-4o": {Name: "gpt-4o", API: "openai", MaxChars: 1000}},
+		APIs:       APIs{{Name: "openai", BaseURL: ts.URL, APIKey: "fake"}},
+	}, nil, nil)
+	require.NotNil(t, mods.runHeadless(""))
+	require.Equal(t, circuitOpen, breaker.state("openai", time.Minute))
+}
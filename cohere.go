@@ -126,6 +126,19 @@ func (c *CohereClient) CreateChatCompletionStream(
 	return
 }
 
+// CreateChatCompletion — API call to create a chat completion without
+// streaming.
+func (c *CohereClient) CreateChatCompletion(
+	ctx context.Context,
+	request *cohere.ChatRequest,
+) (*cohere.NonStreamedChatResponse, error) {
+	resp, err := c.Chat(ctx, request)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	return resp, nil
+}
+
 // CohereToOpenAIAPIError attempts to convert a Cohere API error into
 // an OpenAI API error to later reuse the existing error handling logic.
 func CohereToOpenAIAPIError(err error) error {
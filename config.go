@@ -1,8 +1,10 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"text/template"
@@ -29,46 +31,137 @@ const (
 )
 
 var help = map[string]string{
-	"api":               "OpenAI compatible REST API (openai, localai).",
-	"apis":              "Aliases and endpoints for OpenAI compatible REST API.",
-	"http-proxy":        "HTTP proxy to use for API requests.",
-	"model":             "Default model (gpt-3.5-turbo, gpt-4, ggml-gpt4all-j...).",
-	"ask-model":         "Ask which model to use with an interactive prompt.",
-	"max-input-chars":   "Default character limit on input to model.",
-	"format":            "Ask for the response to be formatted as markdown unless otherwise set.",
-	"format-text":       "Text to append when using the -f flag.",
-	"role":              "System role to use.",
-	"roles":             "List of predefined system messages that can be used as roles.",
-	"list-roles":        "List the roles defined in your configuration file",
-	"prompt":            "Include the prompt from the arguments and stdin, truncate stdin to specified number of lines.",
-	"prompt-args":       "Include the prompt from the arguments in the response.",
-	"raw":               "Render output as raw text when connected to a TTY.",
-	"quiet":             "Quiet mode (hide the spinner while loading and stderr messages for success).",
-	"help":              "Show help and exit.",
-	"version":           "Show version and exit.",
-	"max-retries":       "Maximum number of times to retry API calls.",
-	"no-limit":          "Turn off the client-side limit on the size of the input into the model.",
-	"word-wrap":         "Wrap formatted output at specific width (default is 80)",
-	"max-tokens":        "Maximum number of tokens in response.",
-	"temp":              "Temperature (randomness) of results, from 0.0 to 2.0.",
-	"stop":              "Up to 4 sequences where the API will stop generating further tokens.",
-	"topp":              "TopP, an alternative to temperature that narrows response, from 0.0 to 1.0.",
-	"topk":              "TopK, only sample from the top K options for each subsequent token.",
-	"fanciness":         "Your desired level of fanciness.",
-	"status-text":       "Text to show while generating.",
-	"settings":          "Open settings in your $EDITOR.",
-	"dirs":              "Print the directories in which mods store its data.",
-	"reset-settings":    "Backup your old settings file and reset everything to the defaults.",
-	"continue":          "Continue from the last response or a given save title.",
-	"continue-last":     "Continue from the last response.",
-	"no-cache":          "Disables caching of the prompt/response.",
-	"title":             "Saves the current conversation with the given title.",
-	"list":              "Lists saved conversations.",
-	"delete":            "Deletes a saved conversation with the given title or ID.",
-	"delete-older-than": "Deletes all saved conversations older than the specified duration. Valid units are: " + strings.EnglishJoin(duration.ValidUnits(), true) + ".",
-	"show":              "Show a saved conversation with the given title or ID.",
-	"theme":             "Theme to use in the forms. Valid units are: 'charm', 'catppuccin', 'dracula', and 'base16'",
-	"show-last":         "Show the last saved conversation.",
+	"api":                  "OpenAI compatible REST API (openai, localai).",
+	"apis":                 "Aliases and endpoints for OpenAI compatible REST API.",
+	"http-proxy":           "HTTP proxy to use for API requests.",
+	"timeout":              "Give up on API requests, including streaming the response, after this long. 0 means no timeout. The timeout of an API in the settings takes precedence.",
+	"model":                "Default model (gpt-3.5-turbo, gpt-4, ggml-gpt4all-j...).",
+	"ask-model":            "Ask which model to use with an interactive prompt.",
+	"parallel":             "Send the prompt to each of the given comma separated models and show the responses side by side.",
+	"max-input-chars":      "Default character limit on input to model.",
+	"format":               "Ask for the response to be formatted as markdown unless otherwise set.",
+	"format-text":          "Text to append when using the -f flag.",
+	"role":                 "System role to use.",
+	"roles":                "List of predefined system messages that can be used as roles.",
+	"var":                  "Set a variable used in role templates, as key=value. Can be used multiple times.",
+	"template-left":        "Left delimiter of variables in role templates.",
+	"template-right":       "Right delimiter of variables in role templates.",
+	"list-roles":           "List the roles defined in your configuration file",
+	"list-models":          "List the models defined in your configuration file",
+	"prompt":               "Include the prompt from the arguments and stdin, truncate stdin to specified number of lines.",
+	"separator":            "Separator written between the prompt included with --prompt, --prompt-args or --prompt-range and the response.",
+	"prompt-range":         "Include the given range of stdin lines in the output, like 3:7, :10 or -5:.",
+	"prompt-args":          "Include the prompt from the arguments in the response.",
+	"raw":                  "Render output as raw text when connected to a TTY.",
+	"quiet":                "Quiet mode (hide the spinner while loading and stderr messages for success).",
+	"help":                 "Show help and exit.",
+	"version":              "Show version and exit.",
+	"version-json":         "Show version and build information as JSON and exit.",
+	"debug":                "Log the raw API requests and responses to mods_debug.log in the cache directory, with API keys redacted.",
+	"metrics-addr":         "Serve Prometheus metrics on /metrics at the given address, like :9090, while mods runs.",
+	"log-file":             "Append JSON lines about the requests, like when they start, retry, fail and complete, to the given file.",
+	"otlp-endpoint":        "Send OpenTelemetry traces of the requests to the given OTLP/HTTP endpoint. Defaults to $OTEL_EXPORTER_OTLP_ENDPOINT.",
+	"verbose":              "Print the API, model, prompt size and timings of the request to STDERR.",
+	"show-tokens":          "Print the tokens the request used to STDERR, estimated when the API doesn't tell.",
+	"profile":              "Use the settings of the given profile on top of the others.",
+	"profiles":             "Named groups of settings that override the others when used with --profile.",
+	"prompt-caching":       "Ask Anthropic to cache the system prompt and conversation, making follow ups cheaper.",
+	"max-retries":          "Maximum number of times to retry API calls.",
+	"max-retry-wait":       "Longest to wait before retrying a rate limited request, however long the API asks to wait.",
+	"no-limit":             "Turn off the client-side limit on the size of the input into the model.",
+	"truncation-keep-last": "Number of the most recent user and assistant message pairs always kept when a conversation is too long.",
+	"truncation-strategy":  "How to cut prompts over the input limit: tail, middle (keeps the start and end), or summarize (asks the model for a summary).",
+	"word-wrap":            "Wrap formatted output at specific width (default is 80)",
+	"max-tokens":           "Maximum number of tokens in response.",
+	"max-cost":             "Refuse to send requests that would take the cost of this run over this many USD. Needs the model's cost-per-input-token and cost-per-output-token.",
+	"temp":                 "Temperature (randomness) of results, from 0.0 to 2.0.",
+	"times":                "Send the prompt this many times and print all the responses.",
+	"watch":                "Run the prompt again each time new input is piped in, or every --watch-interval, replacing the previous response.",
+	"watch-interval":       "Run the --watch prompt again after this long, instead of waiting for new input.",
+	"watch-save":           "Save each --watch response as its own conversation.",
+	"temp-vary":            "Use a random temperature between 0.0 and 2.0 for each of the --times runs.",
+	"stop":                 "Up to 4 sequences where the API will stop generating further tokens.",
+	"topp":                 "TopP, an alternative to temperature that narrows response, from 0.0 to 1.0.",
+	"topk":                 "TopK, only sample from the top K options for each subsequent token.",
+	"thinking-budget":      "Let Anthropic models think for up to this many tokens before answering. Overrides the thinking-budget of the model in the settings.",
+	"grounding":            "Let Google and Vertex AI models search the web before answering, citing their sources with --format.",
+	"logprobs":             "Write the log probabilities of the response tokens to a JSON file next to --output (OpenAI compatible APIs only).",
+	"top-logprobs":         "Number of most likely tokens to include at each position with --logprobs, from 0 to 20.",
+	"seed":                 "Seed for reproducible responses on supporting models, -1 to disable.",
+	"presence-penalty":     "Penalize tokens that already appeared, from 0.0 to 2.0, or -1 to disable (OpenAI compatible APIs only).",
+	"frequency-penalty":    "Penalize tokens by how often they appeared, from 0.0 to 2.0, or -1 to disable (OpenAI compatible APIs only).",
+	"fanciness":            "Your desired level of fanciness.",
+	"status-text":          "Text to show while generating.",
+	"settings":             "Open settings in your $EDITOR.",
+	"config":               "Read the settings from the given file instead of the default one.",
+	"dirs":                 "Print the directories in which mods store its data.",
+	"reset-settings":       "Backup your old settings file and reset everything to the defaults.",
+	"continue":             "Continue from the last response or a given save title.",
+	"continue-last":        "Continue from the last response.",
+	"no-cache":             "Disables caching of the prompt/response.",
+	"cache-responses":      "Answer identical requests from a cache of previous responses, for --cache-ttl or an hour. Always on with a temperature of 0.",
+	"title":                "Saves the current conversation with the given title.",
+	"conversation-id":      "Continue the conversation with the given SHA-1, or start it if there is none.",
+	"list":                 "Lists saved conversations.",
+	"tag":                  "Comma separated tags to add to the saved conversation.",
+	"filter-tags":          "Only list conversations that have all of the given comma separated tags.",
+	"sort":                 "Sort the listed conversations by updated, created, title or model.",
+	"sort-asc":             "Sort the listed conversations in ascending order.",
+	"sort-desc":            "Sort the listed conversations in descending order.",
+	"paginate":             "List the conversations a page at a time.",
+	"page":                 "List the given page of conversations, counting from 1.",
+	"page-size":            "How many conversations are listed per page.",
+	"next-page":            "List the page after the one listed last.",
+	"prev-page":            "List the page before the one listed last.",
+	"filter-model":         "Only list conversations that used the given model.",
+	"filter-api":           "Only list conversations that used the given API.",
+	"search":               "Lists saved conversations whose title or content contains the given text.",
+	"rename":               "Rename the conversation given by --show, --show-last, --continue or --continue-last.",
+	"fork":                 "Copy the conversation for the given title or SHA-1 into a new one, optionally named with --title.",
+	"migrate-model":        "Rename a model in all saved conversations, given as old=new.",
+	"backup-db":            "Write a copy of the conversation database to the given path, which must not exist.",
+	"vacuum":               "Shrink the conversation database, on its own or after deleting conversations.",
+	"yes":                  "Don't ask for confirmation before changing saved conversations.",
+	"stats":                "Show statistics about your saved conversations. Use --raw for JSON.",
+	"import":               "Import a conversation from a markdown or json file written by --export.",
+	"export":               "Export the conversation given by --show or --show-last to STDOUT as markdown or json.",
+	"delete":               "Deletes a saved conversation with the given title or ID.",
+	"archive":              "Archive the saved conversation with the given title or ID, hiding it from --list.",
+	"unarchive":            "Unarchive the saved conversation with the given title or ID.",
+	"pin":                  "Pin the saved conversation with the given title or ID to the top of --list.",
+	"unpin":                "Unpin the saved conversation with the given title or ID.",
+	"list-archived":        "Lists archived conversations.",
+	"include-archived":     "Also delete archived conversations with --delete-older-than.",
+	"cache-ttl":            "Delete saved conversations automatically once they are older than this. 0 means they are kept forever.",
+	"cache-max-size":       "Delete the oldest saved conversations once together they take more than this many bytes. 0 means no limit.",
+	"cache-max-count":      "Delete the oldest saved conversations once there are more than this many. 0 means no limit.",
+	"delete-older-than":    "Deletes all saved conversations older than the specified duration. Valid units are: " + strings.EnglishJoin(duration.ValidUnits(), true) + ".",
+	"show":                 "Show a saved conversation with the given title or ID.",
+	"theme":                "Theme to use in the forms. Valid units are: 'charm', 'catppuccin', 'dracula', and 'base16'",
+	"show-last":            "Show the last saved conversation.",
+	"dry-run":              "Print the request that would be sent as JSON, without calling the API.",
+	"estimate-tokens":      "Print an approximate token count for the prompt, without calling the API.",
+	"no-stream":            "Disable response streaming and wait for the complete answer.",
+	"system":               "System prompt to use. When used with --role, it is appended to the role's messages.",
+	"append":               "Append the response to the --output file instead of overwriting it.",
+	"append-separator":     "Separator written between responses when using --append.",
+	"copy":                 "Copy the response to the clipboard.",
+	"output":               "Write the raw response to the given file.",
+	"file":                 "Include the contents of the given file in the prompt. Can be repeated.",
+	"prompt-file":          "Read the prompt from the given file instead of the arguments. Use - to read it from STDIN.",
+	"force":                "Include files given with --file even if they don't look like text.",
+	"exec-timeout":         "Kill the --exec command if it runs for longer than this.",
+	"exec":                 "Run the given command and include its output in the prompt instead of STDIN.",
+	"url":                  "Fetch the given URL and include its text in the prompt. Can be repeated.",
+	"image":                "Attach an image file to the prompt, for vision-capable models. Can be used multiple times.",
+
+	// circuit breaker
+	"circuit-breaker-timeout": "How long to stop sending requests to an API after max-retries failed ones in a row.",
+
+	// reasoning models
+	"max-completion-tokens": "Maximum number of tokens in response, reasoning included, for the OpenAI o-series models.",
+	"completions":           "Ask for this many answers, and pick which one to save.",
+	"schema":                "Path of a JSON Schema the response must follow (OpenAI only).",
 }
 
 // Model represents the LLM model used in the API call.
@@ -78,6 +171,19 @@ type Model struct {
 	MaxChars int      `yaml:"max-input-chars"`
 	Aliases  []string `yaml:"aliases"`
 	Fallback string   `yaml:"fallback"`
+
+	// ThinkingBudget is how many tokens Anthropic models may spend thinking
+	// before they answer. 0 turns extended thinking off.
+	ThinkingBudget int `yaml:"thinking-budget"`
+
+	// Grounding lets Google and Vertex AI models search the web before they
+	// answer.
+	Grounding bool `yaml:"grounding"`
+
+	// CostPerInputToken and CostPerOutputToken are the prices of the model,
+	// in USD, used by --max-cost.
+	CostPerInputToken  float64 `yaml:"cost-per-input-token"`
+	CostPerOutputToken float64 `yaml:"cost-per-output-token"`
 }
 
 // API represents an API endpoint and its models.
@@ -86,10 +192,38 @@ type API struct {
 	APIKey    string           `yaml:"api-key"`
 	APIKeyEnv string           `yaml:"api-key-env"`
 	APIKeyCmd string           `yaml:"api-key-cmd"`
+	OPItem    string           `yaml:"op-item"`
 	Version   string           `yaml:"version"`
 	BaseURL   string           `yaml:"base-url"`
 	Models    map[string]Model `yaml:"models"`
 	User      string           `yaml:"user"`
+	Timeout   time.Duration    `yaml:"timeout"`
+
+	// Project and Location route Vertex AI requests.
+	Project  string `yaml:"project"`
+	Location string `yaml:"location"`
+
+	// Region and Profile select the AWS region and credentials for Bedrock
+	// and api-key-secret-arn.
+	Region  string `yaml:"region"`
+	Profile string `yaml:"profile"`
+
+	// SiteURL and SiteName identify your app to OpenRouter.
+	SiteURL  string `yaml:"site-url"`
+	SiteName string `yaml:"site-name"`
+
+	// APIKeySecretARN reads the key from an AWS Secrets Manager secret.
+	APIKeySecretARN string `yaml:"api-key-secret-arn"`
+
+	// GitHubEnterpriseURL points Copilot to a GitHub Enterprise instance.
+	GitHubEnterpriseURL string `yaml:"github-enterprise-url"`
+
+	// SafePrompt asks Mistral to prepend its safety prompt.
+	SafePrompt bool `yaml:"safe-prompt"`
+
+	// SafetySettings are the Gemini blocking thresholds by harm category,
+	// like HARM_CATEGORY_DANGEROUS_CONTENT: BLOCK_NONE.
+	SafetySettings map[string]string `yaml:"safety-settings"`
 }
 
 // APIs is a type alias to allow custom YAML decoding.
@@ -131,58 +265,185 @@ func (ft *FormatText) UnmarshalYAML(unmarshal func(interface{}) error) error {
 
 // Config holds the main configuration and is mapped to the YAML settings file.
 type Config struct {
-	Model             string     `yaml:"default-model" env:"MODEL"`
-	Format            bool       `yaml:"format" env:"FORMAT"`
-	FormatText        FormatText `yaml:"format-text"`
-	FormatAs          string     `yaml:"format-as" env:"FORMAT_AS"`
-	Raw               bool       `yaml:"raw" env:"RAW"`
-	Quiet             bool       `yaml:"quiet" env:"QUIET"`
-	MaxTokens         int        `yaml:"max-tokens" env:"MAX_TOKENS"`
-	MaxInputChars     int        `yaml:"max-input-chars" env:"MAX_INPUT_CHARS"`
-	Temperature       float32    `yaml:"temp" env:"TEMP"`
-	Stop              []string   `yaml:"stop" env:"STOP"`
-	TopP              float32    `yaml:"topp" env:"TOPP"`
-	TopK              int        `yaml:"topk" env:"TOPK"`
-	NoLimit           bool       `yaml:"no-limit" env:"NO_LIMIT"`
-	CachePath         string     `yaml:"cache-path" env:"CACHE_PATH"`
-	NoCache           bool       `yaml:"no-cache" env:"NO_CACHE"`
-	IncludePromptArgs bool       `yaml:"include-prompt-args" env:"INCLUDE_PROMPT_ARGS"`
-	IncludePrompt     int        `yaml:"include-prompt" env:"INCLUDE_PROMPT"`
-	MaxRetries        int        `yaml:"max-retries" env:"MAX_RETRIES"`
-	WordWrap          int        `yaml:"word-wrap" env:"WORD_WRAP"`
-	Fanciness         uint       `yaml:"fanciness" env:"FANCINESS"`
-	StatusText        string     `yaml:"status-text" env:"STATUS_TEXT"`
-	HTTPProxy         string     `yaml:"http-proxy" env:"HTTP_PROXY"`
-	APIs              APIs       `yaml:"apis"`
-	System            string     `yaml:"system"`
-	Role              string     `yaml:"role" env:"ROLE"`
-	AskModel          bool
-	API               string
-	Models            map[string]Model
-	Roles             map[string][]string
-	ShowHelp          bool
-	ResetSettings     bool
-	Prefix            string
-	Version           bool
-	Settings          bool
-	Dirs              bool
-	Theme             string
-	SettingsPath      string
-	ContinueLast      bool
-	Continue          string
-	Title             string
-	ShowLast          bool
-	Show              string
-	List              bool
-	ListRoles         bool
-	Delete            string
-	DeleteOlderThan   time.Duration
-	User              string
+	Model              string               `yaml:"default-model" env:"MODEL"`
+	Format             bool                 `yaml:"format" env:"FORMAT"`
+	FormatText         FormatText           `yaml:"format-text"`
+	FormatAs           string               `yaml:"format-as" env:"FORMAT_AS"`
+	Raw                bool                 `yaml:"raw" env:"RAW"`
+	Quiet              bool                 `yaml:"quiet" env:"QUIET"`
+	MaxTokens          int                  `yaml:"max-tokens" env:"MAX_TOKENS"`
+	MaxCost            float64              `yaml:"max-cost" env:"MAX_COST"`
+	OTLPEndpoint       string               `yaml:"otlp-endpoint" env:"OTLP_ENDPOINT"`
+	LogFile            string               `yaml:"log-file" env:"LOG_FILE"`
+	MaxInputChars      int                  `yaml:"max-input-chars" env:"MAX_INPUT_CHARS"`
+	Temperature        float32              `yaml:"temp" env:"TEMP"`
+	Stop               []string             `yaml:"stop" env:"STOP"`
+	TopP               float32              `yaml:"topp" env:"TOPP"`
+	TopK               int                  `yaml:"topk" env:"TOPK"`
+	ThinkingBudget     int                  `yaml:"thinking-budget" env:"THINKING_BUDGET"`
+	Grounding          bool                 `yaml:"grounding" env:"GROUNDING"`
+	Seed               int64                `yaml:"seed" env:"SEED"`
+	PresencePenalty    float32              `yaml:"presence-penalty" env:"PRESENCE_PENALTY"`
+	FrequencyPenalty   float32              `yaml:"frequency-penalty" env:"FREQUENCY_PENALTY"`
+	NoLimit            bool                 `yaml:"no-limit" env:"NO_LIMIT"`
+	TruncationStrategy string               `yaml:"truncation-strategy" env:"TRUNCATION_STRATEGY"`
+	TruncationKeepLast int                  `yaml:"truncation-keep-last" env:"TRUNCATION_KEEP_LAST"`
+	CachePath          string               `yaml:"cache-path" env:"CACHE_PATH"`
+	NoCache            bool                 `yaml:"no-cache" env:"NO_CACHE"`
+	CacheResponses     bool                 `yaml:"cache-responses" env:"CACHE_RESPONSES"`
+	CacheTTL           time.Duration        `yaml:"cache-ttl" env:"CACHE_TTL"`
+	CacheMaxSize       int64                `yaml:"cache-max-size" env:"CACHE_MAX_SIZE"`
+	CacheMaxCount      int                  `yaml:"cache-max-count" env:"CACHE_MAX_COUNT"`
+	IncludePromptArgs  bool                 `yaml:"include-prompt-args" env:"INCLUDE_PROMPT_ARGS"`
+	IncludePrompt      int                  `yaml:"include-prompt" env:"INCLUDE_PROMPT"`
+	IncludePromptRange string               `yaml:"include-prompt-range" env:"INCLUDE_PROMPT_RANGE"`
+	Separator          string               `yaml:"separator" env:"SEPARATOR"`
+	MaxRetries         int                  `yaml:"max-retries" env:"MAX_RETRIES"`
+	MaxRetryWait       time.Duration        `yaml:"max-retry-wait" env:"MAX_RETRY_WAIT"`
+	WordWrap           int                  `yaml:"word-wrap" env:"WORD_WRAP"`
+	PageSize           int                  `yaml:"page-size" env:"PAGE_SIZE"`
+	Fanciness          uint                 `yaml:"fanciness" env:"FANCINESS"`
+	StatusText         string               `yaml:"status-text" env:"STATUS_TEXT"`
+	HTTPProxy          string               `yaml:"http-proxy" env:"HTTP_PROXY"`
+	Timeout            time.Duration        `yaml:"timeout" env:"TIMEOUT"`
+	ExecTimeout        time.Duration        `yaml:"exec-timeout" env:"EXEC_TIMEOUT"`
+	NoStream           bool                 `yaml:"no-stream" env:"NO_STREAM"`
+	PromptCaching      bool                 `yaml:"prompt-caching" env:"PROMPT_CACHING"`
+	APIs               APIs                 `yaml:"apis"`
+	Profiles           map[string]yaml.Node `yaml:"profiles"`
+	System             string               `yaml:"system" env:"SYSTEM"`
+	Role               string               `yaml:"role" env:"ROLE"`
+	TemplateLeft       string               `yaml:"template-left" env:"TEMPLATE_LEFT"`
+	TemplateRight      string               `yaml:"template-right" env:"TEMPLATE_RIGHT"`
+	AskModel           bool
+	Parallel           []string
+	Times              int
+	TempVary           bool
+	ContextWindow      int
+	Watch              bool
+	WatchInterval      time.Duration
+	WatchSave          bool
+	API                string
+	Models             map[string]Model
+	Roles              map[string][]string
+	Vars               map[string]string
+	ShowHelp           bool
+	ResetSettings      bool
+	Prefix             string
+	Version            bool
+	VersionJSON        bool
+	Profile            string
+	ConfigPath         string
+	Verbose            bool
+	ShowTokens         bool
+	Debug              bool
+	MetricsAddr        string
+	Settings           bool
+	Dirs               bool
+	Theme              string
+	SettingsPath       string
+	ContinueLast       bool
+	Continue           string
+	Title              string
+	ConversationID     string
+	ShowLast           bool
+	Show               string
+	List               bool
+	Search             string
+	Tags               []string
+	FilterTags         []string
+	FilterModel        string
+	Sort               string
+	SortAsc            bool
+	SortDesc           bool
+	Paginate           bool
+	Page               int
+	NextPage           bool
+	PrevPage           bool
+	FilterAPI          string
+	Export             string
+	Import             string
+	Rename             string
+	Fork               string
+	Stats              bool
+	MigrateModel       string
+	BackupDB           string
+	Vacuum             bool
+	Yes                bool
+	ListRoles          bool
+	ListModels         bool
+	DryRun             bool
+	EstimateTokens     bool
+	Delete             string
+	DeleteOlderThan    time.Duration
+	Archive            string
+	Unarchive          string
+	Pin                string
+	Unpin              string
+	ListArchived       bool
+	IncludeArchived    bool
+	User               string
+	OutputFile         string
+	Append             bool
+	AppendSeparator    string
+	Copy               bool
+	LogProbs           bool
+	TopLogProbs        int
+	Images             []string
+	URLs               []string
+	Files              []string
+	PromptFile         string
+	ExecCmd            string
+	Force              bool
+
+	// CircuitBreakerTimeout is how long an API that keeps failing is left
+	// alone.
+	CircuitBreakerTimeout time.Duration `yaml:"circuit-breaker-timeout" env:"CIRCUIT_BREAKER_TIMEOUT"`
+
+	// MaxCompletionTokens limits the response of the OpenAI o-series models,
+	// reasoning included, as they don't take max-tokens.
+	MaxCompletionTokens int `yaml:"max-completion-tokens" env:"MAX_COMPLETION_TOKENS"`
+
+	// Completions is how many answers to ask for.
+	Completions int
+
+	// ResponseSchema is the path of a JSON Schema the response must follow.
+	ResponseSchema string
 
 	cacheReadFromID, cacheWriteToID, cacheWriteToTitle string
+	// pages is how many pages --list has when paginating.
+	pages int
 }
 
-func ensureConfig() (Config, error) {
+// expandRole fills the variables in a role message with the values given
+// with --var. Messages are left untouched if no variables were given.
+func expandRole(msg string, vars map[string]string, left, right string) (string, error) {
+	if len(vars) == 0 {
+		return msg, nil
+	}
+	if left == "" {
+		left = "{{"
+	}
+	if right == "" {
+		right = "}}"
+	}
+	tmpl, err := template.New("role").
+		Delims(left, right).
+		Option("missingkey=error").
+		Parse(msg)
+	if err != nil {
+		return "", fmt.Errorf("expandRole: %w", err)
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, vars); err != nil {
+		return "", fmt.Errorf("expandRole: %w", err)
+	}
+	return b.String(), nil
+}
+
+// ensureConfig loads the settings from path, or from the default settings
+// file if path is empty, applying the given profile if any.
+func ensureConfig(path, profile string) (Config, error) {
 	var c Config
 	sp, err := xdg.ConfigFile(filepath.Join("mods", "mods.yml"))
 	if err != nil {
@@ -198,13 +459,38 @@ func ensureConfig() (Config, error) {
 	if dirErr := writeConfigFile(sp); dirErr != nil {
 		return c, dirErr
 	}
-	content, err := os.ReadFile(sp)
+	if path != "" {
+		c.SettingsPath = path
+	}
+	content, err := os.ReadFile(c.SettingsPath)
 	if err != nil {
 		return c, modsError{err, "Could not read settings file."}
 	}
+	// settings files written before --seed existed don't have it, and 0 is
+	// a valid seed.
+	c.Seed = -1
+	// nor --separator, and an empty separator is valid too.
+	c.Separator = defaultConfig().Separator
+	// nor truncation-keep-last, and keeping no turns is valid too.
+	c.TruncationKeepLast = defaultConfig().TruncationKeepLast
 	if err := yaml.Unmarshal(content, &c); err != nil {
 		return c, modsError{err, "Could not parse settings file."}
 	}
+	if profile != "" {
+		if err := applyProfile(&c, profile); err != nil {
+			return c, modsError{err, "Could not load the profile."}
+		}
+		c.Profile = profile
+	}
+
+	if wd, err := os.Getwd(); err == nil {
+		home, _ := os.UserHomeDir()
+		if path := findModsrc(wd, home); path != "" {
+			if err := applyModsrc(&c, path); err != nil {
+				return c, modsError{err, "Could not load the project settings."}
+			}
+		}
+	}
 	ms := make(map[string]Model)
 	for _, api := range c.APIs {
 		for mk, mv := range api.Models {
@@ -240,6 +526,9 @@ func ensureConfig() (Config, error) {
 	if c.WordWrap == 0 {
 		c.WordWrap = 80
 	}
+	if c.PageSize == 0 {
+		c.PageSize = 20
+	}
 
 	return c, nil
 }
@@ -254,14 +543,18 @@ func writeConfigFile(path string) error {
 }
 
 func createConfigFile(path string) error {
-	tmpl := template.Must(template.New("config").Parse(configTemplate))
-
 	f, err := os.Create(path)
 	if err != nil {
 		return modsError{err, "Could not create configuration file."}
 	}
 	defer func() { _ = f.Close() }()
 
+	return writeConfigTemplate(f)
+}
+
+// writeConfigTemplate writes the default settings file to w.
+func writeConfigTemplate(w io.Writer) error {
+	tmpl := template.Must(template.New("config").Parse(configTemplate))
 	m := struct {
 		Config Config
 		Help   map[string]string
@@ -269,7 +562,7 @@ func createConfigFile(path string) error {
 		Config: defaultConfig(),
 		Help:   help,
 	}
-	if err := tmpl.Execute(f, m); err != nil {
+	if err := tmpl.Execute(w, m); err != nil {
 		return modsError{err, "Could not render template."}
 	}
 	return nil
@@ -277,7 +570,10 @@ func createConfigFile(path string) error {
 
 func defaultConfig() Config {
 	return Config{
-		FormatAs: "markdown",
+		FormatAs:           "markdown",
+		TruncationStrategy: truncateTail,
+		TruncationKeepLast: 1,
+		Separator:          "\n\n",
 		FormatText: FormatText{
 			"markdown": defaultMarkdownFormatText,
 			"json":     defaultJSONFormatText,
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/aymanbagabas/go-udiff"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// newConfigCmd returns the hidden config command, which works on the
+// settings file.
+func newConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:    "config",
+		Short:  "Works on the settings file",
+		Hidden: true,
+	}
+
+	diff := &cobra.Command{
+		Use:                   "diff",
+		Short:                 "Shows how the settings differ from the defaults",
+		SilenceUsage:          true,
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.NoArgs,
+		RunE: func(*cobra.Command, []string) error {
+			content, err := os.ReadFile(config.SettingsPath)
+			if err != nil {
+				return modsError{err, "Could not read settings file."}
+			}
+			diff, err := configDiff(config.SettingsPath, content)
+			if err != nil {
+				return modsError{err, "Could not compare the settings."}
+			}
+			if isOutputTTY() && !config.Raw {
+				diff = colorDiff(diff)
+			}
+			fmt.Print(diff)
+			return nil
+		},
+	}
+	diff.Flags().BoolVarP(&config.Raw, "raw", "r", config.Raw, stdoutStyles().FlagDesc.Render(help["raw"]))
+
+	set := &cobra.Command{
+		Use:                   "set key=value...",
+		Short:                 "Changes settings, keeping the rest of the file as is",
+		SilenceUsage:          true,
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.MinimumNArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			content, err := os.ReadFile(config.SettingsPath)
+			if err != nil {
+				return modsError{err, "Could not read settings file."}
+			}
+			updated, err := setConfigValues(content, args)
+			if err != nil {
+				return modsError{err, "Could not change the settings."}
+			}
+			if err := os.WriteFile(config.SettingsPath+".bak", content, 0o600); err != nil { //nolint:mnd
+				return modsError{err, "Couldn't backup config file."}
+			}
+			if err := os.WriteFile(config.SettingsPath, updated, 0o600); err != nil { //nolint:mnd
+				return modsError{err, "Couldn't write config file."}
+			}
+			return nil
+		},
+	}
+
+	cmd.AddCommand(diff, set)
+	return cmd
+}
+
+// setConfigValues sets each of the given key=value pairs in the settings
+// file content. Keys are paths separated by dots, like apis.openai.api-key,
+// and values are YAML. Comments and the rest of the file are kept.
+func setConfigValues(content []byte, pairs []string) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("setConfigValues: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{
+			Kind:    yaml.DocumentNode,
+			Content: []*yaml.Node{{Kind: yaml.MappingNode}},
+		}
+	}
+
+	keys := configKeys()
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, newUserErrorf("Settings must be given as key=value, got %q.", pair)
+		}
+		path := strings.Split(key, ".")
+		if !keys[path[0]] {
+			return nil, newUserErrorf("Unknown setting %q.", path[0])
+		}
+		var v yaml.Node
+		if err := yaml.Unmarshal([]byte(value), &v); err != nil {
+			return nil, newUserErrorf("Invalid value for %s: %s", key, err)
+		}
+		if err := setConfigNode(doc.Content[0], path, valueNode(&v)); err != nil {
+			return nil, newUserErrorf("Could not set %s: %s", key, err)
+		}
+	}
+
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2) //nolint:mnd
+	if err := enc.Encode(&doc); err != nil {
+		return nil, fmt.Errorf("setConfigValues: %w", err)
+	}
+	var c Config
+	if err := yaml.Unmarshal(b.Bytes(), &c); err != nil {
+		return nil, newUserErrorf("The new settings are invalid: %s", err)
+	}
+	return b.Bytes(), nil
+}
+
+// valueNode returns the node of a parsed value, which is a plain empty
+// scalar for empty values.
+func valueNode(doc *yaml.Node) *yaml.Node {
+	if len(doc.Content) == 0 {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}
+	}
+	return doc.Content[0]
+}
+
+// setConfigNode sets the value at path in the given mapping, creating the
+// missing keys along the way.
+func setConfigNode(mapping *yaml.Node, path []string, value *yaml.Node) error {
+	if mapping.Kind != yaml.MappingNode {
+		return errors.New("the settings file is not a map")
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != path[0] {
+			continue
+		}
+		if len(path) > 1 {
+			if mapping.Content[i+1].Kind != yaml.MappingNode {
+				return fmt.Errorf("%s is not a map", path[0])
+			}
+			return setConfigNode(mapping.Content[i+1], path[1:], value)
+		}
+		old := mapping.Content[i+1]
+		value.HeadComment, value.LineComment, value.FootComment = old.HeadComment, old.LineComment, old.FootComment
+		mapping.Content[i+1] = value
+		return nil
+	}
+
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: path[0]}
+	if len(path) == 1 {
+		mapping.Content = append(mapping.Content, key, value)
+		return nil
+	}
+	child := &yaml.Node{Kind: yaml.MappingNode}
+	mapping.Content = append(mapping.Content, key, child)
+	return setConfigNode(child, path[1:], value)
+}
+
+// configKeys returns the top level keys of the settings file.
+func configKeys() map[string]bool {
+	keys := map[string]bool{}
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name != "" && name != "-" {
+			keys[name] = true
+		}
+	}
+	return keys
+}
+
+// configDiff returns a unified diff from the default settings to the given
+// settings file content. Both are normalized first, so only the settings
+// that changed show up, and not comments or formatting.
+func configDiff(path string, content []byte) (string, error) {
+	var defaults bytes.Buffer
+	if err := writeConfigTemplate(&defaults); err != nil {
+		return "", err
+	}
+	want, err := normalizeConfig(defaults.Bytes())
+	if err != nil {
+		return "", err
+	}
+	got, err := normalizeConfig(content)
+	if err != nil {
+		return "", err
+	}
+	return udiff.Unified("defaults", path, want, got), nil
+}
+
+func normalizeConfig(content []byte) (string, error) {
+	var c Config
+	// same as ensureConfig.
+	c.Seed = -1
+	if err := yaml.Unmarshal(content, &c); err != nil {
+		return "", fmt.Errorf("normalizeConfig: %w", err)
+	}
+	bts, err := yaml.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("normalizeConfig: %w", err)
+	}
+	return string(bts), nil
+}
+
+// colorDiff shows additions in green and removals in red.
+func colorDiff(diff string) string {
+	r := stdoutRenderer()
+	added := r.NewStyle().Foreground(lipgloss.Color("#00AF87"))
+	removed := r.NewStyle().Foreground(lipgloss.Color("#FF5F87"))
+	lines := strings.SplitAfter(diff, "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "+"):
+			lines[i] = added.Render(strings.TrimSuffix(line, "\n")) + "\n"
+		case strings.HasPrefix(line, "-"):
+			lines[i] = removed.Render(strings.TrimSuffix(line, "\n")) + "\n"
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+// isConfigCmd reports whether args run one of the config subcommands, so a
+// prompt that starts with "config" is still sent as a prompt.
+//
+//nolint:mnd
+func isConfigCmd(args []string) bool {
+	if len(args) < 3 || args[1] != "config" {
+		return false
+	}
+	switch args[2] {
+	case "diff":
+		return len(args) == 3
+	case "set":
+		return len(args) > 3
+	default:
+		return false
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestConfigDiff(t *testing.T) {
+	var defaults bytes.Buffer
+	require.NoError(t, writeConfigTemplate(&defaults))
+
+	t.Run("defaults", func(t *testing.T) {
+		diff, err := configDiff("mods.yml", defaults.Bytes())
+		require.NoError(t, err)
+		require.Empty(t, diff)
+	})
+
+	t.Run("changed temperature", func(t *testing.T) {
+		content := strings.Replace(defaults.String(), "\ntemp: 1.0\n", "\ntemp: 0.3\n", 1)
+		diff, err := configDiff("mods.yml", []byte(content))
+		require.NoError(t, err)
+		require.Contains(t, diff, "--- defaults\n+++ mods.yml\n")
+		require.Contains(t, diff, "\n-temp: 1\n+temp: 0.3\n")
+		require.Equal(t, 1, strings.Count(diff, "\n@@ "), diff)
+	})
+}
+
+func TestSetConfigValues(t *testing.T) {
+	content := []byte(`# the model
+default-model: gpt-4o
+temp: 1.0 # sampling
+apis:
+  openai:
+    base-url: https://api.openai.com/v1
+    api-key:
+`)
+
+	t.Run("round trip", func(t *testing.T) {
+		updated, err := setConfigValues(content, []string{
+			"default-model=gpt-4o-mini",
+			"temp=0.7",
+			"apis.openai.api-key=sk-xxx",
+			"apis.ollama.base-url=http://localhost:11434/api",
+		})
+		require.NoError(t, err)
+		require.Equal(t, `# the model
+default-model: gpt-4o-mini
+temp: 0.7 # sampling
+apis:
+  openai:
+    base-url: https://api.openai.com/v1
+    api-key: sk-xxx
+  ollama:
+    base-url: http://localhost:11434/api
+`, string(updated))
+
+		var cfg Config
+		require.NoError(t, yaml.Unmarshal(updated, &cfg))
+		require.Equal(t, "gpt-4o-mini", cfg.Model)
+		require.Equal(t, float32(0.7), cfg.Temperature)
+		require.Equal(t, "sk-xxx", cfg.APIs[0].APIKey)
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		_, err := setConfigValues(content, []string{"model=gpt-4o"})
+		require.ErrorContains(t, err, `Unknown setting "model"`)
+	})
+
+	t.Run("not key=value", func(t *testing.T) {
+		_, err := setConfigValues(content, []string{"temp"})
+		require.ErrorContains(t, err, "key=value")
+	})
+
+	t.Run("invalid value", func(t *testing.T) {
+		_, err := setConfigValues(content, []string{"temp=hot"})
+		require.ErrorContains(t, err, "The new settings are invalid")
+	})
+
+	t.Run("not a map", func(t *testing.T) {
+		_, err := setConfigValues(content, []string{"temp.value=1"})
+		require.ErrorContains(t, err, "temp is not a map")
+	})
+}
+
+func TestIsConfigCmd(t *testing.T) {
+	require.True(t, isConfigCmd([]string{"mods", "config", "diff"}))
+	require.True(t, isConfigCmd([]string{"mods", "config", "set", "model=gpt-4o"}))
+	require.False(t, isConfigCmd([]string{"mods", "config", "how", "do", "I", "write", "nginx"}))
+	require.False(t, isConfigCmd([]string{"mods", "config", "diff", "these", "files"}))
+	require.False(t, isConfigCmd([]string{"mods", "config", "set"}))
+	require.False(t, isConfigCmd([]string{"mods", "config"}))
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// applyProfile merges the settings of the named profile into c, the profile
+// taking precedence. APIs in the profile replace the ones with the same name.
+func applyProfile(c *Config, name string) error {
+	node, ok := c.Profiles[name]
+	if !ok {
+		return newUserErrorf(
+			"Profile %q is not in the settings file. Your profiles are: %s",
+			name,
+			strings.Join(profileNames(c.Profiles), ", "),
+		)
+	}
+
+	apis := c.APIs
+	c.APIs = nil
+	if err := node.Decode(c); err != nil {
+		return fmt.Errorf("applyProfile: %w", err)
+	}
+	for _, api := range apis {
+		if !hasAPI(c.APIs, api.Name) {
+			c.APIs = append(c.APIs, api)
+		}
+	}
+	return nil
+}
+
+func hasAPI(apis APIs, name string) bool {
+	for _, api := range apis {
+		if api.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func profileNames(profiles map[string]yaml.Node) []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// flagValue returns the value given to the --name flag in args. It is used
+// for flags that change how the settings are loaded, which happens before
+// the flags are parsed.
+func flagValue(args []string, name string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if arg == "--"+name && i+1 < len(args) {
+			return args[i+1]
+		}
+		if value, ok := strings.CutPrefix(arg, "--"+name+"="); ok {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const profilesConfig = `
+default-model: gpt-4o
+temp: 1.0
+role: default
+apis:
+  openai:
+    base-url: https://api.openai.com/v1
+  ollama:
+    base-url: http://localhost:11434/api
+profiles:
+  work:
+    default-model: claude
+    temp: 0.2
+    role: reviewer
+    apis:
+      openai:
+        base-url: https://openai.example.com/v1
+`
+
+func TestApplyProfile(t *testing.T) {
+	load := func(t *testing.T) Config {
+		t.Helper()
+		var c Config
+		require.NoError(t, yaml.Unmarshal([]byte(profilesConfig), &c))
+		return c
+	}
+
+	t.Run("overrides", func(t *testing.T) {
+		c := load(t)
+		require.NoError(t, applyProfile(&c, "work"))
+		require.Equal(t, "claude", c.Model)
+		require.Equal(t, float32(0.2), c.Temperature)
+		require.Equal(t, "reviewer", c.Role)
+		require.Equal(t, APIs{
+			{Name: "openai", BaseURL: "https://openai.example.com/v1"},
+			{Name: "ollama", BaseURL: "http://localhost:11434/api"},
+		}, c.APIs)
+	})
+
+	t.Run("unknown profile", func(t *testing.T) {
+		c := load(t)
+		err := applyProfile(&c, "home")
+		require.ErrorContains(t, err, `Profile "home" is not in the settings file`)
+		require.ErrorContains(t, err, "work")
+		require.Equal(t, "gpt-4o", c.Model)
+	})
+}
+
+func TestFlagValue(t *testing.T) {
+	require.Equal(t, "work", flagValue([]string{"--profile", "work", "hi"}, "profile"))
+	require.Equal(t, "work", flagValue([]string{"-q", "--profile=work"}, "profile"))
+	require.Equal(t, "", flagValue([]string{"--profile"}, "profile"))
+	require.Equal(t, "", flagValue([]string{"--", "--profile", "work"}, "profile"))
+	require.Equal(t, "", flagValue([]string{"--profiles", "work"}, "profile"))
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/adrg/xdg"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v3"
 )
@@ -24,3 +27,90 @@ func TestConfig(t *testing.T) {
 		}), cfg.FormatText)
 	})
 }
+
+func TestExpandRole(t *testing.T) {
+	vars := map[string]string{"Language": "Go"}
+
+	t.Run("no vars", func(t *testing.T) {
+		msg, err := expandRole("You are a {{.Language}} expert", nil, "", "")
+		require.NoError(t, err)
+		require.Equal(t, "You are a {{.Language}} expert", msg)
+	})
+
+	t.Run("default delimiters", func(t *testing.T) {
+		msg, err := expandRole("You are a {{.Language}} expert", vars, "", "")
+		require.NoError(t, err)
+		require.Equal(t, "You are a Go expert", msg)
+	})
+
+	t.Run("custom delimiters", func(t *testing.T) {
+		msg, err := expandRole("You are a <<.Language>> expert, not {{.Nope}}", vars, "<<", ">>")
+		require.NoError(t, err)
+		require.Equal(t, "You are a Go expert, not {{.Nope}}", msg)
+	})
+
+	t.Run("missing var", func(t *testing.T) {
+		_, err := expandRole("You are a {{.Language}} expert in {{.Field}}", vars, "", "")
+		require.ErrorContains(t, err, `no entry for key "Field"`)
+	})
+
+	t.Run("invalid template", func(t *testing.T) {
+		_, err := expandRole("You are a {{.Language expert", vars, "", "")
+		require.Error(t, err)
+	})
+}
+
+func TestEnsureConfigPath(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	xdg.Reload()
+	t.Cleanup(xdg.Reload)
+
+	path := filepath.Join(t.TempDir(), "ci.yml")
+	require.NoError(t, os.WriteFile(path, []byte(profilesConfig), 0o600))
+
+	c, err := ensureConfig(path, "work")
+	require.NoError(t, err)
+	require.Equal(t, path, c.SettingsPath)
+	require.Equal(t, "claude", c.Model)
+	require.FileExists(t, filepath.Join(xdg.ConfigHome, "mods", "mods.yml"))
+
+	_, err = ensureConfig(filepath.Join(t.TempDir(), "nope.yml"), "")
+	require.Error(t, err)
+}
+
+func TestEnsureConfigDefaults(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	xdg.Reload()
+	t.Cleanup(xdg.Reload)
+
+	t.Run("missing keys", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "old.yml")
+		require.NoError(t, os.WriteFile(path, []byte("default-model: gpt-4o\n"), 0o600))
+
+		c, err := ensureConfig(path, "")
+		require.NoError(t, err)
+		require.Equal(t, "\n\n", c.Separator)
+		require.Equal(t, int64(-1), c.Seed)
+		require.Equal(t, 1, c.TruncationKeepLast)
+	})
+
+	t.Run("keep no turns", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "keep.yml")
+		require.NoError(t, os.WriteFile(path, []byte("truncation-keep-last: 0\n"), 0o600))
+
+		c, err := ensureConfig(path, "")
+		require.NoError(t, err)
+		require.Zero(t, c.TruncationKeepLast)
+	})
+
+	t.Run("empty separator", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "new.yml")
+		require.NoError(t, os.WriteFile(path, []byte("separator: \"\"\n"), 0o600))
+
+		c, err := ensureConfig(path, "")
+		require.NoError(t, err)
+		require.Empty(t, c.Separator)
+	})
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// validateConfig checks the given settings file content for unknown or
+// invalid fields, APIs with bad base URLs, models without an input limit and
+// empty roles. It returns all the problems found.
+func validateConfig(content []byte) []string {
+	var problems []string
+	var c Config
+	if err := decodeStrict(content, &c); err != nil {
+		problems = append(problems, yamlProblems("", err)...)
+	}
+
+	// APIs and profiles are decoded from nodes, which doesn't check for
+	// unknown fields, so check each of them on their own.
+	var raw struct {
+		APIs     yaml.Node            `yaml:"apis"`
+		Profiles map[string]yaml.Node `yaml:"profiles"`
+	}
+	if err := yaml.Unmarshal(content, &raw); err != nil {
+		return problems
+	}
+	for i := 0; i+1 < len(raw.APIs.Content); i += 2 {
+		name := raw.APIs.Content[i].Value
+		var api API
+		if err := decodeNodeStrict(raw.APIs.Content[i+1], &api); err != nil {
+			problems = append(problems, yamlProblems("apis."+name, err)...)
+		}
+	}
+	for _, name := range profileNames(raw.Profiles) {
+		node := raw.Profiles[name]
+		var profile Config
+		if err := decodeNodeStrict(&node, &profile); err != nil {
+			problems = append(problems, yamlProblems("profiles."+name, err)...)
+		}
+	}
+
+	for _, api := range c.APIs {
+		if api.BaseURL != "" {
+			if u, err := url.Parse(api.BaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+				problems = append(problems, fmt.Sprintf("apis.%s: base-url %q is not a valid URL", api.Name, api.BaseURL))
+			}
+		}
+		models := make([]string, 0, len(api.Models))
+		for name := range api.Models {
+			models = append(models, name)
+		}
+		sort.Strings(models)
+		for _, name := range models {
+			if api.Models[name].MaxChars <= 0 {
+				problems = append(problems, fmt.Sprintf("apis.%s.models.%s: max-input-chars must be positive", api.Name, name))
+			}
+		}
+	}
+
+	roles := make([]string, 0, len(c.Roles))
+	for name := range c.Roles {
+		roles = append(roles, name)
+	}
+	sort.Strings(roles)
+	for _, name := range roles {
+		// the default role may be empty, meaning no system prompt.
+		if name != "default" && !hasNonEmpty(c.Roles[name]) {
+			problems = append(problems, fmt.Sprintf("roles.%s: must have at least one non-empty message", name))
+		}
+	}
+	return problems
+}
+
+func decodeStrict(content []byte, v any) error {
+	dec := yaml.NewDecoder(bytes.NewReader(content))
+	dec.KnownFields(true)
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("decodeStrict: %w", err)
+	}
+	return nil
+}
+
+func decodeNodeStrict(node *yaml.Node, v any) error {
+	bts, err := yaml.Marshal(node)
+	if err != nil {
+		return fmt.Errorf("decodeNodeStrict: %w", err)
+	}
+	return decodeStrict(bts, v)
+}
+
+// yamlLine is how yaml errors start. The lines are wrong for nodes decoded
+// on their own, which are reported with their path instead.
+var yamlLine = regexp.MustCompile(`^line \d+: `)
+
+// yamlProblems splits the type errors of a decoding error, so each of them
+// is reported on its own.
+func yamlProblems(path string, err error) []string {
+	var terr *yaml.TypeError
+	if !errors.As(err, &terr) {
+		return []string{strings.TrimPrefix(err.Error(), "decodeStrict: ")}
+	}
+	problems := make([]string, 0, len(terr.Errors))
+	for _, e := range terr.Errors {
+		if path != "" {
+			e = path + ": " + yamlLine.ReplaceAllString(e, "")
+		}
+		problems = append(problems, e)
+	}
+	return problems
+}
+
+func hasNonEmpty(lines []string) bool {
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// validateConfigFile prints whether the settings file at path is valid,
+// returning an error listing all its problems if it isn't.
+func validateConfigFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return modsError{err, "Could not read settings file."}
+	}
+	problems := validateConfig(content)
+	if len(problems) == 0 {
+		fmt.Println("Settings file is valid:", path)
+		return nil
+	}
+	return modsError{
+		err: newUserErrorf("%s", "- "+strings.Join(problems, "\n- ")),
+		reason: fmt.Sprintf(
+			"Found %d problems in %s.",
+			len(problems),
+			stderrStyles().InlineCode.Render(path),
+		),
+	}
+}
+
+//nolint:mnd
+func isValidateConfigCmd(args []string) bool {
+	return len(args) == 2 && args[1] == "validate-config"
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateConfig(t *testing.T) {
+	t.Run("template", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "mods.yml")
+		require.NoError(t, createConfigFile(path))
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Empty(t, validateConfig(content))
+	})
+
+	t.Run("problems", func(t *testing.T) {
+		problems := validateConfig([]byte(`
+default-model: gpt-4o
+temperature: 0.5
+roles:
+  default: []
+  shell: ["", " "]
+apis:
+  openai:
+    base-url: not a url
+    api-key-environment: OPENAI_API_KEY
+    models:
+      gpt-4o:
+        max-input-chars: 392000
+      gpt-4o-mini:
+        max-chars: 392000
+profiles:
+  work:
+    model: gpt-4o
+`))
+		require.Equal(t, []string{
+			"line 3: field temperature not found in type main.Config",
+			"apis.openai: field api-key-environment not found in type main.API",
+			"apis.openai: field max-chars not found in type main.Model",
+			"profiles.work: field model not found in type main.Config",
+			`apis.openai: base-url "not a url" is not a valid URL`,
+			"apis.openai.models.gpt-4o-mini: max-input-chars must be positive",
+			"roles.shell: must have at least one non-empty message",
+		}, problems)
+	})
+
+	t.Run("invalid yaml", func(t *testing.T) {
+		problems := validateConfig([]byte("roles: [\n"))
+		require.Len(t, problems, 1)
+		require.Contains(t, problems[0], "yaml:")
+	})
+}
@@ -2,18 +2,158 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 )
 
-func getCopilotAuthToken() (string, error) {
+const (
+	copilotHost    = "github.com"
+	copilotBaseURL = "https://api.githubcopilot.com"
+)
+
+// copilotHostsPath is where the GitHub Copilot editor plugins keep their
+// OAuth tokens.
+func copilotHostsPath() string {
 	// TODO: Windows?
-	bts, err := os.ReadFile(os.Getenv("HOME") + "/.config/github-copilot/hosts.json")
+	return filepath.Join(os.Getenv("HOME"), ".config", "github-copilot", "hosts.json")
+}
+
+// copilotHostEntry is a host entry of the Copilot hosts file.
+type copilotHostEntry struct {
+	User       string `json:"user"`
+	OAuthToken string `json:"oauth_token"`
+}
+
+func readCopilotHosts(path string) (map[string]copilotHostEntry, error) {
+	bts, err := os.ReadFile(path)
 	if err != nil {
-		return "", err
+		return nil, err //nolint:wrapcheck
 	}
-	hosts := map[string]map[string]string{}
+	hosts := map[string]copilotHostEntry{}
 	if err := json.Unmarshal(bts, &hosts); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	return hosts, nil
+}
+
+func getCopilotAuthToken(host string) (string, error) {
+	hosts, err := readCopilotHosts(copilotHostsPath())
+	if err != nil {
+		return "", err
+	}
+	return hosts[host].OAuthToken, nil
+}
+
+// copilotEnterpriseURL returns the GitHub Enterprise URL set on the API, or
+// in $GH_ENTERPRISE_URL.
+func copilotEnterpriseURL(api API) string {
+	if api.GitHubEnterpriseURL != "" {
+		return api.GitHubEnterpriseURL
+	}
+	return os.Getenv("GH_ENTERPRISE_URL")
+}
+
+// copilotHostFor returns the host the Copilot plugins file the OAuth token
+// under: github.com, or the GitHub Enterprise host.
+func copilotHostFor(api API) (string, error) {
+	enterprise := copilotEnterpriseURL(api)
+	if enterprise == "" {
+		return copilotHost, nil
+	}
+	if !strings.Contains(enterprise, "://") {
+		enterprise = "https://" + enterprise
+	}
+	u, err := url.Parse(enterprise)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid github-enterprise-url %q", enterprise)
+	}
+	return u.Host, nil
+}
+
+// copilotEndpoint returns the base URL of the Copilot API. GitHub Enterprise
+// serves it from the copilot-api subdomain of its host, unless base-url is
+// set to something other than the github.com one.
+func copilotEndpoint(api API) (string, error) {
+	host, err := copilotHostFor(api)
+	if err != nil {
 		return "", err
 	}
-	return hosts["github.com"]["oauth_token"], nil
+	if host == copilotHost || (api.BaseURL != "" && api.BaseURL != copilotBaseURL) {
+		return api.BaseURL, nil
+	}
+	return "https://copilot-api." + host, nil
+}
+
+var errCopilotToken = errors.New("no Copilot token")
+
+// printCopilotStatus prints where the Copilot token comes from, who it
+// belongs to and which endpoint and models it is used with. It returns an
+// error if there is no token to use.
+func printCopilotStatus(w io.Writer, path string, api API) error {
+	styles := stdoutStyles()
+	line := func(label string, value any) {
+		fmt.Fprintf(w, "%s %v\n", styles.FlagDesc.Render(label), value)
+	}
+
+	hostName, err := copilotHostFor(api)
+	if err != nil {
+		return err
+	}
+	endpoint, err := copilotEndpoint(api)
+	if err != nil {
+		return err
+	}
+	if endpoint == "" {
+		endpoint = "not set"
+	}
+	models := make([]string, 0, len(api.Models))
+	for name := range api.Models {
+		models = append(models, name)
+	}
+	sort.Strings(models)
+
+	if hostName != copilotHost {
+		line("GitHub Enterprise:", hostName)
+	}
+	line("Endpoint:", endpoint)
+	line("Models:", strings.Join(models, ", "))
+	if hasAPIKey(api) {
+		line("Token:", "from the API key settings")
+		return nil
+	}
+
+	line("Token file:", path)
+	hosts, err := readCopilotHosts(path)
+	if err != nil {
+		line("Token:", "missing")
+		return fmt.Errorf("%w: %w", errCopilotToken, err)
+	}
+	host := hosts[hostName]
+	if host.OAuthToken == "" {
+		line("Token:", "missing")
+		return fmt.Errorf("%w for %s in %s", errCopilotToken, hostName, path)
+	}
+	line("User:", host.User)
+	line("Token:", maskToken(host.OAuthToken))
+	return nil
+}
+
+// maskToken hides all but the first few characters of a token.
+func maskToken(token string) string {
+	const visible = 4
+	if len(token) <= visible*2 { //nolint:mnd
+		return strings.Repeat("*", len(token))
+	}
+	return token[:visible] + strings.Repeat("*", len(token)-visible)
+}
+
+//nolint:mnd
+func isCopilotStatusCmd(args []string) bool {
+	return len(args) == 2 && args[1] == "copilot-status"
 }
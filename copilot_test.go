@@ -0,0 +1,122 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintCopilotStatus(t *testing.T) {
+	api := API{
+		Name:    "copilot",
+		BaseURL: "https://api.githubcopilot.com",
+		Models:  map[string]Model{"gpt-4o": {}, "claude-3.5-sonnet": {}},
+	}
+
+	t.Run("signed in", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "hosts.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"github.com":{"user":"octocat","oauth_token":"gho_abcdefghijkl"}}`), 0o600))
+
+		var b strings.Builder
+		require.NoError(t, printCopilotStatus(&b, path, api))
+		out := b.String()
+		require.Contains(t, out, "https://api.githubcopilot.com")
+		require.Contains(t, out, "claude-3.5-sonnet, gpt-4o")
+		require.Contains(t, out, "octocat")
+		require.Contains(t, out, "gho_************")
+		require.NotContains(t, out, "abcdefghijkl")
+	})
+
+	t.Run("no hosts file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "hosts.json")
+
+		var b strings.Builder
+		err := printCopilotStatus(&b, path, api)
+		require.ErrorIs(t, err, errCopilotToken)
+		require.ErrorIs(t, err, os.ErrNotExist)
+		require.Contains(t, b.String(), "missing")
+	})
+
+	t.Run("no github.com host", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "hosts.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"example.com":{"oauth_token":"abc"}}`), 0o600))
+
+		var b strings.Builder
+		require.ErrorIs(t, printCopilotStatus(&b, path, api), errCopilotToken)
+	})
+
+	t.Run("api key settings", func(t *testing.T) {
+		api := api
+		api.APIKeyEnv = "GITHUB_TOKEN"
+
+		var b strings.Builder
+		require.NoError(t, printCopilotStatus(&b, filepath.Join(t.TempDir(), "hosts.json"), api))
+		require.Contains(t, b.String(), "from the API key settings")
+	})
+}
+
+func TestMaskToken(t *testing.T) {
+	require.Equal(t, "", maskToken(""))
+	require.Equal(t, "********", maskToken("abcdefgh"))
+	require.Equal(t, "abcd*****", maskToken("abcdefghi"))
+}
+
+func TestCopilotEnterprise(t *testing.T) {
+	t.Setenv("GH_ENTERPRISE_URL", "")
+
+	t.Run("github.com", func(t *testing.T) {
+		api := API{BaseURL: copilotBaseURL}
+		host, err := copilotHostFor(api)
+		require.NoError(t, err)
+		require.Equal(t, "github.com", host)
+		endpoint, err := copilotEndpoint(api)
+		require.NoError(t, err)
+		require.Equal(t, copilotBaseURL, endpoint)
+	})
+
+	t.Run("enterprise", func(t *testing.T) {
+		api := API{BaseURL: copilotBaseURL, GitHubEnterpriseURL: "https://octocorp.ghe.com/"}
+		host, err := copilotHostFor(api)
+		require.NoError(t, err)
+		require.Equal(t, "octocorp.ghe.com", host)
+		endpoint, err := copilotEndpoint(api)
+		require.NoError(t, err)
+		require.Equal(t, "https://copilot-api.octocorp.ghe.com", endpoint)
+	})
+
+	t.Run("enterprise from env", func(t *testing.T) {
+		t.Setenv("GH_ENTERPRISE_URL", "octocorp.ghe.com")
+		endpoint, err := copilotEndpoint(API{})
+		require.NoError(t, err)
+		require.Equal(t, "https://copilot-api.octocorp.ghe.com", endpoint)
+	})
+
+	t.Run("enterprise with custom base url", func(t *testing.T) {
+		api := API{BaseURL: "https://copilot.internal", GitHubEnterpriseURL: "https://octocorp.ghe.com"}
+		endpoint, err := copilotEndpoint(api)
+		require.NoError(t, err)
+		require.Equal(t, "https://copilot.internal", endpoint)
+	})
+
+	t.Run("enterprise token", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "hosts.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{
+			"github.com":{"user":"octocat","oauth_token":"gho_public"},
+			"octocorp.ghe.com":{"user":"mona","oauth_token":"gho_enterprise"}
+		}`), 0o600))
+
+		var b strings.Builder
+		api := API{BaseURL: copilotBaseURL, GitHubEnterpriseURL: "https://octocorp.ghe.com"}
+		require.NoError(t, printCopilotStatus(&b, path, api))
+		require.Contains(t, b.String(), "mona")
+		require.Contains(t, b.String(), "https://copilot-api.octocorp.ghe.com")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := copilotHostFor(API{GitHubEnterpriseURL: "https://"})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// costWarningRatio is how much of --max-cost can be spent before we warn.
+const costWarningRatio = 0.8
+
+// hasCost reports whether the model's prices are known.
+func hasCost(mod Model) bool {
+	return mod.CostPerInputToken > 0 || mod.CostPerOutputToken > 0
+}
+
+// requestCost returns the cost in USD of a request with the given token
+// counts.
+func requestCost(mod Model, inputTokens, outputTokens int) float64 {
+	return float64(inputTokens)*mod.CostPerInputToken + float64(outputTokens)*mod.CostPerOutputToken
+}
+
+// estimateInputCost returns roughly what sending messages to the model
+// costs, before any of the response.
+func estimateInputCost(mod Model, messages []openai.ChatCompletionMessage) float64 {
+	return requestCost(mod, estimateTokens(messagesText(messages)), 0)
+}
+
+// checkCost makes sure sending the current messages would not take the
+// cost of this run over --max-cost.
+func (m *Mods) checkCost(mod Model) error {
+	if m.Config.MaxCost <= 0 || !hasCost(mod) {
+		return nil
+	}
+	estimate := m.cost + estimateInputCost(mod, m.messages)
+	if estimate <= m.Config.MaxCost {
+		return nil
+	}
+	return modsError{
+		err: newUserErrorf(
+			"The request would take the cost to about $%.4f. Raise %s or shorten the prompt.",
+			estimate,
+			m.Styles.InlineCode.Render("--max-cost"),
+		),
+		reason: fmt.Sprintf("Over the cost limit of $%.4f.", m.Config.MaxCost),
+	}
+}
+
+// addCost adds the cost of the request that just finished. Cached responses
+// are free.
+func (m *Mods) addCost() {
+	if !hasCost(m.model) || m.responseHit {
+		return
+	}
+	input, output := m.usage()
+	m.cost += requestCost(m.model, input, output)
+}
+
+// usage returns the input and output tokens of the request that just
+// finished, as the API returned them, or estimated from the text when it did
+// not.
+func (m *Mods) usage() (int, int) {
+	if m.promptTokens > 0 || m.completionTokens > 0 {
+		return m.promptTokens, m.completionTokens
+	}
+	messages := m.messages
+	if n := len(messages); n > 0 && messages[n-1].Role == openai.ChatMessageRoleAssistant {
+		messages = messages[:n-1]
+	}
+	return estimateTokens(messagesText(messages)), estimateTokens(m.Output)
+}
+
+// printCostWarning warns when the run cost most of --max-cost.
+func printCostWarning(w io.Writer, mods *Mods) {
+	maxCost := mods.Config.MaxCost
+	if maxCost <= 0 || mods.cost < maxCost*costWarningRatio {
+		return
+	}
+	fmt.Fprintf(w, "Warning: this run cost $%.4f, %.0f%% of --max-cost $%.4f.\n", mods.cost, mods.cost/maxCost*100, maxCost) //nolint:mnd
+}
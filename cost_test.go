@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestCost(t *testing.T) {
+	mod := Model{CostPerInputToken: 0.000002, CostPerOutputToken: 0.00001}
+	require.True(t, hasCost(mod))
+	require.False(t, hasCost(Model{}))
+	require.InDelta(t, 0.003, requestCost(mod, 1000, 100), 1e-9)
+	require.InDelta(t, 0.0002, estimateInputCost(mod, []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleUser, Content: strings.Repeat("a", 400)},
+	}), 1e-9)
+}
+
+func TestCheckCost(t *testing.T) {
+	mod := Model{Name: "gpt-4o", CostPerInputToken: 0.001}
+	mods := newMods(lipgloss.DefaultRenderer(), &Config{MaxCost: 0.5}, nil, nil)
+	mods.messages = []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleUser, Content: strings.Repeat("a", 1000)},
+	}
+
+	// 250 tokens at $0.001.
+	require.NoError(t, mods.checkCost(mod))
+	mods.cost = 0.3
+	require.Error(t, mods.checkCost(mod))
+
+	// no limit, or no prices.
+	require.NoError(t, mods.checkCost(Model{}))
+	mods.Config.MaxCost = 0
+	require.NoError(t, mods.checkCost(mod))
+}
+
+func TestAddCost(t *testing.T) {
+	mods := newMods(lipgloss.DefaultRenderer(), &Config{}, nil, nil)
+	mods.model = Model{CostPerInputToken: 0.001, CostPerOutputToken: 0.002}
+
+	mods.promptTokens, mods.completionTokens = 100, 50
+	mods.addCost()
+	require.InDelta(t, 0.2, mods.cost, 1e-9)
+
+	// estimated from the text without usage.
+	mods.promptTokens, mods.completionTokens = 0, 0
+	mods.messages = []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleUser, Content: strings.Repeat("a", 400)},
+		{Role: openai.ChatMessageRoleAssistant, Content: strings.Repeat("b", 40)},
+	}
+	mods.Output = strings.Repeat("b", 40)
+	mods.addCost()
+	require.InDelta(t, 0.2+0.1+0.02, mods.cost, 1e-9)
+
+	mods.responseHit = true
+	mods.addCost()
+	require.InDelta(t, 0.32, mods.cost, 1e-9)
+}
+
+func TestPrintCostWarning(t *testing.T) {
+	mods := &Mods{Config: &Config{MaxCost: 1}, cost: 0.5}
+	var b strings.Builder
+	printCostWarning(&b, mods)
+	require.Empty(t, b.String())
+
+	mods.cost = 0.9
+	printCostWarning(&b, mods)
+	require.Equal(t, "Warning: this run cost $0.9000, 90% of --max-cost $1.0000.\n", b.String())
+}
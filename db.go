@@ -1,8 +1,11 @@
 package main
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/jmoiron/sqlx"
@@ -71,6 +74,67 @@ func openDB(ds string) (*convoDB, error) {
 		}
 	}
 
+	if !hasColumn(db, "api") {
+		if _, err := db.Exec(`
+			ALTER TABLE conversations ADD COLUMN api string
+		`); err != nil {
+			return nil, fmt.Errorf("could not migrate db: %w", err)
+		}
+	}
+
+	if !hasColumn(db, "tokens_used") {
+		if _, err := db.Exec(`
+			ALTER TABLE conversations ADD COLUMN tokens_used integer NOT NULL DEFAULT 0
+		`); err != nil {
+			return nil, fmt.Errorf("could not migrate db: %w", err)
+		}
+	}
+
+	if !hasColumn(db, "tags") {
+		if _, err := db.Exec(`
+			ALTER TABLE conversations ADD COLUMN tags string
+		`); err != nil {
+			return nil, fmt.Errorf("could not migrate db: %w", err)
+		}
+	}
+
+	if !hasColumn(db, "archived") {
+		if _, err := db.Exec(`
+			ALTER TABLE conversations ADD COLUMN archived boolean NOT NULL DEFAULT 0
+		`); err != nil {
+			return nil, fmt.Errorf("could not migrate db: %w", err)
+		}
+	}
+
+	if !hasColumn(db, "pinned") {
+		if _, err := db.Exec(`
+			ALTER TABLE conversations ADD COLUMN pinned boolean NOT NULL DEFAULT 0
+		`); err != nil {
+			return nil, fmt.Errorf("could not migrate db: %w", err)
+		}
+	}
+
+	if !hasColumn(db, "created_at") {
+		if _, err := db.Exec(`
+			ALTER TABLE conversations ADD COLUMN created_at datetime
+		`); err != nil {
+			return nil, fmt.Errorf("could not migrate db: %w", err)
+		}
+		// the best guess we have for existing conversations.
+		if _, err := db.Exec(`
+			UPDATE conversations SET created_at = updated_at
+		`); err != nil {
+			return nil, fmt.Errorf("could not migrate db: %w", err)
+		}
+	}
+
+	if _, err := db.Exec(`
+		CREATE VIRTUAL TABLE
+		  IF NOT EXISTS conversations_fts USING fts5 (id UNINDEXED, content, tokenize = 'trigram')
+	`); err != nil {
+		return nil, fmt.Errorf("could not migrate db: %w", err)
+	}
+
 	return &convoDB{db: db}, nil
 }
 
@@ -92,10 +156,47 @@ type convoDB struct {
 
 // Conversation in the database.
 type Conversation struct {
-	ID        string    `db:"id"`
-	Title     string    `db:"title"`
-	UpdatedAt time.Time `db:"updated_at"`
-	Model     *string   `db:"model"`
+	ID         string     `db:"id"`
+	Title      string     `db:"title"`
+	UpdatedAt  time.Time  `db:"updated_at"`
+	CreatedAt  *time.Time `db:"created_at"`
+	Model      *string    `db:"model"`
+	API        *string    `db:"api"`
+	TokensUsed int        `db:"tokens_used"`
+	Tags       *string    `db:"tags"`
+	Archived   bool       `db:"archived"`
+	Pinned     bool       `db:"pinned"`
+
+	// MessageCount and ApproxTokens are not stored, see convoCache.counts.
+	MessageCount int `db:"-"`
+	ApproxTokens int `db:"-"`
+}
+
+// TagList returns the tags of the conversation.
+func (c Conversation) TagList() []string {
+	if c.Tags == nil {
+		return nil
+	}
+	return splitTags(*c.Tags)
+}
+
+// splitTags splits a comma separated list of tags, removing empty and
+// duplicated entries.
+func splitTags(s string) []string {
+	return normalizeTags(strings.Split(s, ","))
+}
+
+func normalizeTags(tags []string) []string {
+	result := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || slices.Contains(result, tag) {
+			continue
+		}
+		result = append(result, tag)
+	}
+	slices.Sort(result)
+	return result
 }
 
 func (c *convoDB) Close() error {
@@ -108,7 +209,7 @@ func (c *convoDB) Save(id, title, model string) error {
 		SET
 		  title = ?,
 		  model = ?,
-		  updated_at = CURRENT_TIMESTAMP
+		  updated_at = strftime ('%Y-%m-%d %H:%M:%f', 'now')
 		WHERE
 		  id = ?
 	`), title, model, id)
@@ -127,9 +228,9 @@ func (c *convoDB) Save(id, title, model string) error {
 
 	if _, err := c.db.Exec(c.db.Rebind(`
 		INSERT INTO
-		  conversations (id, title, model)
+		  conversations (id, title, model, created_at)
 		VALUES
-		  (?, ?, ?)
+		  (?, ?, ?, strftime ('%Y-%m-%d %H:%M:%f', 'now'))
 	`), id, title, model); err != nil {
 		return fmt.Errorf("Save: %w", err)
 	}
@@ -137,6 +238,398 @@ func (c *convoDB) Save(id, title, model string) error {
 	return nil
 }
 
+// Tag adds the given tags to the conversation.
+func (c *convoDB) Tag(id string, tags []string) error {
+	var current []sql.NullString
+	if err := c.db.Select(&current, c.db.Rebind(`
+		SELECT
+		  tags
+		FROM
+		  conversations
+		WHERE
+		  id = ?
+	`), id); err != nil {
+		return fmt.Errorf("Tag: %w", err)
+	}
+	if len(current) == 0 {
+		return fmt.Errorf("Tag: %w", errNoMatches)
+	}
+	if current[0].Valid {
+		tags = append(splitTags(current[0].String), tags...)
+	}
+
+	if _, err := c.db.Exec(c.db.Rebind(`
+		UPDATE conversations
+		SET
+		  tags = ?
+		WHERE
+		  id = ?
+	`), strings.Join(normalizeTags(tags), ","), id); err != nil {
+		return fmt.Errorf("Tag: %w", err)
+	}
+	return nil
+}
+
+// TagCompletions returns all the tags starting with the given prefix.
+func (c *convoDB) TagCompletions(in string) ([]string, error) {
+	var rows []string
+	if err := c.db.Select(&rows, `
+		SELECT
+		  tags
+		FROM
+		  conversations
+		WHERE
+		  tags IS NOT NULL
+		  AND tags <> ''
+	`); err != nil {
+		return nil, fmt.Errorf("TagCompletions: %w", err)
+	}
+	var all []string
+	for _, row := range rows {
+		all = append(all, splitTags(row)...)
+	}
+	result := []string{}
+	for _, tag := range normalizeTags(all) {
+		if strings.HasPrefix(tag, in) {
+			result = append(result, tag)
+		}
+	}
+	return result, nil
+}
+
+// SetAPI sets the API used by the given conversation.
+func (c *convoDB) SetAPI(id, api string) error {
+	if _, err := c.db.Exec(c.db.Rebind(`
+		UPDATE conversations
+		SET
+		  api = ?
+		WHERE
+		  id = ?
+	`), api, id); err != nil {
+		return fmt.Errorf("SetAPI: %w", err)
+	}
+	return nil
+}
+
+// Archive hides the given conversation from --list.
+func (c *convoDB) Archive(id string) error {
+	if err := c.setArchived(id, true); err != nil {
+		return fmt.Errorf("Archive: %w", err)
+	}
+	return nil
+}
+
+// Unarchive shows the given conversation in --list again.
+func (c *convoDB) Unarchive(id string) error {
+	if err := c.setArchived(id, false); err != nil {
+		return fmt.Errorf("Unarchive: %w", err)
+	}
+	return nil
+}
+
+func (c *convoDB) setArchived(id string, archived bool) error {
+	res, err := c.db.Exec(c.db.Rebind(`
+		UPDATE conversations
+		SET
+		  archived = ?
+		WHERE
+		  id = ?
+	`), archived, id)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	if rows == 0 {
+		return errNoMatches
+	}
+	return nil
+}
+
+// Pin keeps the given conversation at the top of --list.
+func (c *convoDB) Pin(id string) error {
+	if err := c.setPinned(id, true); err != nil {
+		return fmt.Errorf("Pin: %w", err)
+	}
+	return nil
+}
+
+// Unpin lets the given conversation be sorted like the others again.
+func (c *convoDB) Unpin(id string) error {
+	if err := c.setPinned(id, false); err != nil {
+		return fmt.Errorf("Unpin: %w", err)
+	}
+	return nil
+}
+
+// Pinned returns the IDs of the pinned conversations.
+func (c *convoDB) Pinned() (map[string]bool, error) {
+	var ids []string
+	if err := c.db.Select(&ids, `
+		SELECT
+		  id
+		FROM
+		  conversations
+		WHERE
+		  pinned
+	`); err != nil {
+		return nil, fmt.Errorf("Pinned: %w", err)
+	}
+	pinned := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		pinned[id] = true
+	}
+	return pinned, nil
+}
+
+func (c *convoDB) setPinned(id string, pinned bool) error {
+	res, err := c.db.Exec(c.db.Rebind(`
+		UPDATE conversations
+		SET
+		  pinned = ?
+		WHERE
+		  id = ?
+	`), pinned, id)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	if rows == 0 {
+		return errNoMatches
+	}
+	return nil
+}
+
+// SetTokens sets the number of tokens used by the given conversation.
+func (c *convoDB) SetTokens(id string, tokens int) error {
+	if _, err := c.db.Exec(c.db.Rebind(`
+		UPDATE conversations
+		SET
+		  tokens_used = ?
+		WHERE
+		  id = ?
+	`), tokens, id); err != nil {
+		return fmt.Errorf("SetTokens: %w", err)
+	}
+	return nil
+}
+
+// RenameModel replaces oldModel with newModel in all the conversations that
+// used it, returning how many were changed.
+func (c *convoDB) RenameModel(oldModel, newModel string) (int, error) {
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("RenameModel: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	res, err := tx.Exec(tx.Rebind(`
+		UPDATE conversations
+		SET
+		  model = ?
+		WHERE
+		  model = ?
+	`), newModel, oldModel)
+	if err != nil {
+		return 0, fmt.Errorf("RenameModel: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("RenameModel: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("RenameModel: %w", err)
+	}
+	return int(rows), nil
+}
+
+// ConvoStats are aggregated statistics about the saved conversations.
+type ConvoStats struct {
+	Conversations int     `db:"conversations" json:"conversations"`
+	TotalTokens   int     `db:"total_tokens" json:"total_tokens"`
+	AverageTokens float64 `db:"average_tokens" json:"average_tokens"`
+	TopModel      string  `db:"-" json:"top_model"`
+	TopAPI        string  `db:"-" json:"top_api"`
+	// SizeBytes is the size of the database, of which FragmentedBytes are
+	// free pages that only VACUUM gives back.
+	SizeBytes       int64 `db:"-" json:"size_bytes"`
+	FragmentedBytes int64 `db:"-" json:"fragmented_bytes"`
+}
+
+// vacuumRatio is how much of the database has to be free pages for --stats
+// to suggest --vacuum.
+const vacuumRatio = 0.2
+
+// NeedsVacuum tells whether enough of the database is free pages for VACUUM
+// to be worth it.
+func (s ConvoStats) NeedsVacuum() bool {
+	return s.SizeBytes > 0 && float64(s.FragmentedBytes)/float64(s.SizeBytes) > vacuumRatio
+}
+
+func (c *convoDB) Stats() (ConvoStats, error) {
+	var stats ConvoStats
+	if err := c.db.Get(&stats, `
+		SELECT
+		  count(*) AS conversations,
+		  coalesce(sum(tokens_used), 0) AS total_tokens,
+		  coalesce(avg(tokens_used), 0) AS average_tokens
+		FROM
+		  conversations
+	`); err != nil {
+		return stats, fmt.Errorf("Stats: %w", err)
+	}
+
+	var err error
+	if stats.TopModel, err = c.mostUsed("model"); err != nil {
+		return stats, fmt.Errorf("Stats: %w", err)
+	}
+	if stats.TopAPI, err = c.mostUsed("api"); err != nil {
+		return stats, fmt.Errorf("Stats: %w", err)
+	}
+	if stats.SizeBytes, stats.FragmentedBytes, err = c.size(); err != nil {
+		return stats, fmt.Errorf("Stats: %w", err)
+	}
+	return stats, nil
+}
+
+// size returns the size of the database and how much of it is free pages.
+func (c *convoDB) size() (int64, int64, error) {
+	var pageSize, pages, free int64
+	for pragma, dest := range map[string]*int64{
+		"page_size":      &pageSize,
+		"page_count":     &pages,
+		"freelist_count": &free,
+	} {
+		if err := c.db.Get(dest, "PRAGMA "+pragma); err != nil {
+			return 0, 0, fmt.Errorf("size: %w", err)
+		}
+	}
+	return pages * pageSize, free * pageSize, nil
+}
+
+// Vacuum rebuilds the database, giving the space of deleted conversations
+// back to the file system.
+func (c *convoDB) Vacuum() error {
+	if _, err := c.db.Exec(`VACUUM`); err != nil {
+		return fmt.Errorf("Vacuum: %w", err)
+	}
+	return nil
+}
+
+// Backup writes a copy of the database to destPath, which must not exist.
+func (c *convoDB) Backup(destPath string) error {
+	if _, err := c.db.Exec(`VACUUM INTO ?`, destPath); err != nil {
+		return fmt.Errorf("Backup: %w", err)
+	}
+	return nil
+}
+
+// mostUsed returns the most common non-empty value of the given column.
+func (c *convoDB) mostUsed(col string) (string, error) {
+	var result string
+	if err := c.db.Get(&result, fmt.Sprintf(`
+		SELECT
+		  %[1]s
+		FROM
+		  conversations
+		WHERE
+		  %[1]s IS NOT NULL
+		  AND %[1]s <> ''
+		GROUP BY
+		  %[1]s
+		ORDER BY
+		  count(*) DESC,
+		  max(updated_at) DESC
+		LIMIT
+		  1
+	`, col)); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err //nolint:wrapcheck
+	}
+	return result, nil
+}
+
+func (c *convoDB) Rename(id, title string) error {
+	res, err := c.db.Exec(c.db.Rebind(`
+		UPDATE conversations
+		SET
+		  title = ?
+		WHERE
+		  id = ?
+	`), title, id)
+	if err != nil {
+		return fmt.Errorf("Rename: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("Rename: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("Rename: %w", errNoMatches)
+	}
+	return nil
+}
+
+// Fork copies the conversation srcID into a new conversation dstID with the
+// given title. Empty api or model values are copied from the source.
+func (c *convoDB) Fork(srcID, dstID, title, api, model string) error {
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("Fork: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	res, err := tx.Exec(tx.Rebind(`
+		INSERT INTO
+		  conversations (id, title, api, model, tokens_used, tags, created_at)
+		SELECT
+		  ?,
+		  ?,
+		  coalesce(nullif(?, ''), api),
+		  coalesce(nullif(?, ''), model),
+		  tokens_used,
+		  tags,
+		  strftime ('%Y-%m-%d %H:%M:%f', 'now')
+		FROM
+		  conversations
+		WHERE
+		  id = ?
+	`), dstID, title, api, model, srcID)
+	if err != nil {
+		return fmt.Errorf("Fork: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("Fork: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("Fork: %w", errNoMatches)
+	}
+
+	if _, err := tx.Exec(tx.Rebind(`
+		INSERT INTO
+		  conversations_fts (id, content)
+		SELECT
+		  ?,
+		  content
+		FROM
+		  conversations_fts
+		WHERE
+		  id = ?
+	`), dstID, srcID); err != nil {
+		return fmt.Errorf("Fork: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("Fork: %w", err)
+	}
+	return nil
+}
+
 func (c *convoDB) Delete(id string) error {
 	if _, err := c.db.Exec(c.db.Rebind(`
 		DELETE FROM conversations
@@ -145,10 +638,100 @@ func (c *convoDB) Delete(id string) error {
 	`), id); err != nil {
 		return fmt.Errorf("Delete: %w", err)
 	}
+	if _, err := c.db.Exec(c.db.Rebind(`
+		DELETE FROM conversations_fts
+		WHERE
+		  id = ?
+	`), id); err != nil {
+		return fmt.Errorf("Delete: %w", err)
+	}
+	return nil
+}
+
+// Index sets the searchable content of the given conversation.
+func (c *convoDB) Index(id, content string) error {
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("Index: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if _, err := tx.Exec(tx.Rebind(`
+		DELETE FROM conversations_fts
+		WHERE
+		  id = ?
+	`), id); err != nil {
+		return fmt.Errorf("Index: %w", err)
+	}
+	if _, err := tx.Exec(tx.Rebind(`
+		INSERT INTO
+		  conversations_fts (id, content)
+		VALUES
+		  (?, ?)
+	`), id, content); err != nil {
+		return fmt.Errorf("Index: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("Index: %w", err)
+	}
 	return nil
 }
 
-func (c *convoDB) ListOlderThan(t time.Duration) ([]Conversation, error) {
+// Unindexed returns the IDs of the conversations that are not in the search
+// index yet.
+func (c *convoDB) Unindexed() ([]string, error) {
+	var ids []string
+	if err := c.db.Select(&ids, `
+		SELECT
+		  id
+		FROM
+		  conversations
+		WHERE
+		  id NOT IN (
+		    SELECT
+		      id
+		    FROM
+		      conversations_fts
+		  )
+	`); err != nil {
+		return nil, fmt.Errorf("Unindexed: %w", err)
+	}
+	return ids, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Search returns the conversations whose title or content contain the given
+// query, ignoring case.
+func (c *convoDB) Search(query string) ([]Conversation, error) {
+	pattern := "%" + likeEscaper.Replace(query) + "%"
+	var convos []Conversation
+	if err := c.db.Select(&convos, c.db.Rebind(`
+		SELECT
+		  *
+		FROM
+		  conversations
+		WHERE
+		  title LIKE ? ESCAPE '\'
+		  OR id IN (
+		    SELECT
+		      id
+		    FROM
+		      conversations_fts
+		    WHERE
+		      content LIKE ? ESCAPE '\'
+		  )
+		ORDER BY
+		  updated_at DESC
+	`), pattern, pattern); err != nil {
+		return convos, fmt.Errorf("Search: %w", err)
+	}
+	return convos, nil
+}
+
+// ListOlderThan returns the conversations not updated in the given duration.
+// Archived conversations are skipped unless includeArchived is set.
+func (c *convoDB) ListOlderThan(t time.Duration, includeArchived bool) ([]Conversation, error) {
 	var convos []Conversation
 	if err := c.db.Select(&convos, c.db.Rebind(`
 		SELECT
@@ -157,7 +740,8 @@ func (c *convoDB) ListOlderThan(t time.Duration) ([]Conversation, error) {
 		  conversations
 		WHERE
 		  updated_at < ?
-		`), time.Now().Add(-t)); err != nil {
+		  AND (? OR NOT archived)
+		`), time.Now().Add(-t), includeArchived); err != nil {
 		return nil, fmt.Errorf("ListOlderThan: %w", err)
 	}
 	return convos, nil
@@ -261,17 +845,187 @@ func (c *convoDB) Find(in string) (*Conversation, error) {
 	return nil, errNoMatches
 }
 
-func (c *convoDB) List() ([]Conversation, error) {
-	var convos []Conversation
-	if err := c.db.Select(&convos, `
+// listSorts maps the --sort values to their columns and default order.
+var listSorts = map[string]string{
+	"updated": "updated_at DESC",
+	"created": "created_at DESC",
+	"title":   "title ASC",
+	"model":   "model ASC",
+}
+
+// listSortNames are the valid --sort values.
+var listSortNames = []string{"updated", "created", "title", "model"}
+
+type listOptions struct {
+	tags     []string
+	model    string
+	api      string
+	sort     string
+	order    string
+	archived bool
+	limit    int
+	offset   int
+}
+
+type listOption func(*listOptions)
+
+// withTags only lists conversations that have all the given tags.
+func withTags(tags []string) listOption {
+	return func(o *listOptions) {
+		o.tags = normalizeTags(tags)
+	}
+}
+
+// withModel only lists conversations that used the given model.
+func withModel(model string) listOption {
+	return func(o *listOptions) {
+		o.model = model
+	}
+}
+
+// withAPI only lists conversations that used the given API.
+func withAPI(api string) listOption {
+	return func(o *listOptions) {
+		o.api = api
+	}
+}
+
+// withSort sorts the conversations by one of listSorts. If order is "ASC" or
+// "DESC", it overrides the default order of the sort.
+func withSort(sort, order string) listOption {
+	return func(o *listOptions) {
+		o.sort = sort
+		o.order = order
+	}
+}
+
+// withArchived lists the archived conversations instead of the others.
+func withArchived() listOption {
+	return func(o *listOptions) {
+		o.archived = true
+	}
+}
+
+// withPage only lists the given page of conversations, counting from 1.
+func withPage(page, size int) listOption {
+	return func(o *listOptions) {
+		o.limit, o.offset = pageBounds(page, size)
+	}
+}
+
+// pageBounds returns the LIMIT and OFFSET of the given page. A size of 0
+// means no paging.
+func pageBounds(page, size int) (int, int) {
+	if size <= 0 {
+		return 0, 0
+	}
+	page = max(page, 1)
+	return size, (page - 1) * size
+}
+
+// pageCount returns how many pages of size conversations total makes. There
+// is always at least one, even if it is empty.
+func pageCount(total, size int) int {
+	if size <= 0 {
+		return 1
+	}
+	return max((total+size-1)/size, 1)
+}
+
+func (c *convoDB) List(opts ...listOption) ([]Conversation, error) {
+	var o listOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	where, args := o.where()
+	query := `
 		SELECT
 		  *
 		FROM
 		  conversations
+	` + where
+	orderBy, ok := listSorts[o.sort]
+	if !ok {
+		orderBy = listSorts["updated"]
+	}
+	if o.order == "ASC" || o.order == "DESC" {
+		column, _, _ := strings.Cut(orderBy, " ")
+		orderBy = column + " " + o.order
+	}
+	// pinned conversations come first, whatever the sort.
+	query += `
 		ORDER BY
-		  updated_at DESC
-	`); err != nil {
+		  pinned DESC,
+		  ` + orderBy + `
+	`
+	if o.limit > 0 {
+		query += `
+		LIMIT ? OFFSET ?
+		`
+		args = append(args, o.limit, o.offset)
+	}
+
+	var convos []Conversation
+	if err := c.db.Select(&convos, c.db.Rebind(query), args...); err != nil {
 		return convos, fmt.Errorf("List: %w", err)
 	}
 	return convos, nil
 }
+
+// Count returns how many conversations List would return without paging.
+func (c *convoDB) Count(opts ...listOption) (int, error) {
+	var o listOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	where, args := o.where()
+	var count int
+	if err := c.db.Get(&count, c.db.Rebind(`
+		SELECT
+		  count(*)
+		FROM
+		  conversations
+	`+where), args...); err != nil {
+		return 0, fmt.Errorf("Count: %w", err)
+	}
+	return count, nil
+}
+
+func (o listOptions) where() (string, []any) {
+	query := `
+		WHERE
+		  archived = ?
+	`
+	args := []any{o.archived}
+	if o.model != "" {
+		query += `
+		  AND model = ?
+		`
+		args = append(args, o.model)
+	}
+	if o.api != "" {
+		query += `
+		  AND api = ?
+		`
+		args = append(args, o.api)
+	}
+	for _, tag := range o.tags {
+		query += `
+		  AND (',' || tags || ',') LIKE ? ESCAPE '\'
+		`
+		args = append(args, "%,"+likeEscaper.Replace(tag)+",%")
+	}
+	return query, args
+}
+
+// ListByModel returns the conversations that used the given model.
+func (c *convoDB) ListByModel(model string) ([]Conversation, error) {
+	return c.List(withModel(model))
+}
+
+// ListByAPI returns the conversations that used the given API.
+func (c *convoDB) ListByAPI(api string) ([]Conversation, error) {
+	return c.List(withAPI(api))
+}
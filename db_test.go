@@ -2,9 +2,13 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/stretchr/testify/require"
 )
 
@@ -126,6 +130,127 @@ func TestConvoDB(t *testing.T) {
 		require.ErrorIs(t, err, errManyMatches)
 	})
 
+	t.Run("rename", func(t *testing.T) {
+		db := testDB(t)
+
+		require.NoError(t, db.Save(testid, "message 1", "gpt-4o"))
+		require.NoError(t, db.Rename(testid, "a better title"))
+
+		convo, err := db.Find("a better title")
+		require.NoError(t, err)
+		require.Equal(t, testid, convo.ID)
+		require.NotNil(t, convo.Model)
+		require.Equal(t, "gpt-4o", *convo.Model)
+
+		require.ErrorIs(t, db.Rename(newConversationID(), "nope"), errNoMatches)
+		require.Error(t, db.Rename(testid, ""))
+	})
+
+	t.Run("fork", func(t *testing.T) {
+		db := testDB(t)
+
+		dstid := newConversationID()
+		require.NoError(t, db.Save(testid, "message 1", "gpt-4o"))
+		require.NoError(t, db.SetAPI(testid, "openai"))
+		require.NoError(t, db.SetTokens(testid, 42))
+		require.NoError(t, db.Tag(testid, []string{"work"}))
+		require.NoError(t, db.Index(testid, "some content"))
+		require.NoError(t, db.Fork(testid, dstid, "message 1 (fork)", "", ""))
+
+		convo, err := db.Find(dstid)
+		require.NoError(t, err)
+		require.Equal(t, "message 1 (fork)", convo.Title)
+		require.Equal(t, "openai", *convo.API)
+		require.Equal(t, "gpt-4o", *convo.Model)
+		require.Equal(t, 42, convo.TokensUsed)
+		require.Equal(t, []string{"work"}, convo.TagList())
+
+		results, err := db.Search("some content")
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+
+		require.NoError(t, db.Fork(testid, newConversationID(), "other", "ollama", "llama3"))
+		convo, err = db.Find("other")
+		require.NoError(t, err)
+		require.Equal(t, "ollama", *convo.API)
+		require.Equal(t, "llama3", *convo.Model)
+
+		require.ErrorIs(t, db.Fork(newConversationID(), newConversationID(), "nope", "", ""), errNoMatches)
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		db := testDB(t)
+
+		stats, err := db.Stats()
+		require.NoError(t, err)
+		require.Positive(t, stats.SizeBytes)
+		stats.SizeBytes = 0
+		require.Equal(t, ConvoStats{}, stats)
+
+		const testid2 = "6c33f71694bf41a18c844a96d1f62f153e5f6f44"
+		const testid3 = "fc5012d8c67073ea0a46a3c05488a0e1d87df74b"
+		require.NoError(t, db.Save(testid, "message 1", "gpt-4o"))
+		require.NoError(t, db.Save(testid2, "message 2", "llama3"))
+		require.NoError(t, db.Save(testid3, "message 3", "llama3"))
+		require.NoError(t, db.SetAPI(testid, "openai"))
+		require.NoError(t, db.SetAPI(testid2, "ollama"))
+		require.NoError(t, db.SetAPI(testid3, "ollama"))
+		require.NoError(t, db.SetTokens(testid, 100))
+		require.NoError(t, db.SetTokens(testid2, 50))
+
+		stats, err = db.Stats()
+		require.NoError(t, err)
+		stats.SizeBytes, stats.FragmentedBytes = 0, 0
+		require.Equal(t, ConvoStats{
+			Conversations: 3,
+			TotalTokens:   150,
+			AverageTokens: 50,
+			TopModel:      "llama3",
+			TopAPI:        "ollama",
+		}, stats)
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		db := testDB(t)
+
+		const testid2 = "6c33f71694bf41a18c844a96d1f62f153e5f6f44"
+		require.NoError(t, db.Save(testid, "message 1", "gpt-4o"))
+		require.NoError(t, db.Save(testid2, "message 2", "gpt-4o"))
+		require.NoError(t, db.Tag(testid, []string{"work", " go", ""}))
+		require.NoError(t, db.Tag(testid, []string{"review", "go"}))
+		require.NoError(t, db.Tag(testid2, []string{"work", "go_lang"}))
+		require.ErrorIs(t, db.Tag(newConversationID(), []string{"work"}), errNoMatches)
+
+		convo, err := db.Find(testid)
+		require.NoError(t, err)
+		require.Equal(t, []string{"go", "review", "work"}, convo.TagList())
+
+		ids := func(opts ...listOption) []string {
+			t.Helper()
+			list, err := db.List(opts...)
+			require.NoError(t, err)
+			result := []string{}
+			for _, c := range list {
+				result = append(result, c.ID)
+			}
+			return result
+		}
+		require.Len(t, ids(), 2)
+		require.Len(t, ids(withTags(nil)), 2)
+		require.ElementsMatch(t, []string{testid, testid2}, ids(withTags([]string{"work"})))
+		require.Equal(t, []string{testid}, ids(withTags([]string{"work", "go"})))
+		require.Equal(t, []string{testid2}, ids(withTags([]string{"go_lang"})))
+		require.Empty(t, ids(withTags([]string{"g_"})))
+
+		results, err := db.TagCompletions("go")
+		require.NoError(t, err)
+		require.Equal(t, []string{"go", "go_lang"}, results)
+
+		results, err = db.TagCompletions("")
+		require.NoError(t, err)
+		require.Equal(t, []string{"go", "go_lang", "review", "work"}, results)
+	})
+
 	t.Run("delete", func(t *testing.T) {
 		db := testDB(t)
 
@@ -145,6 +270,131 @@ func TestConvoDB(t *testing.T) {
 		require.Empty(t, list)
 	})
 
+	t.Run("filter by model and api", func(t *testing.T) {
+		db := testDB(t)
+
+		const testid2 = "6c33f71694bf41a18c844a96d1f62f153e5f6f44"
+		const testid3 = "fc5012d8c67073ea0a46a3c05488a0e1d87df74b"
+		require.NoError(t, db.Save(testid, "message 1", "gpt-4o"))
+		require.NoError(t, db.SetAPI(testid, "openai"))
+		require.NoError(t, db.Save(testid2, "message 2", "llama3"))
+		require.NoError(t, db.SetAPI(testid2, "ollama"))
+		require.NoError(t, db.Save(testid3, "message 3", "gpt-4o"))
+		require.NoError(t, db.SetAPI(testid3, "azure"))
+
+		ids := func(list []Conversation, err error) []string {
+			t.Helper()
+			require.NoError(t, err)
+			result := []string{}
+			for _, c := range list {
+				result = append(result, c.ID)
+			}
+			return result
+		}
+		require.ElementsMatch(t, []string{testid, testid3}, ids(db.ListByModel("gpt-4o")))
+		require.Equal(t, []string{testid2}, ids(db.ListByModel("llama3")))
+		require.Empty(t, ids(db.ListByModel("gpt-4")))
+		require.Equal(t, []string{testid3}, ids(db.ListByAPI("azure")))
+		require.Equal(t, []string{testid}, ids(db.List(withModel("gpt-4o"), withAPI("openai"))))
+		require.Len(t, ids(db.List(withModel(""), withAPI(""))), 3)
+	})
+
+	t.Run("rename model", func(t *testing.T) {
+		db := testDB(t)
+
+		const testid2 = "6c33f71694bf41a18c844a96d1f62f153e5f6f44"
+		const testid3 = "fc5012d8c67073ea0a46a3c05488a0e1d87df74b"
+		require.NoError(t, db.Save(testid, "message 1", "gpt-4"))
+		require.NoError(t, db.Save(testid2, "message 2", "gpt-4"))
+		require.NoError(t, db.Save(testid3, "message 3", "llama3"))
+
+		n, err := db.RenameModel("gpt-4", "gpt-4-turbo")
+		require.NoError(t, err)
+		require.Equal(t, 2, n)
+
+		list, err := db.ListByModel("gpt-4-turbo")
+		require.NoError(t, err)
+		require.Len(t, list, 2)
+		list, err = db.ListByModel("llama3")
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+
+		n, err = db.RenameModel("gpt-4", "gpt-4-turbo")
+		require.NoError(t, err)
+		require.Zero(t, n)
+	})
+
+	t.Run("sort", func(t *testing.T) {
+		db := testDB(t)
+
+		const testid2 = "6c33f71694bf41a18c844a96d1f62f153e5f6f44"
+		const testid3 = "fc5012d8c67073ea0a46a3c05488a0e1d87df74b"
+		require.NoError(t, db.Save(testid, "b title", "llama3"))
+		time.Sleep(2 * time.Millisecond)
+		require.NoError(t, db.Save(testid2, "c title", "gpt-4o"))
+		time.Sleep(2 * time.Millisecond)
+		require.NoError(t, db.Save(testid3, "a title", "mistral"))
+		time.Sleep(2 * time.Millisecond)
+		// updating a conversation doesn't change when it was created.
+		require.NoError(t, db.Save(testid, "b title", "llama3"))
+
+		ids := func(opts ...listOption) []string {
+			t.Helper()
+			list, err := db.List(opts...)
+			require.NoError(t, err)
+			result := []string{}
+			for _, c := range list {
+				result = append(result, c.ID)
+			}
+			return result
+		}
+		require.Equal(t, []string{testid, testid3, testid2}, ids())
+		require.Equal(t, []string{testid, testid3, testid2}, ids(withSort("updated", "")))
+		require.Equal(t, []string{testid2, testid3, testid}, ids(withSort("updated", "ASC")))
+		require.Equal(t, []string{testid3, testid2, testid}, ids(withSort("created", "")))
+		require.Equal(t, []string{testid, testid2, testid3}, ids(withSort("created", "ASC")))
+		require.Equal(t, []string{testid3, testid, testid2}, ids(withSort("title", "")))
+		require.Equal(t, []string{testid2, testid, testid3}, ids(withSort("title", "DESC")))
+		require.Equal(t, []string{testid2, testid, testid3}, ids(withSort("model", "")))
+		require.Equal(t, []string{testid3, testid, testid2}, ids(withSort("model", "DESC")))
+	})
+
+	t.Run("archive", func(t *testing.T) {
+		db := testDB(t)
+
+		const testid1 = "fc5012d8c67073ea0a46a3c05488a0e1d87df74b"
+		const testid2 = "6c33f71694bf41a18c844a96d1f62f153e5f6f44"
+		require.NoError(t, db.Save(testid1, "kept", "gpt-4o"))
+		require.NoError(t, db.Save(testid2, "archived", "gpt-4o"))
+		require.NoError(t, db.Archive(testid2))
+		require.ErrorIs(t, db.Archive(newConversationID()), errNoMatches)
+
+		list, err := db.List()
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+		require.Equal(t, testid1, list[0].ID)
+
+		list, err = db.List(withArchived())
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+		require.Equal(t, testid2, list[0].ID)
+		require.True(t, list[0].Archived)
+
+		list, err = db.ListOlderThan(-time.Hour, false)
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+		require.Equal(t, testid1, list[0].ID)
+
+		list, err = db.ListOlderThan(-time.Hour, true)
+		require.NoError(t, err)
+		require.Len(t, list, 2)
+
+		require.NoError(t, db.Unarchive(testid2))
+		list, err = db.List()
+		require.NoError(t, err)
+		require.Len(t, list, 2)
+	})
+
 	t.Run("completions", func(t *testing.T) {
 		db := testDB(t)
 
@@ -168,4 +418,217 @@ func TestConvoDB(t *testing.T) {
 			fmt.Sprintf("%s\t%s", testid1, title1),
 		}, results)
 	})
+
+	t.Run("search", func(t *testing.T) {
+		db := testDB(t)
+
+		const testid1 = "fc5012d8c67073ea0a46a3c05488a0e1d87df74b"
+		const testid2 = "6c33f71694bf41a18c844a96d1f62f153e5f6f44"
+		require.NoError(t, db.Save(testid1, "some title", "gpt-4o"))
+		require.NoError(t, db.Save(testid2, "football teams", "gpt-4o"))
+		require.NoError(t, db.Index(testid1, "how do I write a Makefile?\nLike this: 100% of the time"))
+
+		ids, err := db.Unindexed()
+		require.NoError(t, err)
+		require.Equal(t, []string{testid2}, ids)
+
+		search := func(q string) []string {
+			t.Helper()
+			results, err := db.Search(q)
+			require.NoError(t, err)
+			ids := []string{}
+			for _, r := range results {
+				ids = append(ids, r.ID)
+			}
+			return ids
+		}
+
+		require.Equal(t, []string{testid2}, search("FOOTBALL"))
+		require.Equal(t, []string{testid1}, search("makefile"))
+		require.Equal(t, []string{testid1}, search("100%"))
+		require.Empty(t, search("0%%"))
+		require.Empty(t, search("basketball"))
+
+		require.NoError(t, db.Delete(testid1))
+		require.Empty(t, search("makefile"))
+	})
+
+	t.Run("pin", func(t *testing.T) {
+		db := testDB(t)
+		const testid2 = "6c33f71694bf41a18c844a96d1f62f153e5f6f44"
+		const testid3 = "fc5012d8c67073ea0a46a3c05488a0e1d87df74b"
+		require.NoError(t, db.Save(testid, "a", "gpt-4o"))
+		require.NoError(t, db.Save(testid2, "b", "gpt-4o"))
+		require.NoError(t, db.Save(testid3, "c", "gpt-4o"))
+
+		titles := func(opts ...listOption) []string {
+			t.Helper()
+			list, err := db.List(opts...)
+			require.NoError(t, err)
+			titles := []string{}
+			for _, c := range list {
+				titles = append(titles, c.Title)
+			}
+			return titles
+		}
+		byTitle := withSort("title", "")
+		require.Equal(t, []string{"a", "b", "c"}, titles(byTitle))
+
+		require.NoError(t, db.Pin(testid3))
+		require.NoError(t, db.Pin(testid2))
+		require.Equal(t, []string{"b", "c", "a"}, titles(byTitle))
+		require.Equal(t, []string{"c", "b", "a"}, titles(withSort("title", "DESC")))
+
+		convo, err := db.Find(testid3)
+		require.NoError(t, err)
+		require.True(t, convo.Pinned)
+
+		require.NoError(t, db.Unpin(testid3))
+		require.Equal(t, []string{"b", "a", "c"}, titles(byTitle))
+		require.ErrorIs(t, db.Pin(newConversationID()), errNoMatches)
+	})
+
+	t.Run("pages", func(t *testing.T) {
+		db := testDB(t)
+		for i := 0; i < 5; i++ {
+			require.NoError(t, db.Save(newConversationID(), fmt.Sprintf("message %d", i), "gpt-4o"))
+		}
+
+		count, err := db.Count()
+		require.NoError(t, err)
+		require.Equal(t, 5, count)
+
+		titles := func(page int) []string {
+			t.Helper()
+			list, err := db.List(withSort("title", ""), withPage(page, 2))
+			require.NoError(t, err)
+			titles := []string{}
+			for _, c := range list {
+				titles = append(titles, c.Title)
+			}
+			return titles
+		}
+		require.Equal(t, []string{"message 0", "message 1"}, titles(1))
+		require.Equal(t, []string{"message 4"}, titles(3))
+		require.Empty(t, titles(4))
+	})
+
+	t.Run("vacuum", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "mods.db")
+		db, err := openDB(path)
+		require.NoError(t, err)
+		t.Cleanup(func() { require.NoError(t, db.Close()) })
+
+		var ids []string
+		for i := 0; i < 200; i++ {
+			id := newConversationID()
+			ids = append(ids, id)
+			require.NoError(t, db.Save(id, fmt.Sprintf("message %d", i), "gpt-4o"))
+			require.NoError(t, db.Index(id, strings.Repeat(fmt.Sprintf("word%d ", i), 500)))
+		}
+		for _, id := range ids {
+			require.NoError(t, db.Delete(id))
+		}
+
+		stats, err := db.Stats()
+		require.NoError(t, err)
+		require.True(t, stats.NeedsVacuum())
+		before, err := os.Stat(path)
+		require.NoError(t, err)
+
+		require.NoError(t, db.Vacuum())
+
+		after, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Less(t, after.Size(), before.Size())
+		stats, err = db.Stats()
+		require.NoError(t, err)
+		require.Zero(t, stats.FragmentedBytes)
+	})
+
+	t.Run("backup", func(t *testing.T) {
+		db := testDB(t)
+		require.NoError(t, db.Save(testid, "message 1", "gpt-4o"))
+		require.NoError(t, db.Save(newConversationID(), "message 2", "gpt-4o"))
+
+		path := filepath.Join(t.TempDir(), "backup.db")
+		require.NoError(t, db.Backup(path))
+		require.Error(t, db.Backup(path), "existing files are not overwritten")
+
+		backup, err := openDB(path)
+		require.NoError(t, err)
+		t.Cleanup(func() { require.NoError(t, backup.Close()) })
+
+		list, err := backup.List()
+		require.NoError(t, err)
+		require.Len(t, list, 2)
+	})
+}
+
+func TestConvoDBMigration(t *testing.T) {
+	const testid = "df31ae23ab8b75b5643c2f846c570997edc71333"
+	ds := filepath.Join(t.TempDir(), "mods.db")
+
+	// the schema before model, api, tokens_used, tags, archived and
+	// created_at were added.
+	old, err := sqlx.Open("sqlite", ds)
+	require.NoError(t, err)
+	_, err = old.Exec(`
+		CREATE TABLE
+		  conversations (
+		    id string NOT NULL PRIMARY KEY,
+		    title string NOT NULL,
+		    updated_at datetime NOT NULL DEFAULT (strftime ('%Y-%m-%d %H:%M:%f', 'now')),
+		    CHECK (id <> ''),
+		    CHECK (title <> '')
+		  )
+	`)
+	require.NoError(t, err)
+	_, err = old.Exec(`INSERT INTO conversations (id, title) VALUES ($1, 'old')`, testid)
+	require.NoError(t, err)
+	require.NoError(t, old.Close())
+
+	db, err := openDB(ds)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, db.Close()) })
+
+	convo, err := db.Find(testid)
+	require.NoError(t, err)
+	require.Equal(t, "old", convo.Title)
+	require.NotNil(t, convo.CreatedAt)
+	require.Equal(t, convo.UpdatedAt, *convo.CreatedAt)
+	require.Nil(t, convo.Model)
+	require.Nil(t, convo.Tags)
+	require.Empty(t, convo.TagList())
+
+	require.NoError(t, db.Tag(testid, []string{"legacy"}))
+	list, err := db.List(withTags([]string{"legacy"}))
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+}
+
+func TestPageBounds(t *testing.T) {
+	for name, tc := range map[string]struct {
+		page, size, limit, offset int
+	}{
+		"first":      {page: 1, size: 20, limit: 20, offset: 0},
+		"third":      {page: 3, size: 20, limit: 20, offset: 40},
+		"unset":      {page: 0, size: 20, limit: 20, offset: 0},
+		"negative":   {page: -2, size: 20, limit: 20, offset: 0},
+		"no paging":  {page: 3, size: 0, limit: 0, offset: 0},
+		"one a page": {page: 7, size: 1, limit: 1, offset: 6},
+	} {
+		t.Run(name, func(t *testing.T) {
+			limit, offset := pageBounds(tc.page, tc.size)
+			require.Equal(t, tc.limit, limit)
+			require.Equal(t, tc.offset, offset)
+		})
+	}
+}
+
+func TestPageCount(t *testing.T) {
+	require.Equal(t, 1, pageCount(0, 20))
+	require.Equal(t, 1, pageCount(20, 20))
+	require.Equal(t, 2, pageCount(21, 20))
+	require.Equal(t, 1, pageCount(50, 0))
 }
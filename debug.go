@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// maxDebugBody is how much of each request and response body --debug logs.
+const maxDebugBody = 64 * 1024
+
+const debugLogName = "mods_debug.log"
+
+// redactedHeaders are never written to the debug log.
+var redactedHeaders = map[string]bool{
+	"Authorization":  true,
+	"Api-Key":        true,
+	"X-Api-Key":      true,
+	"X-Goog-Api-Key": true,
+}
+
+// debugTransport logs requests and responses to a file.
+type debugTransport struct {
+	base http.RoundTripper
+	path string
+	mu   sync.Mutex
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("debugTransport: %w", err)
+		}
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	t.log(fmt.Sprintf("%s %s", req.Method, redactURL(req.URL.String())), req.Header, body)
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		t.log("ERROR", nil, []byte(err.Error()))
+		return resp, err //nolint:wrapcheck
+	}
+	resp.Body = &debugBody{
+		ReadCloser: resp.Body,
+		done: func(body []byte) {
+			t.log("RESPONSE "+resp.Status, resp.Header, body)
+		},
+	}
+	return resp, nil
+}
+
+func (t *debugTransport) log(title string, header http.Header, body []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s\n", time.Now().Format(time.RFC3339Nano), title)
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := strings.Join(header[k], ", ")
+		if redactedHeaders[http.CanonicalHeaderKey(k)] {
+			v = "[REDACTED]"
+		}
+		fmt.Fprintf(&b, "%s: %s\n", k, v)
+	}
+	if len(body) > maxDebugBody {
+		body = append(body[:maxDebugBody:maxDebugBody], []byte("\n[TRUNCATED]")...)
+	}
+	fmt.Fprintf(&b, "\n%s\n\n", body)
+
+	f, err := os.OpenFile(t.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600) //nolint:mnd
+	if err != nil {
+		return
+	}
+	_, _ = f.WriteString(b.String())
+	_ = f.Close()
+}
+
+// debugBody keeps what is read from a response body, so it can be logged
+// once it is closed without getting in the way of streaming.
+type debugBody struct {
+	io.ReadCloser
+	buf  bytes.Buffer
+	done func([]byte)
+	once sync.Once
+}
+
+func (b *debugBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if room := maxDebugBody + 1 - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(n, room)])
+	}
+	return n, err //nolint:wrapcheck
+}
+
+func (b *debugBody) Close() error {
+	b.once.Do(func() { b.done(b.buf.Bytes()) })
+	return b.ReadCloser.Close() //nolint:wrapcheck
+}
+
+// redactURL hides API keys given as query parameters, like Google's.
+func redactURL(u string) string {
+	before, query, ok := strings.Cut(u, "?")
+	if !ok {
+		return u
+	}
+	params := strings.Split(query, "&")
+	for i, p := range params {
+		if k, _, _ := strings.Cut(p, "="); k == "key" {
+			params[i] = "key=[REDACTED]"
+		}
+	}
+	return before + "?" + strings.Join(params, "&")
+}
+
+// withDebugLog wraps the given client so that its requests and responses are
+// logged to path.
+func withDebugLog(doer openai.HTTPDoer, path string) *http.Client {
+	client := cloneClient(doer)
+	client.Transport = &debugTransport{base: baseTransport(client), path: path}
+	return client
+}
+
+func debugLogPath(cachePath string) string {
+	return filepath.Join(cachePath, debugLogName)
+}
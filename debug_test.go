@@ -0,0 +1,68 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugLog(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("the response"))
+	}))
+	t.Cleanup(ts.Close)
+
+	path := debugLogPath(t.TempDir())
+	client := withDebugLog(&http.Client{}, path)
+
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/chat?key=google-secret&alt=sse", strings.NewReader(`{"model":"gpt-4o"}`))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer super-secret")
+	req.Header.Set("X-Api-Key", "another-secret")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	bts, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, "the response", string(bts))
+
+	log, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(log), `{"model":"gpt-4o"}`)
+	require.Contains(t, string(log), "RESPONSE 200 OK")
+	require.Contains(t, string(log), "the response")
+	require.Contains(t, string(log), "Authorization: [REDACTED]")
+	require.Contains(t, string(log), "key=[REDACTED]&alt=sse")
+	require.NotContains(t, string(log), "secret")
+}
+
+func TestDebugLogTruncates(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bts, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(bts)
+	}))
+	t.Cleanup(ts.Close)
+
+	path := filepath.Join(t.TempDir(), debugLogName)
+	client := withDebugLog(&http.Client{}, path)
+	body := strings.Repeat("a", maxDebugBody*2)
+	resp, err := client.Post(ts.URL, "text/plain", strings.NewReader(body))
+	require.NoError(t, err)
+	bts, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, body, string(bts))
+
+	log, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, 2, strings.Count(string(log), "[TRUNCATED]"))
+	require.Less(t, len(log), 3*maxDebugBody)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const deepseekBaseURL = "https://api.deepseek.com/v1"
+
+const (
+	thinkOpen  = "<think>"
+	thinkClose = "</think>"
+)
+
+// stripsThinking reports whether the <think> blocks of the given model should
+// be removed from its responses. --raw keeps them.
+func stripsThinking(mod Model, raw bool) bool {
+	return mod.API == "deepseek" && strings.Contains(mod.Name, "reasoner") && !raw
+}
+
+// thinkStripper removes <think>…</think> spans from streamed text, even when
+// the tags are split across chunks.
+type thinkStripper struct {
+	thinking bool
+	pending  string
+}
+
+// write returns the text of chunk that is outside of <think> blocks. Text
+// that could be the start of a tag is held back until the next chunk.
+func (s *thinkStripper) write(chunk string) string {
+	s.pending += chunk
+	var out strings.Builder
+	for {
+		tag := thinkOpen
+		if s.thinking {
+			tag = thinkClose
+		}
+		if i := strings.Index(s.pending, tag); i >= 0 {
+			if !s.thinking {
+				out.WriteString(s.pending[:i])
+			}
+			s.pending = s.pending[i+len(tag):]
+			s.thinking = !s.thinking
+			continue
+		}
+
+		keep := partialTagLen(s.pending, tag)
+		if !s.thinking {
+			out.WriteString(s.pending[:len(s.pending)-keep])
+		}
+		s.pending = s.pending[len(s.pending)-keep:]
+		return out.String()
+	}
+}
+
+// flush returns the text held back by write, if it was not a tag after all.
+func (s *thinkStripper) flush() string {
+	rest := s.pending
+	s.pending = ""
+	if s.thinking {
+		return ""
+	}
+	return rest
+}
+
+// partialTagLen returns the length of the longest suffix of s that is a
+// prefix of tag.
+func partialTagLen(s, tag string) int {
+	for n := min(len(s), len(tag)-1); n > 0; n-- {
+		if strings.HasSuffix(s, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// thinkStrippingStream removes the <think> blocks from the responses of the
+// wrapped stream.
+type thinkStrippingStream struct {
+	chatCompletionReceiver
+	stripper thinkStripper
+	done     bool
+}
+
+func (s *thinkStrippingStream) Recv() (openai.ChatCompletionStreamResponse, error) {
+	if s.done {
+		return openai.ChatCompletionStreamResponse{}, io.EOF
+	}
+	resp, err := s.chatCompletionReceiver.Recv()
+	if errors.Is(err, io.EOF) {
+		s.done = true
+		if rest := s.stripper.flush(); rest != "" {
+			return openai.ChatCompletionStreamResponse{
+				Choices: []openai.ChatCompletionStreamChoice{
+					{
+						Delta: openai.ChatCompletionStreamChoiceDelta{
+							Content: rest,
+							Role:    openai.ChatMessageRoleAssistant,
+						},
+					},
+				},
+			}, nil
+		}
+	}
+	if err != nil {
+		return resp, err //nolint:wrapcheck
+	}
+	for i := range resp.Choices {
+		resp.Choices[i].Delta.Content = s.stripper.write(resp.Choices[i].Delta.Content)
+	}
+	return resp, nil
+}
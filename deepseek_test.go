@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+// chunksStream streams the given chunks, one per response.
+type chunksStream struct {
+	chunks []string
+}
+
+func (s *chunksStream) Close() error { return nil }
+func (s *chunksStream) Recv() (openai.ChatCompletionStreamResponse, error) {
+	if len(s.chunks) == 0 {
+		return openai.ChatCompletionStreamResponse{}, io.EOF
+	}
+	chunk := s.chunks[0]
+	s.chunks = s.chunks[1:]
+	return openai.ChatCompletionStreamResponse{
+		Choices: []openai.ChatCompletionStreamChoice{
+			{Delta: openai.ChatCompletionStreamChoiceDelta{Content: chunk}},
+		},
+	}, nil
+}
+
+func TestThinkStrippingStream(t *testing.T) {
+	for name, tc := range map[string]struct {
+		chunks []string
+		want   string
+	}{
+		"no thinking": {
+			chunks: []string{"4 is ", "the answer"},
+			want:   "4 is the answer",
+		},
+		"one chunk": {
+			chunks: []string{"<think>2+2 is 4</think>4"},
+			want:   "4",
+		},
+		"split tags": {
+			chunks: []string{"<th", "ink>2+2", " is 4</thi", "nk>", "4 <", "b>ok</b>"},
+			want:   "4 <b>ok</b>",
+		},
+		"unterminated": {
+			chunks: []string{"<think>hmm", "mm"},
+			want:   "",
+		},
+		"trailing partial tag": {
+			chunks: []string{"a < b", " and 1 <"},
+			want:   "a < b and 1 <",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			stream := &thinkStrippingStream{chatCompletionReceiver: &chunksStream{chunks: tc.chunks}}
+			var got strings.Builder
+			for {
+				resp, err := stream.Recv()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				require.NoError(t, err)
+				got.WriteString(resp.Choices[0].Delta.Content)
+			}
+			require.Equal(t, tc.want, got.String())
+		})
+	}
+}
+
+func TestStripsThinking(t *testing.T) {
+	require.True(t, stripsThinking(Model{API: "deepseek", Name: "deepseek-reasoner"}, false))
+	require.False(t, stripsThinking(Model{API: "deepseek", Name: "deepseek-reasoner"}, true))
+	require.False(t, stripsThinking(Model{API: "deepseek", Name: "deepseek-chat"}, false))
+	require.False(t, stripsThinking(Model{API: "openai", Name: "reasoner"}, false))
+}
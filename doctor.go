@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// doctorTimeout is how long doctor waits for each API to answer.
+const doctorTimeout = 5 * time.Second
+
+// defaultKeyEnvs are the environment variables API keys are read from when
+// an API doesn't say otherwise.
+var defaultKeyEnvs = map[string]string{
+	"anthropic":  "ANTHROPIC_API_KEY",
+	"azure":      "AZURE_OPENAI_KEY",
+	"azure-ad":   "AZURE_OPENAI_KEY",
+	"cohere":     "COHERE_API_KEY",
+	"deepseek":   "DEEPSEEK_API_KEY",
+	"google":     "GOOGLE_API_KEY",
+	"groq":       "GROQ_API_KEY",
+	"mistral":    "MISTRAL_API_KEY",
+	"openai":     "OPENAI_API_KEY",
+	"openrouter": "OPENROUTER_API_KEY",
+	"together":   "TOGETHER_API_KEY",
+	"xai":        "XAI_API_KEY",
+}
+
+type checkStatus int
+
+const (
+	checkOK checkStatus = iota
+	checkWarn
+	checkFail
+)
+
+type doctorCheck struct {
+	name   string
+	status checkStatus
+	detail string
+}
+
+// runDoctor checks the environment mods runs in, prints the results to w,
+// and returns whether none of the checks failed.
+func runDoctor(w io.Writer, r *lipgloss.Renderer, cfg *Config, db *convoDB, client *http.Client) bool {
+	checks := []doctorCheck{
+		{name: "Go", detail: runtime.Version()},
+		{name: "OS", detail: runtime.GOOS + "/" + runtime.GOARCH},
+		terminalCheck(r),
+		configCheck(cfg.SettingsPath),
+		databaseCheck(db),
+	}
+	// only problems with the API of the default model fail, the others may
+	// well be unused.
+	defaultAPI := cfg.Models[cfg.Model].API
+	if defaultAPI == "" {
+		defaultAPI = cfg.API
+	}
+	for _, api := range cfg.APIs {
+		severity := checkWarn
+		if api.Name == defaultAPI {
+			severity = checkFail
+		}
+		checks = append(checks, reachabilityCheck(client, api, severity))
+		if check, ok := apiKeyCheck(api, severity); ok {
+			checks = append(checks, check)
+		}
+	}
+	checks = append(checks, editorCheck())
+
+	marks := map[checkStatus]string{
+		checkOK:   r.NewStyle().Foreground(lipgloss.Color("#00AF87")).Render("✓"),
+		checkWarn: r.NewStyle().Foreground(lipgloss.Color("#FFAF00")).Render("!"),
+		checkFail: r.NewStyle().Foreground(lipgloss.Color("#FF5F87")).Render("✗"),
+	}
+	healthy := true
+	for _, c := range checks {
+		fmt.Fprintf(w, "%s %s: %s\n", marks[c.status], c.name, c.detail)
+		if c.status == checkFail {
+			healthy = false
+		}
+	}
+	return healthy
+}
+
+func terminalCheck(r *lipgloss.Renderer) doctorCheck {
+	term := os.Getenv("TERM")
+	if term == "" {
+		term = "unknown"
+	}
+	colors := map[termenv.Profile]string{
+		termenv.TrueColor: "true color",
+		termenv.ANSI256:   "256 colors",
+		termenv.ANSI:      "16 colors",
+		termenv.Ascii:     "no colors",
+	}
+	return doctorCheck{
+		name:   "Terminal",
+		detail: fmt.Sprintf("%s, %s", term, colors[r.ColorProfile()]),
+	}
+}
+
+func configCheck(path string) doctorCheck {
+	check := doctorCheck{name: "Settings"}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		check.status = checkFail
+		check.detail = err.Error()
+		return check
+	}
+	if problems := validateConfig(content); len(problems) > 0 {
+		check.status = checkFail
+		check.detail = fmt.Sprintf("%s has %d problems, run mods validate-config", path, len(problems))
+		return check
+	}
+	check.detail = path
+	return check
+}
+
+func databaseCheck(db *convoDB) doctorCheck {
+	check := doctorCheck{name: "Database"}
+	if db == nil {
+		check.status = checkFail
+		check.detail = "could not be opened"
+		return check
+	}
+	stats, err := db.Stats()
+	if err != nil {
+		check.status = checkFail
+		check.detail = err.Error()
+		return check
+	}
+	check.detail = fmt.Sprintf("%d conversations", stats.Conversations)
+	return check
+}
+
+// reachabilityCheck sends a HEAD request to the base URL of api. Any answer
+// will do, as most APIs don't like HEAD requests without a key.
+func reachabilityCheck(client *http.Client, api API, severity checkStatus) doctorCheck {
+	check := doctorCheck{name: "API " + api.Name}
+	if api.BaseURL == "" {
+		check.detail = "uses the default endpoint"
+		return check
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, api.BaseURL, nil)
+	if err != nil {
+		check.status = checkFail
+		check.detail = err.Error()
+		return check
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		check.status = severity
+		check.detail = fmt.Sprintf("%s is not reachable", api.BaseURL)
+		return check
+	}
+	_ = resp.Body.Close()
+	check.detail = fmt.Sprintf("%s answered %s", api.BaseURL, resp.Status)
+	return check
+}
+
+// apiKeyCheck tells where the key of api comes from, without running any
+// commands to get it. APIs that don't need a key have no check.
+func apiKeyCheck(api API, severity checkStatus) (doctorCheck, bool) {
+	check := doctorCheck{name: "API key " + api.Name}
+	if api.Name == "copilot" {
+		check.name = "Copilot token"
+		host, err := copilotHostFor(api)
+		if err == nil && !hasAPIKey(api) {
+			var token string
+			token, err = getCopilotAuthToken(host)
+			if err == nil && token != "" {
+				check.detail = fmt.Sprintf("%s for %s", lastFour(token), host)
+				return check, true
+			}
+		}
+		if hasAPIKey(api) {
+			return apiKeySource(check, api, "GITHUB_TOKEN", severity), true
+		}
+		check.status = severity
+		check.detail = "not signed in to Copilot, see mods copilot-status"
+		return check, true
+	}
+	env, ok := defaultKeyEnvs[api.Name]
+	if !ok && !hasAPIKey(api) {
+		return check, false
+	}
+	return apiKeySource(check, api, env, severity), true
+}
+
+func apiKeySource(check doctorCheck, api API, defaultEnv string, severity checkStatus) doctorCheck {
+	switch {
+	case api.APIKey != "":
+		check.detail = lastFour(api.APIKey) + " in the settings"
+	case api.APIKeyEnv != "" && api.APIKeyCmd == "":
+		if key := os.Getenv(api.APIKeyEnv); key != "" {
+			check.detail = fmt.Sprintf("%s from $%s", lastFour(key), api.APIKeyEnv)
+		} else {
+			check.status = severity
+			check.detail = fmt.Sprintf("$%s is not set", api.APIKeyEnv)
+		}
+	case api.APIKeyCmd != "":
+		check.detail = "from api-key-cmd"
+	case api.OPItem != "":
+		check.detail = "from 1Password"
+	case api.APIKeySecretARN != "":
+		check.detail = "from AWS Secrets Manager"
+	case defaultEnv != "" && os.Getenv(defaultEnv) != "":
+		check.detail = fmt.Sprintf("%s from $%s", lastFour(os.Getenv(defaultEnv)), defaultEnv)
+	default:
+		check.status = severity
+		check.detail = fmt.Sprintf("not set, set $%s or api-key", defaultEnv)
+	}
+	return check
+}
+
+// lastFour hides all but the last four characters of a key.
+func lastFour(key string) string {
+	const visible = 4
+	if len(key) <= visible*2 { //nolint:mnd
+		return strings.Repeat("*", len(key))
+	}
+	return "****" + key[len(key)-visible:]
+}
+
+func editorCheck() doctorCheck {
+	check := doctorCheck{name: "Editor"}
+	if editor := os.Getenv("EDITOR"); editor != "" {
+		check.detail = editor
+		return check
+	}
+	check.status = checkWarn
+	check.detail = "$EDITOR is not set, mods --settings will use nano"
+	return check
+}
+
+//nolint:mnd
+func isDoctorCmd(args []string) bool {
+	return len(args) == 2 && args[1] == "doctor"
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLastFour(t *testing.T) {
+	require.Equal(t, "****6789", lastFour("sk-0123456789"))
+	require.Equal(t, "*****", lastFour("short"))
+}
+
+func TestAPIKeyCheck(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "sk-0123456789")
+	t.Setenv("MY_KEY", "")
+
+	check, ok := apiKeyCheck(API{Name: "openai"}, checkFail)
+	require.True(t, ok)
+	require.Equal(t, checkOK, check.status)
+	require.Equal(t, "****6789 from $OPENAI_API_KEY", check.detail)
+
+	check, ok = apiKeyCheck(API{Name: "groq", APIKeyEnv: "MY_KEY"}, checkWarn)
+	require.True(t, ok)
+	require.Equal(t, checkWarn, check.status)
+
+	check, ok = apiKeyCheck(API{Name: "custom", APIKeyCmd: "pass show key"}, checkFail)
+	require.True(t, ok)
+	require.Equal(t, checkOK, check.status)
+	require.Equal(t, "from api-key-cmd", check.detail)
+
+	_, ok = apiKeyCheck(API{Name: "ollama"}, checkFail)
+	require.False(t, ok)
+}
+
+func TestRunDoctor(t *testing.T) {
+	t.Setenv("EDITOR", "vim")
+	t.Setenv("OPENAI_API_KEY", "sk-0123456789")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	t.Cleanup(ts.Close)
+
+	settings := filepath.Join(t.TempDir(), "mods.yml")
+	require.NoError(t, os.WriteFile(settings, []byte("default-model: gpt-4o\n"), 0o644))
+
+	cfg := &Config{
+		SettingsPath: settings,
+		Model:        "gpt-4o",
+		Models:       map[string]Model{"gpt-4o": {Name: "gpt-4o", API: "openai"}},
+		APIs: APIs{
+			{Name: "openai", BaseURL: ts.URL},
+			{Name: "groq", BaseURL: "http://127.0.0.1:1"},
+		},
+	}
+	r := lipgloss.NewRenderer(nil, termenv.WithProfile(termenv.Ascii))
+
+	var b strings.Builder
+	require.True(t, runDoctor(&b, r, cfg, testDB(t), ts.Client()))
+	out := b.String()
+	require.Contains(t, out, "✓ Settings: "+settings)
+	require.Contains(t, out, "✓ Database: 0 conversations")
+	require.Contains(t, out, "✓ API openai: "+ts.URL+" answered 401 Unauthorized")
+	require.Contains(t, out, "✓ API key openai: ****6789 from $OPENAI_API_KEY")
+	require.Contains(t, out, "! API groq: http://127.0.0.1:1 is not reachable")
+	require.Contains(t, out, "! API key groq: not set")
+	require.Contains(t, out, "✓ Editor: vim")
+	require.NotContains(t, out, "sk-0123456789")
+
+	b.Reset()
+	require.False(t, runDoctor(&b, r, cfg, nil, ts.Client()))
+	require.Contains(t, b.String(), "✗ Database: could not be opened")
+}
+
+func TestIsDoctorCmd(t *testing.T) {
+	require.True(t, isDoctorCmd([]string{"mods", "doctor"}))
+	require.False(t, isDoctorCmd([]string{"mods", "doctor", "me"}))
+	require.False(t, isDoctorCmd([]string{"mods"}))
+}
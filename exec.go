@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/caarlos0/go-shellwords"
+)
+
+// defaultExecTimeout is used when no exec-timeout is configured.
+const defaultExecTimeout = 5 * time.Minute
+
+// runExecCmd runs the given command and returns its combined output, preceded
+// by a header with the command and, if it failed, its exit code. The command
+// is killed after timeout.
+func runExecCmd(command string, timeout time.Duration) (string, error) {
+	if timeout <= 0 {
+		timeout = defaultExecTimeout
+	}
+
+	args, err := shellwords.Parse(command)
+	if err != nil {
+		return "", fmt.Errorf("runExecCmd: %w", err)
+	}
+	if len(args) == 0 {
+		return "", errors.New("runExecCmd: empty command")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput() //nolint:gosec
+	header := "### $ " + command
+	var eerr *exec.ExitError
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return "", fmt.Errorf("runExecCmd: command timed out after %s", timeout)
+	case errors.As(err, &eerr):
+		header += fmt.Sprintf(" (exit code %d)", eerr.ExitCode())
+	case err != nil:
+		return "", fmt.Errorf("runExecCmd: %w", err)
+	}
+	return header + "\n" + string(out), nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunExecCmd(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+
+	t.Run("success", func(t *testing.T) {
+		out, err := runExecCmd(`sh -c "echo hello; echo oops >&2"`, 0)
+		require.NoError(t, err)
+		require.Equal(t, "### $ sh -c \"echo hello; echo oops >&2\"\nhello\noops\n", out)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		out, err := runExecCmd(`sh -c "echo broken; exit 3"`, 0)
+		require.NoError(t, err)
+		require.Equal(t, "### $ sh -c \"echo broken; exit 3\" (exit code 3)\nbroken\n", out)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := runExecCmd("this-command-does-not-exist-mods", 0)
+		require.Error(t, err)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		_, err := runExecCmd("sleep 5", 50*time.Millisecond)
+		require.ErrorContains(t, err, "timed out")
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		_, err := runExecCmd("  ", 0)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	"gopkg.in/yaml.v3"
+)
+
+var exportFormats = []string{"markdown", "json"}
+
+var (
+	errNoMessages  = errors.New("no messages found")
+	errFrontMatter = errors.New("unterminated front matter")
+)
+
+type exportFrontMatter struct {
+	ID        string    `yaml:"id"`
+	Title     string    `yaml:"title"`
+	Model     string    `yaml:"model,omitempty"`
+	API       string    `yaml:"api,omitempty"`
+	UpdatedAt time.Time `yaml:"updated_at"`
+}
+
+// exportConversation writes the given conversation to w in the given format.
+func exportConversation(
+	w io.Writer,
+	format string,
+	convo Conversation,
+	messages []openai.ChatCompletionMessage,
+) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(messages); err != nil {
+			return fmt.Errorf("exportConversation: %w", err)
+		}
+		return nil
+	case "markdown":
+		fm := exportFrontMatter{
+			ID:        convo.ID,
+			Title:     convo.Title,
+			UpdatedAt: convo.UpdatedAt.UTC(),
+		}
+		if convo.Model != nil {
+			fm.Model = *convo.Model
+		}
+		if convo.API != nil {
+			fm.API = *convo.API
+		}
+		bts, err := yaml.Marshal(fm)
+		if err != nil {
+			return fmt.Errorf("exportConversation: %w", err)
+		}
+		if _, err := fmt.Fprintf(w, "---\n%s---\n", bts); err != nil {
+			return fmt.Errorf("exportConversation: %w", err)
+		}
+		for _, msg := range messages {
+			if _, err := io.WriteString(w, messageMarkdown(msg)); err != nil {
+				return fmt.Errorf("exportConversation: %w", err)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("exportConversation: unknown format %q", format)
+	}
+}
+
+// markdownRoles maps the role prefixes written by messageMarkdown back to
+// their roles.
+var markdownRoles = map[string]string{
+	"**System**: ":    openai.ChatMessageRoleSystem,
+	"**Prompt**: ":    openai.ChatMessageRoleUser,
+	"**Assistant**: ": openai.ChatMessageRoleAssistant,
+	"**Function**: ":  openai.ChatMessageRoleFunction,
+	"**Tool**: ":      openai.ChatMessageRoleTool,
+}
+
+// importFormat returns the export format of the file at path, based on its
+// extension.
+func importFormat(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return "json", nil
+	case ".md", ".markdown":
+		return "markdown", nil
+	default:
+		return "", fmt.Errorf("importFormat: unknown format for %q", path)
+	}
+}
+
+// importConversation reads a conversation written by exportConversation.
+// Only markdown exports have front matter.
+func importConversation(
+	r io.Reader,
+	format string,
+) (exportFrontMatter, []openai.ChatCompletionMessage, error) {
+	var fm exportFrontMatter
+	bts, err := io.ReadAll(r)
+	if err != nil {
+		return fm, nil, fmt.Errorf("importConversation: %w", err)
+	}
+
+	switch format {
+	case "json":
+		var messages []openai.ChatCompletionMessage
+		if err := json.Unmarshal(bts, &messages); err != nil {
+			return fm, nil, fmt.Errorf("importConversation: %w", err)
+		}
+		return fm, messages, nil
+	case "markdown":
+		if rest, ok := bytes.CutPrefix(bts, []byte("---\n")); ok {
+			front, body, ok := bytes.Cut(rest, []byte("\n---\n"))
+			if !ok {
+				return fm, nil, fmt.Errorf("importConversation: %w", errFrontMatter)
+			}
+			if err := yaml.Unmarshal(front, &fm); err != nil {
+				return fm, nil, fmt.Errorf("importConversation: %w", err)
+			}
+			bts = body
+		}
+		messages, err := parseMarkdownMessages(string(bts))
+		if err != nil {
+			return fm, nil, fmt.Errorf("importConversation: %w", err)
+		}
+		return fm, messages, nil
+	default:
+		return fm, nil, fmt.Errorf("importConversation: unknown format %q", format)
+	}
+}
+
+// parseMarkdownMessages parses the messages written by messageMarkdown.
+func parseMarkdownMessages(s string) ([]openai.ChatCompletionMessage, error) {
+	var messages []openai.ChatCompletionMessage
+	var content []string
+	flush := func() {
+		if len(messages) > 0 {
+			messages[len(messages)-1].Content = strings.Trim(strings.Join(content, "\n"), "\n")
+		}
+		content = nil
+	}
+
+	for _, line := range strings.Split(s, "\n") {
+		if prefix, role, ok := markdownRole(line); ok {
+			flush()
+			messages = append(messages, openai.ChatCompletionMessage{Role: role})
+			line = strings.TrimPrefix(line, prefix)
+		}
+		content = append(content, line)
+	}
+	flush()
+
+	if len(messages) == 0 {
+		return nil, errNoMessages
+	}
+	return messages, nil
+}
+
+func markdownRole(line string) (string, string, bool) {
+	for prefix, role := range markdownRoles {
+		if strings.HasPrefix(line, prefix) {
+			return prefix, role, true
+		}
+	}
+	return "", "", false
+}
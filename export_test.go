@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestExportConversation(t *testing.T) {
+	const testid = "df31ae23ab8b75b5643c2f846c570997edc71333"
+	messages := []openai.ChatCompletionMessage{
+		{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: "you are a calculator",
+		},
+		{
+			Role:    openai.ChatMessageRoleUser,
+			Content: "first 4 natural numbers",
+		},
+		{
+			Role:    openai.ChatMessageRoleAssistant,
+			Content: "1, 2, 3, 4",
+		},
+	}
+
+	db := testDB(t)
+	cache := newCache(t.TempDir())
+	require.NoError(t, db.Save(testid, "numbers", "gpt-4o"))
+	require.NoError(t, db.SetAPI(testid, "openai"))
+	require.NoError(t, cache.write(testid, &messages))
+
+	convo, err := db.Find(testid)
+	require.NoError(t, err)
+	var saved []openai.ChatCompletionMessage
+	require.NoError(t, cache.read(convo.ID, &saved))
+
+	t.Run("json", func(t *testing.T) {
+		var b bytes.Buffer
+		require.NoError(t, exportConversation(&b, "json", *convo, saved))
+
+		var result []openai.ChatCompletionMessage
+		require.NoError(t, json.Unmarshal(b.Bytes(), &result))
+		require.Equal(t, messages, result)
+	})
+
+	t.Run("markdown", func(t *testing.T) {
+		var b bytes.Buffer
+		require.NoError(t, exportConversation(&b, "markdown", *convo, saved))
+
+		parts := strings.SplitN(b.String(), "---\n", 3)
+		require.Len(t, parts, 3)
+		require.Empty(t, parts[0])
+
+		var fm exportFrontMatter
+		require.NoError(t, yaml.Unmarshal([]byte(parts[1]), &fm))
+		require.Equal(t, testid, fm.ID)
+		require.Equal(t, "numbers", fm.Title)
+		require.Equal(t, "gpt-4o", fm.Model)
+		require.Equal(t, "openai", fm.API)
+		require.Equal(t, convo.UpdatedAt.Unix(), fm.UpdatedAt.Unix())
+
+		require.Equal(t, strings.Join([]string{
+			"",
+			"**System**: you are a calculator",
+			"",
+			"**Prompt**: first 4 natural numbers",
+			"",
+			"**Assistant**: 1, 2, 3, 4",
+			"",
+		}, "\n"), parts[2])
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		require.Error(t, exportConversation(&bytes.Buffer{}, "html", *convo, saved))
+	})
+}
+
+func TestImportConversation(t *testing.T) {
+	const testid = "df31ae23ab8b75b5643c2f846c570997edc71333"
+	messages := []openai.ChatCompletionMessage{
+		{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: "you are a calculator",
+		},
+		{
+			Role:    openai.ChatMessageRoleUser,
+			Content: "first 4 natural numbers\n\nas a list",
+		},
+		{
+			Role:    openai.ChatMessageRoleAssistant,
+			Content: "- 1\n- 2\n- 3\n- 4",
+		},
+	}
+
+	db := testDB(t)
+	cache := newCache(t.TempDir())
+	require.NoError(t, db.Save(testid, "numbers", "gpt-4o"))
+	require.NoError(t, db.SetAPI(testid, "openai"))
+	require.NoError(t, cache.write(testid, &messages))
+	convo, err := db.Find(testid)
+	require.NoError(t, err)
+
+	for _, format := range exportFormats {
+		t.Run(format, func(t *testing.T) {
+			var b bytes.Buffer
+			require.NoError(t, exportConversation(&b, format, *convo, messages))
+
+			fm, result, err := importConversation(&b, format)
+			require.NoError(t, err)
+			require.Len(t, result, len(messages))
+			require.Equal(t, messages, result)
+			if format == "markdown" {
+				require.Equal(t, "numbers", fm.Title)
+				require.Equal(t, "gpt-4o", fm.Model)
+				require.Equal(t, "openai", fm.API)
+			}
+		})
+	}
+
+	t.Run("no messages", func(t *testing.T) {
+		_, _, err := importConversation(strings.NewReader("just some text"), "markdown")
+		require.ErrorIs(t, err, errNoMessages)
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		_, err := importFormat("convo.html")
+		require.Error(t, err)
+		_, _, err = importConversation(strings.NewReader(""), "html")
+		require.Error(t, err)
+	})
+}
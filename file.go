@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxFileSize is the largest file we are willing to read with --file.
+const maxFileSize = 20 * 1024 * 1024
+
+// textSniffLen is how many bytes are checked to decide if a file is text.
+const textSniffLen = 512
+
+// readFiles reads the given files and returns their contents, each one
+// preceded by a header with its name. Binary files are refused unless force
+// is set.
+func readFiles(paths []string, force bool) (string, error) {
+	parts := make([]string, 0, len(paths))
+	for _, path := range paths {
+		content, err := readTextFile(path, force)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, "### "+path+"\n"+content)
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
+// readPromptFile reads the prompt given with --prompt-file, from stdin if
+// path is "-".
+func readPromptFile(path string, stdin io.Reader) (string, error) {
+	if path == "-" {
+		bts, err := io.ReadAll(io.LimitReader(stdin, maxFileSize))
+		if err != nil {
+			return "", fmt.Errorf("readPromptFile: %w", err)
+		}
+		return removeWhitespace(string(bts)), nil
+	}
+	content, err := readTextFile(path, false)
+	if err != nil {
+		return "", err
+	}
+	return removeWhitespace(content), nil
+}
+
+func readTextFile(path string, force bool) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("readTextFile: %w", err)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("readTextFile: %s is a directory", path)
+	}
+	if info.Size() > maxFileSize {
+		return "", fmt.Errorf("readTextFile: %s is larger than %d bytes", path, maxFileSize)
+	}
+	bts, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("readTextFile: %w", err)
+	}
+	if !force && !isText(bts) {
+		return "", fmt.Errorf("readTextFile: %s does not look like a text file", path)
+	}
+	return string(bts), nil
+}
+
+// isText reports whether the start of data is valid UTF-8 without NUL bytes.
+func isText(data []byte) bool {
+	if len(data) > textSniffLen {
+		data = data[:textSniffLen]
+		// do not fail on a rune cut in half by the sniff length.
+		for i := 0; i < utf8.UTFMax-1 && len(data) > 0; i++ {
+			if r, _ := utf8.DecodeLastRune(data); r != utf8.RuneError {
+				break
+			}
+			data = data[:len(data)-1]
+		}
+	}
+	return utf8.Valid(data) && !strings.ContainsRune(string(data), 0)
+}
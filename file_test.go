@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content []byte) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, content, 0o644))
+		return path
+	}
+
+	gofile := write("main.go", []byte("package main\n"))
+	readme := write("README.md", []byte("# Hello\n"))
+	bin := write("app.bin", []byte{0x7f, 'E', 'L', 'F', 0, 0, 0xff, 0xfe})
+	// a multi-byte rune crossing the sniff length must not count as binary.
+	long := write("long.txt", []byte(strings.Repeat("a", textSniffLen-1)+"é and more"))
+
+	t.Run("text files", func(t *testing.T) {
+		content, err := readFiles([]string{gofile, readme}, false)
+		require.NoError(t, err)
+		require.Equal(t, "### "+gofile+"\npackage main\n\n\n### "+readme+"\n# Hello\n", content)
+	})
+
+	t.Run("rune across sniff length", func(t *testing.T) {
+		_, err := readFiles([]string{long}, false)
+		require.NoError(t, err)
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		_, err := readFiles([]string{gofile, bin}, false)
+		require.ErrorContains(t, err, "does not look like a text file")
+	})
+
+	t.Run("binary forced", func(t *testing.T) {
+		content, err := readFiles([]string{bin}, true)
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(content, "### "+bin+"\n"))
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		_, err := readFiles([]string{filepath.Join(dir, "nope")}, false)
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("directory", func(t *testing.T) {
+		_, err := readFiles([]string{dir}, false)
+		require.ErrorContains(t, err, "is a directory")
+	})
+}
+
+func TestReadPromptFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "prompt.md")
+	prompt := "Review this code.\n\nFocus on:\n- errors\n- naming\n"
+	require.NoError(t, os.WriteFile(path, []byte(prompt), 0o644))
+
+	t.Run("file", func(t *testing.T) {
+		got, err := readPromptFile(path, strings.NewReader("ignored"))
+		require.NoError(t, err)
+		require.Equal(t, prompt, got)
+	})
+
+	t.Run("stdin", func(t *testing.T) {
+		got, err := readPromptFile("-", strings.NewReader(prompt))
+		require.NoError(t, err)
+		require.Equal(t, prompt, got)
+	})
+
+	t.Run("blank", func(t *testing.T) {
+		got, err := readPromptFile("-", strings.NewReader(" \n\n"))
+		require.NoError(t, err)
+		require.Empty(t, got)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		_, err := readPromptFile(filepath.Join(dir, "nope.md"), nil)
+		require.Error(t, err)
+	})
+}
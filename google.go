@@ -5,9 +5,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
+	"strings"
 
 	openai "github.com/sashabaranov/go-openai"
 )
@@ -17,8 +20,10 @@ var googleHeaderData = []byte("data: ")
 // GoogleClientConfig represents the configuration for the Google API client.
 type GoogleClientConfig struct {
 	BaseURL            string
+	AuthToken          string
 	HTTPClient         *http.Client
 	EmptyMessagesLimit uint
+	SafetySettings     []GoogleSafetySetting
 }
 
 // DefaultGoogleConfig returns the default configuration for the Google API client.
@@ -56,8 +61,44 @@ type GoogleGenerationConfig struct {
 type GoogleMessageCompletionRequest struct {
 	Contents         []GoogleContent        `json:"contents,omitempty"`
 	GenerationConfig GoogleGenerationConfig `json:"generationConfig,omitempty"`
+	Tools            []GoogleTool           `json:"tools,omitempty"`
+	SafetySettings   []GoogleSafetySetting  `json:"safetySettings,omitempty"`
 }
 
+// GoogleSafetySetting is the threshold above which responses of a harm
+// category are blocked.
+type GoogleSafetySetting struct {
+	Category  string `json:"category"`
+	Threshold string `json:"threshold"`
+}
+
+// googleSafetySettings converts the safety-settings of an API, sorted by
+// category.
+func googleSafetySettings(settings map[string]string) []GoogleSafetySetting {
+	if len(settings) == 0 {
+		return nil
+	}
+	result := make([]GoogleSafetySetting, 0, len(settings))
+	for category, threshold := range settings {
+		result = append(result, GoogleSafetySetting{
+			Category:  strings.ToUpper(category),
+			Threshold: strings.ToUpper(threshold),
+		})
+	}
+	slices.SortFunc(result, func(a, b GoogleSafetySetting) int {
+		return strings.Compare(a.Category, b.Category)
+	})
+	return result
+}
+
+// GoogleTool is a tool the model may use to answer.
+type GoogleTool struct {
+	GoogleSearch *GoogleSearch `json:"googleSearch,omitempty"`
+}
+
+// GoogleSearch grounds the response with Google Search results.
+type GoogleSearch struct{}
+
 // GoogleRequestBuilder is an interface for building HTTP requests for the Google API.
 type GoogleRequestBuilder interface {
 	Build(ctx context.Context, method, url string, body any, header http.Header) (*http.Request, error)
@@ -126,6 +167,24 @@ type GoogleCandidate struct {
 	FinishReason string        `json:"finishReason,omitempty"`
 	TokenCount   uint          `json:"tokenCount,omitempty"`
 	Index        uint          `json:"index,omitempty"`
+
+	GroundingMetadata *GoogleGroundingMetadata `json:"groundingMetadata,omitempty"`
+}
+
+// GoogleGroundingMetadata lists the sources a grounded response is based on.
+type GoogleGroundingMetadata struct {
+	GroundingChunks []GoogleGroundingChunk `json:"groundingChunks,omitempty"`
+}
+
+// GoogleGroundingChunk is a source of a grounded response.
+type GoogleGroundingChunk struct {
+	Web *GoogleWebSource `json:"web,omitempty"`
+}
+
+// GoogleWebSource is a web page a grounded response is based on.
+type GoogleWebSource struct {
+	URI   string `json:"uri,omitempty"`
+	Title string `json:"title,omitempty"`
 }
 
 // GoogleCompletionMessageResponse represents a response to an Google completion message.
@@ -147,6 +206,9 @@ type googleStreamReader struct {
 	errAccumulator ErrorAccumulator
 	unmarshaler    Unmarshaler
 
+	// sources are the web pages a grounded response is based on.
+	sources []GoogleWebSource
+
 	httpHeader
 }
 
@@ -216,6 +278,9 @@ func (stream *googleStreamReader) processLines() (openai.ChatCompletionStreamRes
 		if len(chunk.Candidates) == 0 {
 			continue
 		}
+		if meta := chunk.Candidates[0].GroundingMetadata; meta != nil {
+			stream.addSources(meta.GroundingChunks)
+		}
 		parts := chunk.Candidates[0].Content.Parts
 		if len(parts) == 0 {
 			continue
@@ -236,8 +301,26 @@ func (stream *googleStreamReader) processLines() (openai.ChatCompletionStreamRes
 	}
 }
 
+// addSources keeps the web sources of the given chunks, once each.
+func (stream *googleStreamReader) addSources(chunks []GoogleGroundingChunk) {
+	for _, chunk := range chunks {
+		if chunk.Web == nil || chunk.Web.URI == "" {
+			continue
+		}
+		if slices.ContainsFunc(stream.sources, func(s GoogleWebSource) bool {
+			return s.URI == chunk.Web.URI
+		}) {
+			continue
+		}
+		stream.sources = append(stream.sources, *chunk.Web)
+	}
+}
+
 func googleSendRequestStream(client *GoogleClient, req *http.Request) (*googleStreamReader, error) {
 	req.Header.Set("content-type", "application/json")
+	if client.config.AuthToken != "" {
+		req.Header.Set("Authorization", "Bearer "+client.config.AuthToken)
+	}
 
 	resp, err := client.config.HTTPClient.Do(req) //nolint:bodyclose // body is closed in stream.Close()
 	if err != nil {
@@ -278,3 +361,44 @@ func (c *GoogleClient) CreateChatCompletionStream(
 	}
 	return
 }
+
+// googleCitationStream adds the sources of a grounded response as a list of
+// citations once the response is over.
+type googleCitationStream struct {
+	*GoogleChatCompletionStream
+	cited bool
+}
+
+// Recv reads the next response from the stream, and the citations after the
+// last one.
+func (s *googleCitationStream) Recv() (openai.ChatCompletionStreamResponse, error) {
+	resp, err := s.GoogleChatCompletionStream.Recv()
+	if !errors.Is(err, io.EOF) || s.cited || len(s.sources) == 0 {
+		return resp, err
+	}
+	s.cited = true
+	return openai.ChatCompletionStreamResponse{
+		Choices: []openai.ChatCompletionStreamChoice{
+			{
+				Delta: openai.ChatCompletionStreamChoiceDelta{
+					Content: googleCitations(s.sources),
+					Role:    "assistant",
+				},
+			},
+		},
+	}, nil
+}
+
+// googleCitations renders the given sources as a footer of markdown links.
+func googleCitations(sources []GoogleWebSource) string {
+	var sb strings.Builder
+	sb.WriteString("\n\nSources:\n\n")
+	for i, source := range sources {
+		title := source.Title
+		if title == "" {
+			title = source.URI
+		}
+		fmt.Fprintf(&sb, "%d. [%s](%s)\n", i+1, title, source.URI)
+	}
+	return sb.String()
+}
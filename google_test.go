@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoogleGrounding(t *testing.T) {
+	var body map[string]any
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, `data: {"candidates":[{"content":{"parts":[{"text":"It is sunny."}],"role":"model"}}]}`+"\n\n")
+		_, _ = io.WriteString(w, `data: {"candidates":[{"content":{"parts":[{"text":""}],"role":"model"},"groundingMetadata":{"groundingChunks":[`+
+			`{"web":{"uri":"https://weather.example/lisbon","title":"weather.example"}},`+
+			`{"web":{"uri":"https://weather.example/lisbon","title":"weather.example"}},`+
+			`{"web":{"uri":"https://news.example/today"}}]}}]}`+"\n\n")
+	}))
+	t.Cleanup(ts.Close)
+
+	newGoogleMods := func(grounding, format bool) *Mods {
+		return newMods(lipgloss.DefaultRenderer(), &Config{
+			Model:     "gemini",
+			Quiet:     true,
+			NoLimit:   true,
+			Grounding: grounding,
+			Format:    format,
+			Models: map[string]Model{
+				"gemini": {Name: "gemini-2.0-flash", API: "vertexai", MaxChars: 100000},
+				"gpt":    {Name: "gpt-4o", API: "openai"},
+			},
+			APIs: APIs{
+				{Name: "vertexai", BaseURL: ts.URL, APIKey: "google-key"},
+				{Name: "openai", BaseURL: ts.URL, APIKey: "openai-key"},
+			},
+		}, nil, nil)
+	}
+
+	t.Run("tools", func(t *testing.T) {
+		mods := newGoogleMods(true, false)
+		require.Nil(t, mods.runHeadless("what's the weather in lisbon?"))
+		require.Equal(t, []any{map[string]any{"googleSearch": map[string]any{}}}, body["tools"])
+		require.Equal(t, "It is sunny.", mods.Output)
+	})
+
+	t.Run("citations", func(t *testing.T) {
+		mods := newGoogleMods(true, true)
+		require.Nil(t, mods.runHeadless("what's the weather in lisbon?"))
+		require.Contains(t, mods.Output, "It is sunny.\n\nSources:\n\n"+
+			"1. [weather.example](https://weather.example/lisbon)\n"+
+			"2. [https://news.example/today](https://news.example/today)\n")
+	})
+
+	t.Run("off", func(t *testing.T) {
+		mods := newGoogleMods(false, false)
+		require.Nil(t, mods.runHeadless("what's the weather in lisbon?"))
+		require.NotContains(t, body, "tools")
+	})
+
+	t.Run("other APIs", func(t *testing.T) {
+		mods := newGoogleMods(true, false)
+		mods.Config.Model = "gpt"
+		err := mods.runHeadless("what's the weather in lisbon?")
+		require.NotNil(t, err)
+		require.Contains(t, err.reason, "does not support")
+	})
+}
+
+func TestGoogleSafetySettings(t *testing.T) {
+	t.Run("mapping", func(t *testing.T) {
+		require.Nil(t, googleSafetySettings(nil))
+		require.Equal(t, []GoogleSafetySetting{
+			{Category: "HARM_CATEGORY_DANGEROUS_CONTENT", Threshold: "BLOCK_NONE"},
+			{Category: "HARM_CATEGORY_HARASSMENT", Threshold: "BLOCK_ONLY_HIGH"},
+		}, googleSafetySettings(map[string]string{
+			"harm_category_harassment":        "block_only_high",
+			"HARM_CATEGORY_DANGEROUS_CONTENT": "BLOCK_NONE",
+		}))
+	})
+
+	t.Run("request", func(t *testing.T) {
+		var body map[string]any
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = io.WriteString(w, `data: {"candidates":[{"content":{"parts":[{"text":"hi"}],"role":"model"}}]}`+"\n\n")
+		}))
+		t.Cleanup(ts.Close)
+
+		mods := newMods(lipgloss.DefaultRenderer(), &Config{
+			Model:   "gemini",
+			Quiet:   true,
+			NoLimit: true,
+			Models: map[string]Model{
+				"gemini": {Name: "gemini-2.0-flash", API: "vertexai", MaxChars: 100000},
+			},
+			APIs: APIs{{
+				Name:           "vertexai",
+				BaseURL:        ts.URL,
+				APIKey:         "google-key",
+				SafetySettings: map[string]string{"HARM_CATEGORY_DANGEROUS_CONTENT": "BLOCK_NONE"},
+			}},
+		}, nil, nil)
+		require.Nil(t, mods.runHeadless("hello"))
+		require.Equal(t, []any{map[string]any{
+			"category":  "HARM_CATEGORY_DANGEROUS_CONTENT",
+			"threshold": "BLOCK_NONE",
+		}}, body["safetySettings"])
+	})
+}
@@ -0,0 +1,3 @@
+package main
+
+const groqBaseURL = "https://api.groq.com/openai/v1"
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// maxImageSize is the largest image file we are willing to send.
+const maxImageSize = 20 * 1024 * 1024
+
+var supportedImageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// processImageFiles reads the given image files and converts them into
+// message parts that can be attached to a user message.
+func processImageFiles(paths []string) ([]openai.ChatMessagePart, error) {
+	parts := make([]openai.ChatMessagePart, 0, len(paths))
+	for _, path := range paths {
+		mimeType, data, err := readImageFile(path)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, openai.ChatMessagePart{
+			Type: openai.ChatMessagePartTypeImageURL,
+			ImageURL: &openai.ChatMessageImageURL{
+				URL:    createDataURI(mimeType, data),
+				Detail: openai.ImageURLDetailAuto,
+			},
+		})
+	}
+	return parts, nil
+}
+
+func readImageFile(path string) (string, []byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("readImageFile: %w", err)
+	}
+	if info.Size() > maxImageSize {
+		return "", nil, fmt.Errorf("readImageFile: %s is larger than %d bytes", path, maxImageSize)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("readImageFile: %w", err)
+	}
+	mimeType := http.DetectContentType(data)
+	if !supportedImageTypes[mimeType] {
+		return "", nil, fmt.Errorf("readImageFile: %s has unsupported type %s", path, mimeType)
+	}
+	return mimeType, data, nil
+}
+
+func createDataURI(mimeType string, data []byte) string {
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// supportsImages reports whether mods knows how to send images to the given
+// model.
+func supportsImages(mod Model) bool {
+	switch mod.API {
+	case "google", "vertexai", "bedrock", "cohere":
+		return false
+	case "xai":
+		return xaiSupportsImages(mod.Name)
+	default:
+		return true
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessImageFiles(t *testing.T) {
+	t.Run("png", func(t *testing.T) {
+		parts, err := processImageFiles([]string{filepath.Join("testdata", "image.png")})
+		require.NoError(t, err)
+		require.Len(t, parts, 1)
+		require.Equal(t, openai.ChatMessagePartTypeImageURL, parts[0].Type)
+		require.True(t, strings.HasPrefix(parts[0].ImageURL.URL, "data:image/png;base64,"))
+	})
+
+	t.Run("not an image", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "foo.txt")
+		require.NoError(t, os.WriteFile(path, []byte("just text"), 0o644))
+		_, err := processImageFiles([]string{path})
+		require.ErrorContains(t, err, "unsupported type")
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		_, err := processImageFiles([]string{filepath.Join(t.TempDir(), "nope.png")})
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestSetupStreamContextImages(t *testing.T) {
+	mods := &Mods{Config: &Config{
+		NoLimit: true,
+		Images:  []string{filepath.Join("testdata", "image.png")},
+	}}
+	require.NoError(t, mods.setupStreamContext("describe this", Model{}))
+	require.Len(t, mods.messages, 1)
+
+	msg := mods.messages[0]
+	require.Empty(t, msg.Content)
+	require.Len(t, msg.MultiContent, 2)
+	require.Equal(t, "describe this", msg.MultiContent[0].Text)
+	require.Equal(t, openai.ChatMessagePartTypeImageURL, msg.MultiContent[1].Type)
+	require.Equal(t, "describe this", lastPrompt(mods.messages))
+}
+
+func TestAnthropicMessages(t *testing.T) {
+	t.Run("text", func(t *testing.T) {
+		messages, err := anthropicMessages([]openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleUser, Content: "hi"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []AnthropicInputMessage{
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: []AnthropicInputContent{{Type: "text", Text: "hi"}},
+			},
+		}, messages)
+	})
+
+	t.Run("image", func(t *testing.T) {
+		parts, err := processImageFiles([]string{filepath.Join("testdata", "image.png")})
+		require.NoError(t, err)
+		messages, err := anthropicMessages([]openai.ChatCompletionMessage{
+			{
+				Role: openai.ChatMessageRoleUser,
+				MultiContent: append([]openai.ChatMessagePart{
+					{Type: openai.ChatMessagePartTypeText, Text: "describe this"},
+				}, parts...),
+			},
+		})
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		require.Len(t, messages[0].Content, 2)
+		require.Equal(t, AnthropicInputContent{Type: "text", Text: "describe this"}, messages[0].Content[0])
+
+		image := messages[0].Content[1]
+		require.Equal(t, "image", image.Type)
+		require.Equal(t, "base64", image.Source.Type)
+		require.Equal(t, "image/png", image.Source.MediaType)
+		require.Equal(t, strings.TrimPrefix(parts[0].ImageURL.URL, "data:image/png;base64,"), image.Source.Data)
+	})
+
+	t.Run("invalid data uri", func(t *testing.T) {
+		_, err := anthropicMessages([]openai.ChatCompletionMessage{
+			{
+				Role: openai.ChatMessageRoleUser,
+				MultiContent: []openai.ChatMessagePart{
+					{
+						Type:     openai.ChatMessagePartTypeImageURL,
+						ImageURL: &openai.ChatMessageImageURL{URL: "data:image/png,notbase64"},
+					},
+				},
+			},
+		})
+		require.ErrorContains(t, err, "invalid data URI")
+	})
+}
+
+func TestOllamaMessages(t *testing.T) {
+	parts, err := processImageFiles([]string{filepath.Join("testdata", "image.png")})
+	require.NoError(t, err)
+	data := strings.TrimPrefix(parts[0].ImageURL.URL, "data:image/png;base64,")
+
+	t.Run("user image", func(t *testing.T) {
+		messages, err := ollamaMessages([]openai.ChatCompletionMessage{
+			{
+				Role: openai.ChatMessageRoleUser,
+				MultiContent: append([]openai.ChatMessagePart{
+					{Type: openai.ChatMessagePartTypeText, Text: "describe this"},
+				}, parts...),
+			},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []OllamaInputMessage{
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: "describe this",
+				Images:  []string{data},
+			},
+		}, messages)
+	})
+
+	t.Run("only user messages", func(t *testing.T) {
+		messages, err := ollamaMessages([]openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, MultiContent: parts},
+			{Role: openai.ChatMessageRoleAssistant, Content: "hi"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []OllamaInputMessage{
+			{Role: openai.ChatMessageRoleSystem},
+			{Role: openai.ChatMessageRoleAssistant, Content: "hi"},
+		}, messages)
+	})
+
+	t.Run("url", func(t *testing.T) {
+		_, err := ollamaMessages([]openai.ChatCompletionMessage{
+			{
+				Role: openai.ChatMessageRoleUser,
+				MultiContent: []openai.ChatMessagePart{
+					{
+						Type:     openai.ChatMessagePartTypeImageURL,
+						ImageURL: &openai.ChatMessageImageURL{URL: "https://example.com/image.png"},
+					},
+				},
+			},
+		})
+		require.ErrorContains(t, err, "base64 data URIs")
+	})
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// discardLogger is used when there is no --log-file.
+var discardLogger = slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+// openLog opens path for appending JSON lines to, and returns a logger that
+// writes to it, along with a function that flushes and closes it.
+func openLog(path string) (*slog.Logger, func(), error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600) //nolint:mnd
+	if err != nil {
+		return nil, nil, modsError{err, "Couldn't open the log file."}
+	}
+	var once sync.Once
+	return newJSONLogger(f), func() {
+		once.Do(func() {
+			_ = f.Sync()
+			_ = f.Close()
+		})
+	}, nil
+}
+
+// newJSONLogger returns a logger writing JSON lines to w, with the time under
+// "ts".
+func newJSONLogger(w io.Writer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				a.Key = "ts"
+			}
+			return a
+		},
+	}))
+}
+
+// log returns the --log-file logger, with the model of the run once it is
+// known.
+func (m *Mods) log() *slog.Logger {
+	if m.logger == nil {
+		return discardLogger
+	}
+	if m.model.Name == "" {
+		return m.logger
+	}
+	return m.logger.With("model", m.model.Name, "api", m.model.API)
+}
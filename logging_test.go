@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mods.log")
+	logger, closeLog, err := openLog(path)
+	require.NoError(t, err)
+	logger.Info("config loaded", "model", "gpt-4o")
+	closeLog()
+	closeLog()
+
+	bts, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(bts, &line))
+	require.Equal(t, "INFO", line["level"])
+	require.Equal(t, "config loaded", line["msg"])
+	require.Equal(t, "gpt-4o", line["model"])
+	require.Contains(t, line, "ts")
+
+	_, _, err = openLog(filepath.Join(t.TempDir(), "missing", "mods.log"))
+	require.Error(t, err)
+}
+
+func TestRequestLog(t *testing.T) {
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if hits.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = fmt.Fprint(w, `{"error":{"message":"overloaded","type":"server_error"}}`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hi\"}}]}\n\n")
+		_, _ = fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":12,\"completion_tokens\":3,\"total_tokens\":15}}\n\n")
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(ts.Close)
+
+	cfg := &Config{
+		Model:      "gpt-4o",
+		Quiet:      true,
+		MaxRetries: 2,
+		NoCache:    true,
+		Models: map[string]Model{
+			"gpt-4o": {Name: "gpt-4o", API: "openai", MaxChars: 1000},
+		},
+		APIs: APIs{
+			{Name: "openai", BaseURL: ts.URL, APIKey: "fake"},
+		},
+	}
+	var b bytes.Buffer
+	mods := newMods(lipgloss.DefaultRenderer(), cfg, nil, nil)
+	mods.logger = newJSONLogger(&b)
+	require.Nil(t, mods.runHeadless("hello"))
+
+	var lines []map[string]any
+	for _, l := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		var line map[string]any
+		require.NoError(t, json.Unmarshal([]byte(l), &line))
+		require.Equal(t, "gpt-4o", line["model"])
+		require.Equal(t, "openai", line["api"])
+		lines = append(lines, line)
+	}
+	msgs := make([]string, 0, len(lines))
+	for _, line := range lines {
+		msgs = append(msgs, line["msg"].(string))
+	}
+	require.Equal(t, []string{
+		"request started",
+		"request failed",
+		"retrying request",
+		"request started",
+		"request completed",
+	}, msgs)
+	require.Equal(t, "500", lines[1]["status"])
+	require.Equal(t, "server_error", lines[2]["reason"])
+	require.Equal(t, float64(2), lines[3]["attempt"])
+	require.Equal(t, float64(12), lines[4]["input_tokens"])
+	require.Equal(t, float64(3), lines[4]["output_tokens"])
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const defaultLogProbsPath = "mods.logprobs.json"
+
+// logProbsPath returns where --logprobs writes to: next to the --output file
+// if there's one, or in the current directory otherwise.
+func logProbsPath(output string) string {
+	if output == "" {
+		return defaultLogProbsPath
+	}
+	return output + ".logprobs.json"
+}
+
+// writeLogProbs writes the given log probabilities to path as JSON.
+func writeLogProbs(path string, logprobs []openai.ChatCompletionTokenLogprob) error {
+	if err := checkOutputDir(path); err != nil {
+		return err
+	}
+	if logprobs == nil {
+		logprobs = []openai.ChatCompletionTokenLogprob{}
+	}
+	bts, err := json.MarshalIndent(logprobs, "", "  ")
+	if err != nil {
+		return modsError{err, "Couldn't encode the log probabilities."}
+	}
+	if err := os.WriteFile(path, bts, 0o644); err != nil { //nolint:gosec,mnd
+		return modsError{err, "Couldn't write the log probabilities file."}
+	}
+	return nil
+}
+
+// streamLogProbs converts the log probabilities of a non-streamed response
+// into the streamed format.
+func streamLogProbs(lp *openai.LogProbs) []openai.ChatCompletionTokenLogprob {
+	if lp == nil {
+		return nil
+	}
+	result := make([]openai.ChatCompletionTokenLogprob, 0, len(lp.Content))
+	for _, c := range lp.Content {
+		top := make([]openai.ChatCompletionTokenLogprobTopLogprob, 0, len(c.TopLogProbs))
+		for _, t := range c.TopLogProbs {
+			top = append(top, openai.ChatCompletionTokenLogprobTopLogprob{
+				Token:   t.Token,
+				Logprob: t.LogProb,
+			})
+		}
+		result = append(result, openai.ChatCompletionTokenLogprob{
+			Token:       c.Token,
+			Logprob:     c.LogProb,
+			TopLogprobs: top,
+		})
+	}
+	return result
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogProbs(t *testing.T) {
+	var req openai.ChatCompletionRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, token := range []string{"Hello", "!"} {
+			_, _ = fmt.Fprintf(
+				w,
+				"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":%q},\"logprobs\":{\"content\":[{\"token\":%q,\"logprob\":-0.5,\"top_logprobs\":[]}]}}]}\n\n",
+				token, token,
+			)
+		}
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(ts.Close)
+
+	cfg := &Config{
+		Model:       "gpt-4o",
+		Prefix:      "hi",
+		Quiet:       true,
+		TopLogProbs: 2,
+		Models: map[string]Model{
+			"gpt-4o": {Name: "gpt-4o", API: "openai", MaxChars: 1000},
+		},
+		APIs: APIs{
+			{Name: "openai", BaseURL: ts.URL, APIKey: "fake"},
+		},
+	}
+	mods := newMods(lipgloss.DefaultRenderer(), cfg, nil, nil)
+	p := tea.NewProgram(
+		mods,
+		tea.WithInput(nil),
+		tea.WithOutput(io.Discard),
+		tea.WithoutRenderer(),
+	)
+	_, err := p.Run()
+	require.NoError(t, err)
+	require.Nil(t, mods.Error)
+	require.True(t, req.LogProbs)
+	require.Equal(t, 2, req.TopLogProbs)
+	require.Equal(t, "Hello!", mods.Output)
+	require.Len(t, mods.logprobs, 2)
+	require.Equal(t, "Hello", mods.logprobs[0].Token)
+
+	path := logProbsPath(filepath.Join(t.TempDir(), "out.md"))
+	require.True(t, filepath.IsAbs(path))
+	require.NoError(t, writeLogProbs(path, mods.logprobs))
+	bts, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var got []openai.ChatCompletionTokenLogprob
+	require.NoError(t, json.Unmarshal(bts, &got))
+	require.Equal(t, mods.logprobs, got)
+}
+
+func TestLogProbsPath(t *testing.T) {
+	require.Equal(t, defaultLogProbsPath, logProbsPath(""))
+	require.Equal(t, "out.md.logprobs.json", logProbsPath("out.md"))
+}
+
+func TestStreamLogProbs(t *testing.T) {
+	require.Nil(t, streamLogProbs(nil))
+	require.Equal(t, []openai.ChatCompletionTokenLogprob{
+		{
+			Token:   "hi",
+			Logprob: -0.1,
+			TopLogprobs: []openai.ChatCompletionTokenLogprobTopLogprob{
+				{Token: "hey", Logprob: -2},
+			},
+		},
+	}, streamLogProbs(&openai.LogProbs{
+		Content: []openai.LogProb{
+			{
+				Token:       "hi",
+				LogProb:     -0.1,
+				TopLogProbs: []openai.TopLogProbs{{Token: "hey", LogProb: -2}},
+			},
+		},
+	}))
+}
@@ -1,15 +1,19 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"path/filepath"
 	"runtime/debug"
 	"runtime/pprof"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/atotto/clipboard"
 	timeago "github.com/caarlos0/timea.go"
@@ -20,6 +24,7 @@ import (
 	mcobra "github.com/muesli/mango-cobra"
 	"github.com/muesli/roff"
 	"github.com/muesli/termenv"
+	openai "github.com/sashabaranov/go-openai"
 	"github.com/spf13/cobra"
 )
 
@@ -76,7 +81,17 @@ var (
 		SilenceErrors: true,
 		Example:       randomExample(),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if config.VersionJSON {
+				return printVersionJSON(os.Stdout)
+			}
 			config.Prefix = removeWhitespace(strings.Join(args, " "))
+			if config.PromptFile != "" {
+				prompt, err := readPromptFile(config.PromptFile, os.Stdin)
+				if err != nil {
+					return modsError{err, "Could not read the prompt file."}
+				}
+				config.Prefix = prompt
+			}
 
 			opts := []tea.ProgramOption{}
 
@@ -106,7 +121,99 @@ var (
 				}
 			}
 
+			if config.Export != "" {
+				if err := validateExport(); err != nil {
+					return err
+				}
+			}
+			if !slices.Contains(listSortNames, config.Sort) {
+				return modsError{
+					err: newUserErrorf(
+						"Valid sort orders are: %s.",
+						strings.Join(listSortNames, ", "),
+					),
+					reason: fmt.Sprintf(
+						"Unknown sort order %s.",
+						stdoutStyles().InlineCode.Render(config.Sort),
+					),
+				}
+			}
+			if config.FilterModel != "" || config.FilterAPI != "" {
+				if err := validateFilters(); err != nil {
+					return err
+				}
+			}
+			if config.Page < 0 || config.PageSize < 1 {
+				return modsError{
+					err: newUserErrorf("Pages are counted from 1, and hold at least 1 conversation."),
+					reason: fmt.Sprintf(
+						"Invalid %s or %s.",
+						stdoutStyles().InlineCode.Render("--page"),
+						stdoutStyles().InlineCode.Render("--page-size"),
+					),
+				}
+			}
+			if cmd.Flags().Changed("rename") {
+				if err := validateRename(); err != nil {
+					return err
+				}
+			}
+
+			if config.ConversationID != "" {
+				if err := validateConversationID(); err != nil {
+					return err
+				}
+			}
+			if config.Completions < 1 {
+				return modsError{
+					err: newUserErrorf("Ask for at least 1 completion."),
+					reason: fmt.Sprintf(
+						"Invalid %s %d.",
+						stdoutStyles().InlineCode.Render("--completions"),
+						config.Completions,
+					),
+				}
+			}
+			if err := validateWatch(); err != nil {
+				return err
+			}
+
+			if config.Seed >= 0 && config.NoCache && !config.Quiet {
+				fmt.Fprintf(
+					os.Stderr,
+					"Responses are seeded, but %s is set, so the conversation won't be saved.\n",
+					stderrStyles().InlineCode.Render("--no-cache"),
+				)
+			}
+
 			mods := newMods(stderrRenderer(), &config, db, cache)
+			if config.LogFile != "" {
+				logger, closeLog, err := openLog(config.LogFile)
+				if err != nil {
+					return err
+				}
+				defer closeLog()
+				mods.logger, mods.closeLog = logger, closeLog
+				logger.Info("config loaded", "model", config.Model, "api", config.API, "settings", config.SettingsPath)
+			}
+			if config.MetricsAddr != "" {
+				stop, err := serveMetrics(config.MetricsAddr)
+				if err != nil {
+					return err
+				}
+				defer stop()
+				mods.stopMetrics = stop
+			}
+			if endpoint := otlpEndpoint(&config); endpoint != "" {
+				flush, err := setupTracing(cmd.Context(), endpoint)
+				if err != nil {
+					return err
+				}
+				defer flush()
+			}
+			ctx, span := tracer().Start(cmd.Context(), "mods.request")
+			defer span.End()
+			mods.ctx = ctx
 			p := tea.NewProgram(mods, opts...)
 			m, err := p.Run()
 			if err != nil {
@@ -180,25 +287,118 @@ var (
 			if config.ListRoles {
 				return listRoles()
 			}
+			if config.ListModels {
+				printModels(os.Stdout, config.APIs, config.Raw)
+				return nil
+			}
 			if config.List {
 				return listConversations()
 			}
+			if config.ListArchived {
+				return listConversations(withArchived())
+			}
+			if config.Search != "" {
+				return searchConversations(config.Search)
+			}
+			if config.Stats {
+				return showStats()
+			}
 
 			if config.Delete != "" {
 				return deleteConversation()
 			}
 
+			if config.Fork != "" {
+				return forkConversation()
+			}
+
+			if config.MigrateModel != "" {
+				return migrateModel()
+			}
+
+			if config.BackupDB != "" {
+				return backupDB()
+			}
+
+			if config.Import != "" {
+				return importSavedConversation(config.Import)
+			}
+
+			if config.Archive != "" {
+				return archiveConversation(config.Archive, true)
+			}
+			if config.Unarchive != "" {
+				return archiveConversation(config.Unarchive, false)
+			}
+			if config.Pin != "" {
+				return pinConversation(config.Pin, true)
+			}
+			if config.Unpin != "" {
+				return pinConversation(config.Unpin, false)
+			}
+
 			if config.DeleteOlderThan > 0 {
 				return deleteConversationOlderThan()
 			}
 
+			if config.Vacuum {
+				return vacuumDB()
+			}
+
+			if config.Rename != "" {
+				return renameConversation(config.cacheReadFromID)
+			}
+
+			if config.Export != "" {
+				return exportSavedConversation(config.cacheReadFromID)
+			}
+
+			if config.Verbose && !config.Quiet {
+				defer printVerbose(os.Stderr, mods)
+			}
+			if config.ShowTokens && !config.Quiet {
+				defer printTokenUsage(os.Stderr, mods)
+			}
+			if !config.Quiet {
+				defer printCostWarning(os.Stderr, mods)
+			}
+
+			if config.DryRun {
+				if err := printDryRun(os.Stdout, mods); err != nil {
+					return modsError{err, "Couldn't print the request."}
+				}
+				return nil
+			}
+
+			if config.EstimateTokens {
+				printEstimate(os.Stdout, mods.messages, mods.model, config.Raw)
+				return nil
+			}
+
+			if len(config.Parallel) > 0 {
+				return showParallel(mods)
+			}
+			if config.Times > 1 {
+				return showTimes(mods)
+			}
+
+			var printed string
 			if isOutputTTY() {
 				switch {
 				case mods.glamOutput != "":
-					fmt.Print(mods.glamOutput)
+					printed = mods.glamOutput
 				case mods.Output != "":
-					fmt.Print(mods.Output)
+					printed = mods.Output
 				}
+				fmt.Print(printed)
+			}
+
+			if err := writeResponse(mods); err != nil {
+				return err
+			}
+
+			if config.Watch {
+				return watch(os.Stdout, mods, printed)
 			}
 
 			if config.Show != "" || config.ShowLast {
@@ -206,6 +406,11 @@ var (
 			}
 
 			if config.cacheWriteToID != "" {
+				if len(mods.completions) > 1 && isInputTTY() && isOutputTTY() && !config.Raw {
+					if err := pickCompletion(mods); err != nil {
+						return err
+					}
+				}
 				return saveConversation(mods)
 			}
 
@@ -216,44 +421,159 @@ var (
 
 var memprofile bool
 
+// writeResponse writes the response to --output, its log probabilities to
+// --logprobs and copies it with --copy.
+func writeResponse(mods *Mods) error {
+	switch {
+	case config.OutputFile != "" && config.Append:
+		if err := appendOutputFile(config.OutputFile, mods.Output, config.AppendSeparator); err != nil {
+			return err
+		}
+	case config.OutputFile != "":
+		if err := writeOutputFile(config.OutputFile, mods.Output); err != nil {
+			return err
+		}
+	}
+
+	if config.LogProbs || config.TopLogProbs > 0 {
+		path := logProbsPath(config.OutputFile)
+		if err := writeLogProbs(path, mods.logprobs); err != nil {
+			return err
+		}
+		if !config.Quiet {
+			fmt.Fprintln(os.Stderr, "\nLog probabilities written to:", path)
+		}
+	}
+
+	if config.Copy && mods.Output != "" {
+		// not being able to copy should not lose the response.
+		if err := copyOutput(mods.Output); err != nil {
+			handleError(err)
+		}
+	}
+	return nil
+}
+
 func initFlags() {
 	flags := rootCmd.Flags()
 	flags.StringVarP(&config.Model, "model", "m", config.Model, stdoutStyles().FlagDesc.Render(help["model"]))
+	flags.StringSliceVar(&config.Parallel, "parallel", config.Parallel, stdoutStyles().FlagDesc.Render(help["parallel"]))
 	flags.BoolVarP(&config.AskModel, "ask-model", "M", config.AskModel, stdoutStyles().FlagDesc.Render(help["ask-model"]))
 	flags.StringVarP(&config.API, "api", "a", config.API, stdoutStyles().FlagDesc.Render(help["api"]))
 	flags.StringVarP(&config.HTTPProxy, "http-proxy", "x", config.HTTPProxy, stdoutStyles().FlagDesc.Render(help["http-proxy"]))
+	flags.DurationVar(&config.Timeout, "timeout", config.Timeout, stdoutStyles().FlagDesc.Render(help["timeout"]))
 	flags.BoolVarP(&config.Format, "format", "f", config.Format, stdoutStyles().FlagDesc.Render(help["format"]))
 	flags.StringVar(&config.FormatAs, "format-as", config.FormatAs, stdoutStyles().FlagDesc.Render(help["format-as"]))
 	flags.BoolVarP(&config.Raw, "raw", "r", config.Raw, stdoutStyles().FlagDesc.Render(help["raw"]))
+	flags.StringVarP(&config.OutputFile, "output", "o", config.OutputFile, stdoutStyles().FlagDesc.Render(help["output"]))
+	flags.BoolVar(&config.Append, "append", config.Append, stdoutStyles().FlagDesc.Render(help["append"]))
+	flags.StringVar(&config.AppendSeparator, "append-separator", defaultAppendSeparator, stdoutStyles().FlagDesc.Render(help["append-separator"]))
+	flags.BoolVar(&config.Copy, "copy", config.Copy, stdoutStyles().FlagDesc.Render(help["copy"]))
+	flags.BoolVar(&config.LogProbs, "logprobs", config.LogProbs, stdoutStyles().FlagDesc.Render(help["logprobs"]))
+	flags.IntVar(&config.TopLogProbs, "top-logprobs", config.TopLogProbs, stdoutStyles().FlagDesc.Render(help["top-logprobs"]))
 	flags.IntVarP(&config.IncludePrompt, "prompt", "P", config.IncludePrompt, stdoutStyles().FlagDesc.Render(help["prompt"]))
+	flags.StringVar(&config.IncludePromptRange, "prompt-range", config.IncludePromptRange, stdoutStyles().FlagDesc.Render(help["prompt-range"]))
+	flags.StringVar(&config.Separator, "separator", config.Separator, stdoutStyles().FlagDesc.Render(help["separator"]))
 	flags.BoolVarP(&config.IncludePromptArgs, "prompt-args", "p", config.IncludePromptArgs, stdoutStyles().FlagDesc.Render(help["prompt-args"]))
 	flags.StringVarP(&config.Continue, "continue", "c", "", stdoutStyles().FlagDesc.Render(help["continue"]))
 	flags.BoolVarP(&config.ContinueLast, "continue-last", "C", false, stdoutStyles().FlagDesc.Render(help["continue-last"]))
 	flags.BoolVarP(&config.List, "list", "l", config.List, stdoutStyles().FlagDesc.Render(help["list"]))
+	flags.StringSliceVar(&config.Tags, "tag", config.Tags, stdoutStyles().FlagDesc.Render(help["tag"]))
+	flags.StringSliceVar(&config.FilterTags, "filter-tags", config.FilterTags, stdoutStyles().FlagDesc.Render(help["filter-tags"]))
+	flags.StringVar(&config.FilterModel, "filter-model", config.FilterModel, stdoutStyles().FlagDesc.Render(help["filter-model"]))
+	flags.StringVar(&config.FilterAPI, "filter-api", config.FilterAPI, stdoutStyles().FlagDesc.Render(help["filter-api"]))
+	flags.StringVar(&config.Sort, "sort", "updated", stdoutStyles().FlagDesc.Render(help["sort"]))
+	flags.BoolVar(&config.SortAsc, "sort-asc", config.SortAsc, stdoutStyles().FlagDesc.Render(help["sort-asc"]))
+	flags.BoolVar(&config.SortDesc, "sort-desc", config.SortDesc, stdoutStyles().FlagDesc.Render(help["sort-desc"]))
+	flags.BoolVar(&config.Paginate, "paginate", config.Paginate, stdoutStyles().FlagDesc.Render(help["paginate"]))
+	flags.IntVar(&config.Page, "page", config.Page, stdoutStyles().FlagDesc.Render(help["page"]))
+	flags.IntVar(&config.PageSize, "page-size", config.PageSize, stdoutStyles().FlagDesc.Render(help["page-size"]))
+	flags.BoolVar(&config.NextPage, "next-page", config.NextPage, stdoutStyles().FlagDesc.Render(help["next-page"]))
+	flags.BoolVar(&config.PrevPage, "prev-page", config.PrevPage, stdoutStyles().FlagDesc.Render(help["prev-page"]))
+	flags.StringVar(&config.Search, "search", config.Search, stdoutStyles().FlagDesc.Render(help["search"]))
+	flags.StringVar(&config.Rename, "rename", config.Rename, stdoutStyles().FlagDesc.Render(help["rename"]))
+	flags.StringVar(&config.Fork, "fork", config.Fork, stdoutStyles().FlagDesc.Render(help["fork"]))
+	flags.BoolVar(&config.Stats, "stats", config.Stats, stdoutStyles().FlagDesc.Render(help["stats"]))
+	flags.StringVar(&config.MigrateModel, "migrate-model", config.MigrateModel, stdoutStyles().FlagDesc.Render(help["migrate-model"]))
+	flags.StringVar(&config.BackupDB, "backup-db", config.BackupDB, stdoutStyles().FlagDesc.Render(help["backup-db"]))
+	flags.BoolVar(&config.Vacuum, "vacuum", config.Vacuum, stdoutStyles().FlagDesc.Render(help["vacuum"]))
+	flags.BoolVar(&config.Yes, "yes", config.Yes, stdoutStyles().FlagDesc.Render(help["yes"]))
+	flags.StringVar(&config.Export, "export", config.Export, stdoutStyles().FlagDesc.Render(help["export"]))
+	flags.StringVar(&config.Import, "import", config.Import, stdoutStyles().FlagDesc.Render(help["import"]))
 	flags.StringVarP(&config.Title, "title", "t", config.Title, stdoutStyles().FlagDesc.Render(help["title"]))
+	flags.StringVar(&config.ConversationID, "conversation-id", config.ConversationID, stdoutStyles().FlagDesc.Render(help["conversation-id"]))
 	flags.StringVarP(&config.Delete, "delete", "d", config.Delete, stdoutStyles().FlagDesc.Render(help["delete"]))
 	flags.Var(newDurationFlag(config.DeleteOlderThan, &config.DeleteOlderThan), "delete-older-than", stdoutStyles().FlagDesc.Render(help["delete-older-than"]))
+	flags.BoolVar(&config.IncludeArchived, "include-archived", config.IncludeArchived, stdoutStyles().FlagDesc.Render(help["include-archived"]))
+	flags.Var(newDurationFlag(config.CacheTTL, &config.CacheTTL), "cache-ttl", stdoutStyles().FlagDesc.Render(help["cache-ttl"]))
+	flags.Int64Var(&config.CacheMaxSize, "cache-max-size", config.CacheMaxSize, stdoutStyles().FlagDesc.Render(help["cache-max-size"]))
+	flags.IntVar(&config.CacheMaxCount, "cache-max-count", config.CacheMaxCount, stdoutStyles().FlagDesc.Render(help["cache-max-count"]))
+	flags.StringVar(&config.Archive, "archive", config.Archive, stdoutStyles().FlagDesc.Render(help["archive"]))
+	flags.StringVar(&config.Unarchive, "unarchive", config.Unarchive, stdoutStyles().FlagDesc.Render(help["unarchive"]))
+	flags.StringVar(&config.Pin, "pin", config.Pin, stdoutStyles().FlagDesc.Render(help["pin"]))
+	flags.StringVar(&config.Unpin, "unpin", config.Unpin, stdoutStyles().FlagDesc.Render(help["unpin"]))
+	flags.BoolVar(&config.ListArchived, "list-archived", config.ListArchived, stdoutStyles().FlagDesc.Render(help["list-archived"]))
 	flags.StringVarP(&config.Show, "show", "s", config.Show, stdoutStyles().FlagDesc.Render(help["show"]))
 	flags.BoolVarP(&config.ShowLast, "show-last", "S", false, stdoutStyles().FlagDesc.Render(help["show-last"]))
 	flags.BoolVarP(&config.Quiet, "quiet", "q", config.Quiet, stdoutStyles().FlagDesc.Render(help["quiet"]))
 	flags.BoolVarP(&config.ShowHelp, "help", "h", false, stdoutStyles().FlagDesc.Render(help["help"]))
-	flags.BoolVarP(&config.Version, "version", "v", false, stdoutStyles().FlagDesc.Render(help["version"]))
+	flags.BoolVar(&config.Version, "version", false, stdoutStyles().FlagDesc.Render(help["version"]))
+	flags.BoolVar(&config.VersionJSON, "version-json", false, stdoutStyles().FlagDesc.Render(help["version-json"]))
+	flags.BoolVar(&config.Verbose, "verbose", config.Verbose, stdoutStyles().FlagDesc.Render(help["verbose"]))
+	flags.BoolVar(&config.ShowTokens, "show-tokens", config.ShowTokens, stdoutStyles().FlagDesc.Render(help["show-tokens"]))
+	flags.BoolVar(&config.Debug, "debug", config.Debug, stdoutStyles().FlagDesc.Render(help["debug"]))
+	flags.StringVar(&config.MetricsAddr, "metrics-addr", config.MetricsAddr, stdoutStyles().FlagDesc.Render(help["metrics-addr"]))
+	flags.StringVar(&config.OTLPEndpoint, "otlp-endpoint", config.OTLPEndpoint, stdoutStyles().FlagDesc.Render(help["otlp-endpoint"]))
+	flags.StringVar(&config.LogFile, "log-file", config.LogFile, stdoutStyles().FlagDesc.Render(help["log-file"]))
 	flags.IntVar(&config.MaxRetries, "max-retries", config.MaxRetries, stdoutStyles().FlagDesc.Render(help["max-retries"]))
+	flags.DurationVar(&config.MaxRetryWait, "max-retry-wait", config.MaxRetryWait, stdoutStyles().FlagDesc.Render(help["max-retry-wait"]))
 	flags.BoolVar(&config.NoLimit, "no-limit", config.NoLimit, stdoutStyles().FlagDesc.Render(help["no-limit"]))
+	flags.IntVar(&config.ContextWindow, "context-window", 0, stdoutStyles().FlagDesc.Render(help["context-window"]))
+	flags.StringVar(&config.TruncationStrategy, "truncation-strategy", config.TruncationStrategy, stdoutStyles().FlagDesc.Render(help["truncation-strategy"]))
 	flags.IntVar(&config.MaxTokens, "max-tokens", config.MaxTokens, stdoutStyles().FlagDesc.Render(help["max-tokens"]))
+	flags.IntVar(&config.MaxCompletionTokens, "max-completion-tokens", config.MaxCompletionTokens, stdoutStyles().FlagDesc.Render(help["max-completion-tokens"]))
+	flags.IntVar(&config.Completions, "completions", 1, stdoutStyles().FlagDesc.Render(help["completions"]))
+	flags.StringVar(&config.ResponseSchema, "schema", config.ResponseSchema, stdoutStyles().FlagDesc.Render(help["schema"]))
+	flags.Float64Var(&config.MaxCost, "max-cost", config.MaxCost, stdoutStyles().FlagDesc.Render(help["max-cost"]))
 	flags.IntVar(&config.WordWrap, "word-wrap", config.WordWrap, stdoutStyles().FlagDesc.Render(help["word-wrap"]))
 	flags.Float32Var(&config.Temperature, "temp", config.Temperature, stdoutStyles().FlagDesc.Render(help["temp"]))
+	flags.IntVar(&config.Times, "times", config.Times, stdoutStyles().FlagDesc.Render(help["times"]))
+	flags.BoolVar(&config.Watch, "watch", config.Watch, stdoutStyles().FlagDesc.Render(help["watch"]))
+	flags.DurationVar(&config.WatchInterval, "watch-interval", config.WatchInterval, stdoutStyles().FlagDesc.Render(help["watch-interval"]))
+	flags.BoolVar(&config.WatchSave, "watch-save", config.WatchSave, stdoutStyles().FlagDesc.Render(help["watch-save"]))
+	flags.BoolVar(&config.TempVary, "temp-vary", config.TempVary, stdoutStyles().FlagDesc.Render(help["temp-vary"]))
 	flags.StringArrayVar(&config.Stop, "stop", config.Stop, stdoutStyles().FlagDesc.Render(help["stop"]))
 	flags.Float32Var(&config.TopP, "topp", config.TopP, stdoutStyles().FlagDesc.Render(help["topp"]))
 	flags.IntVar(&config.TopK, "topk", config.TopK, stdoutStyles().FlagDesc.Render(help["topk"]))
+	flags.IntVar(&config.ThinkingBudget, "thinking-budget", config.ThinkingBudget, stdoutStyles().FlagDesc.Render(help["thinking-budget"]))
+	flags.BoolVar(&config.Grounding, "grounding", config.Grounding, stdoutStyles().FlagDesc.Render(help["grounding"]))
+	flags.Int64Var(&config.Seed, "seed", config.Seed, stdoutStyles().FlagDesc.Render(help["seed"]))
+	flags.Float32Var(&config.PresencePenalty, "presence-penalty", config.PresencePenalty, stdoutStyles().FlagDesc.Render(help["presence-penalty"]))
+	flags.Float32Var(&config.FrequencyPenalty, "frequency-penalty", config.FrequencyPenalty, stdoutStyles().FlagDesc.Render(help["frequency-penalty"]))
 	flags.UintVar(&config.Fanciness, "fanciness", config.Fanciness, stdoutStyles().FlagDesc.Render(help["fanciness"]))
 	flags.StringVar(&config.StatusText, "status-text", config.StatusText, stdoutStyles().FlagDesc.Render(help["status-text"]))
 	flags.BoolVar(&config.NoCache, "no-cache", config.NoCache, stdoutStyles().FlagDesc.Render(help["no-cache"]))
+	flags.BoolVar(&config.CacheResponses, "cache-responses", config.CacheResponses, stdoutStyles().FlagDesc.Render(help["cache-responses"]))
+	flags.BoolVar(&config.DryRun, "dry-run", config.DryRun, stdoutStyles().FlagDesc.Render(help["dry-run"]))
+	flags.BoolVar(&config.EstimateTokens, "estimate-tokens", config.EstimateTokens, stdoutStyles().FlagDesc.Render(help["estimate-tokens"]))
+	flags.BoolVar(&config.NoStream, "no-stream", config.NoStream, stdoutStyles().FlagDesc.Render(help["no-stream"]))
 	flags.BoolVar(&config.ResetSettings, "reset-settings", config.ResetSettings, stdoutStyles().FlagDesc.Render(help["reset-settings"]))
 	flags.BoolVar(&config.Settings, "settings", false, stdoutStyles().FlagDesc.Render(help["settings"]))
 	flags.BoolVar(&config.Dirs, "dirs", false, stdoutStyles().FlagDesc.Render(help["dirs"]))
+	flags.StringVar(&config.Profile, "profile", config.Profile, stdoutStyles().FlagDesc.Render(help["profile"]))
+	flags.StringVar(&config.ConfigPath, "config", "", stdoutStyles().FlagDesc.Render(help["config"]))
 	flags.StringVarP(&config.Role, "role", "R", config.Role, stdoutStyles().FlagDesc.Render(help["role"]))
+	flags.StringToStringVar(&config.Vars, "var", config.Vars, stdoutStyles().FlagDesc.Render(help["var"]))
+	flags.StringVarP(&config.System, "system", "y", config.System, stdoutStyles().FlagDesc.Render(help["system"]))
+	flags.StringArrayVarP(&config.Files, "file", "F", config.Files, stdoutStyles().FlagDesc.Render(help["file"]))
+	flags.StringVar(&config.PromptFile, "prompt-file", config.PromptFile, stdoutStyles().FlagDesc.Render(help["prompt-file"]))
+	flags.BoolVar(&config.Force, "force", config.Force, stdoutStyles().FlagDesc.Render(help["force"]))
+	flags.StringVar(&config.ExecCmd, "exec", config.ExecCmd, stdoutStyles().FlagDesc.Render(help["exec"]))
+	flags.DurationVar(&config.ExecTimeout, "exec-timeout", config.ExecTimeout, stdoutStyles().FlagDesc.Render(help["exec-timeout"]))
+	flags.StringArrayVar(&config.URLs, "url", config.URLs, stdoutStyles().FlagDesc.Render(help["url"]))
+	flags.StringArrayVarP(&config.Images, "image", "i", config.Images, stdoutStyles().FlagDesc.Render(help["image"]))
 	flags.BoolVar(&config.ListRoles, "list-roles", config.ListRoles, stdoutStyles().FlagDesc.Render(help["list-roles"]))
+	flags.BoolVar(&config.ListModels, "list-models", config.ListModels, stdoutStyles().FlagDesc.Render(help["list-models"]))
 	flags.StringVar(&config.Theme, "theme", "charm", stdoutStyles().FlagDesc.Render(help["theme"]))
 	flags.Lookup("prompt").NoOptDefVal = "-1"
 	flags.SortFlags = false
@@ -261,12 +581,32 @@ func initFlags() {
 	flags.BoolVar(&memprofile, "memprofile", false, "Write memory profiles to CWD")
 	_ = flags.MarkHidden("memprofile")
 
-	for _, name := range []string{"show", "delete", "continue"} {
+	for _, name := range []string{"show", "delete", "continue", "archive", "unarchive", "pin", "unpin"} {
 		_ = rootCmd.RegisterFlagCompletionFunc(name, func(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 			results, _ := db.Completions(toComplete)
 			return results, cobra.ShellCompDirectiveDefault
 		})
 	}
+	for _, name := range []string{"tag", "filter-tags"} {
+		_ = rootCmd.RegisterFlagCompletionFunc(name, func(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			// complete the last tag of a comma separated list.
+			done, last := "", toComplete
+			if i := strings.LastIndex(toComplete, ","); i >= 0 {
+				done, last = toComplete[:i+1], toComplete[i+1:]
+			}
+			results, _ := db.TagCompletions(last)
+			for i := range results {
+				results[i] = done + results[i]
+			}
+			return results, cobra.ShellCompDirectiveDefault
+		})
+	}
+	_ = rootCmd.RegisterFlagCompletionFunc("sort", func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+		return listSortNames, cobra.ShellCompDirectiveNoFileComp
+	})
+	_ = rootCmd.RegisterFlagCompletionFunc("profile", func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+		return profileNames(config.Profiles), cobra.ShellCompDirectiveNoFileComp
+	})
 	_ = rootCmd.RegisterFlagCompletionFunc("role", func(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		return roleNames(toComplete), cobra.ShellCompDirectiveDefault
 	})
@@ -290,21 +630,47 @@ func initFlags() {
 		"delete",
 		"delete-older-than",
 		"list",
+		"list-archived",
+		"archive",
+		"unarchive",
+		"pin",
+		"unpin",
+		"import",
+		"migrate-model",
+		"search",
+		"fork",
 		"continue",
 		"continue-last",
 		"reset-settings",
 	)
+	rootCmd.MarkFlagsMutuallyExclusive(
+		"dry-run",
+		"estimate-tokens",
+	)
+	rootCmd.MarkFlagsMutuallyExclusive(
+		"rename",
+		"delete",
+		"delete-older-than",
+	)
+	rootCmd.MarkFlagsMutuallyExclusive("conversation-id", "continue", "continue-last", "title")
+	for _, name := range []string{"continue", "continue-last", "conversation-id", "show", "show-last", "dry-run", "estimate-tokens"} {
+		rootCmd.MarkFlagsMutuallyExclusive("parallel", name)
+		rootCmd.MarkFlagsMutuallyExclusive("times", name)
+	}
+	rootCmd.MarkFlagsMutuallyExclusive("parallel", "times")
+	rootCmd.MarkFlagsMutuallyExclusive("sort-asc", "sort-desc")
+	rootCmd.MarkFlagsMutuallyExclusive("page", "next-page", "prev-page")
 }
 
 func main() {
 	defer maybeWriteMemProfile()
 	var err error
-	config, err = ensureConfig()
+	config, err = ensureConfig(flagValue(os.Args[1:], "config"), flagValue(os.Args[1:], "profile"))
 	if err != nil {
 		handleError(modsError{err, "Could not load your configuration file."})
 		// if user is editing the settings, only print out the error, but do
 		// not exit.
-		if !slices.Contains(os.Args, "--settings") {
+		if !slices.Contains(os.Args, "--settings") && !isValidateConfigCmd(os.Args) && !isDoctorCmd(os.Args) {
 			os.Exit(1)
 		}
 	}
@@ -317,6 +683,11 @@ func main() {
 	}
 	defer db.Close() //nolint:errcheck
 
+	if err := evictExpiredConversations(db, cache); err != nil {
+		handleError(modsError{err, "Could not delete expired conversations."})
+		os.Exit(1)
+	}
+
 	// XXX: this must come after creating the config.
 	initFlags()
 
@@ -353,6 +724,107 @@ func main() {
 		})
 	}
 
+	if isConfigCmd(os.Args) {
+		rootCmd.AddCommand(newConfigCmd())
+	}
+
+	if isCopilotStatusCmd(os.Args) {
+		rootCmd.AddCommand(&cobra.Command{
+			Use:                   "copilot-status",
+			Short:                 "Shows the Copilot token and endpoint in use",
+			SilenceUsage:          true,
+			DisableFlagsInUseLine: true,
+			Hidden:                true,
+			Args:                  cobra.NoArgs,
+			RunE: func(*cobra.Command, []string) error {
+				var api API
+				for _, a := range config.APIs {
+					if a.Name == "copilot" {
+						api = a
+					}
+				}
+				if err := printCopilotStatus(os.Stdout, copilotHostsPath(), api); err != nil {
+					return modsError{err, "Copilot is not signed in; sign in to GitHub Copilot in your editor."}
+				}
+				return nil
+			},
+		})
+	}
+
+	if isUpdateCmd(os.Args) {
+		var checkOnly bool
+		updateCmd := &cobra.Command{
+			Use:                   "update",
+			Short:                 "Updates mods to the latest release",
+			SilenceUsage:          true,
+			DisableFlagsInUseLine: true,
+			Hidden:                true,
+			Args:                  cobra.NoArgs,
+			RunE: func(*cobra.Command, []string) error {
+				exe, err := os.Executable()
+				if err == nil {
+					exe, err = filepath.EvalSymlinks(exe)
+				}
+				if err != nil {
+					return modsError{err, "Couldn't find the mods executable."}
+				}
+				client := &http.Client{}
+				if config.HTTPProxy != "" {
+					if client, err = newProxyClient(config.HTTPProxy); err != nil {
+						return modsError{err, "There was an error parsing your proxy URL."}
+					}
+				}
+				if err := selfUpdate(os.Stdout, client, latestReleaseURL, Version, exe, checkOnly); err != nil {
+					if errors.Is(err, errNoVersion) {
+						return modsError{err, "This build of mods can't be updated, install a release instead."}
+					}
+					return modsError{err, "Couldn't update mods."}
+				}
+				return nil
+			},
+		}
+		updateCmd.Flags().BoolVar(&checkOnly, "check-update", false, "Only check whether a newer version is available")
+		rootCmd.AddCommand(updateCmd)
+	}
+
+	if isDoctorCmd(os.Args) {
+		rootCmd.AddCommand(&cobra.Command{
+			Use:                   "doctor",
+			Short:                 "Checks the environment mods runs in",
+			SilenceUsage:          true,
+			DisableFlagsInUseLine: true,
+			Hidden:                true,
+			Args:                  cobra.NoArgs,
+			RunE: func(*cobra.Command, []string) error {
+				client := &http.Client{Timeout: doctorTimeout}
+				if config.HTTPProxy != "" {
+					var err error
+					if client, err = newProxyClient(config.HTTPProxy); err != nil {
+						return modsError{err, "There was an error parsing your proxy URL."}
+					}
+				}
+				if !runDoctor(os.Stdout, stdoutRenderer(), &config, db, client) {
+					return modsError{newUserErrorf("See the checks marked with ✗ above."), "Some checks failed."}
+				}
+				return nil
+			},
+		})
+	}
+
+	if isValidateConfigCmd(os.Args) {
+		rootCmd.AddCommand(&cobra.Command{
+			Use:                   "validate-config",
+			Short:                 "Checks the settings file for problems",
+			SilenceUsage:          true,
+			DisableFlagsInUseLine: true,
+			Hidden:                true,
+			Args:                  cobra.NoArgs,
+			RunE: func(*cobra.Command, []string) error {
+				return validateConfigFile(config.SettingsPath)
+			},
+		})
+	}
+
 	if err := rootCmd.Execute(); err != nil {
 		handleError(err)
 		_ = db.Close()
@@ -483,7 +955,7 @@ func resetSettings() error {
 }
 
 func deleteConversationOlderThan() error {
-	conversations, err := db.ListOlderThan(config.DeleteOlderThan)
+	conversations, err := db.ListOlderThan(config.DeleteOlderThan, config.IncludeArchived)
 	if err != nil {
 		return modsError{err, "Couldn't find conversation to delete."}
 	}
@@ -534,6 +1006,9 @@ func deleteConversationOlderThan() error {
 		}
 	}
 
+	if config.Vacuum {
+		return vacuumDB()
+	}
 	return nil
 }
 
@@ -554,113 +1029,666 @@ func deleteConversation() error {
 	if !config.Quiet {
 		fmt.Fprintln(os.Stderr, "Conversation deleted:", convo.ID[:sha1minLen])
 	}
+	if config.Vacuum {
+		return vacuumDB()
+	}
+	return nil
+}
+
+func validateRename() error {
+	if strings.TrimSpace(config.Rename) == "" {
+		return modsError{
+			err: newUserErrorf("The new title can't be empty."),
+			reason: fmt.Sprintf(
+				"%s needs a title.",
+				stdoutStyles().InlineCode.Render("--rename"),
+			),
+		}
+	}
+	if config.Show == "" && !config.ShowLast && config.Continue == "" && !config.ContinueLast {
+		return modsError{
+			err: newUserErrorf(
+				"Use it together with %s, %s, %s or %s.",
+				stdoutStyles().InlineCode.Render("--show"),
+				stdoutStyles().InlineCode.Render("--show-last"),
+				stdoutStyles().InlineCode.Render("--continue"),
+				stdoutStyles().InlineCode.Render("--continue-last"),
+			),
+			reason: fmt.Sprintf(
+				"%s needs a conversation to rename.",
+				stdoutStyles().InlineCode.Render("--rename"),
+			),
+		}
+	}
 	return nil
 }
 
-func listConversations() error {
-	conversations, err := db.List()
+func renameConversation(id string) error {
+	convo, err := db.Find(id)
 	if err != nil {
-		return modsError{err, "Couldn't list saves."}
+		return modsError{err, "Couldn't find conversation to rename."}
 	}
 
-	if len(conversations) == 0 {
-		fmt.Fprintln(os.Stderr, "No conversations found.")
-		return nil
+	title := strings.TrimSpace(config.Rename)
+	if err := db.Rename(convo.ID, title); err != nil {
+		return modsError{err, "Couldn't rename conversation."}
 	}
 
-	if isInputTTY() && isOutputTTY() {
-		selectFromList(conversations)
-		return nil
+	if !config.Quiet {
+		fmt.Fprintln(
+			os.Stderr,
+			"Conversation renamed:",
+			stderrStyles().InlineCode.Render(convo.ID[:sha1short]),
+			stderrStyles().Comment.Render(title),
+		)
 	}
-	printList(conversations)
 	return nil
 }
 
-func roleNames(prefix string) []string {
-	roles := make([]string, 0, len(config.Roles))
-	for role := range config.Roles {
-		if prefix != "" && !strings.HasPrefix(role, prefix) {
-			continue
-		}
-		roles = append(roles, role)
+func forkConversation() error {
+	src, err := db.Find(config.Fork)
+	if err != nil {
+		return modsError{err, "Couldn't find conversation to fork."}
 	}
-	slices.Sort(roles)
-	return roles
-}
 
-func listRoles() error {
-	for _, role := range roleNames("") {
-		s := role
-		if role == config.Role {
-			s = role + stdoutStyles().Timeago.Render(" (default)")
-		}
-		fmt.Println(s)
+	var messages []openai.ChatCompletionMessage
+	if err := cache.read(src.ID, &messages); err != nil {
+		return modsError{err, "There was an error loading the conversation."}
 	}
-	return nil
-}
 
-func makeOptions(conversations []Conversation) []huh.Option[string] {
-	opts := make([]huh.Option[string], 0, len(conversations))
-	for _, c := range conversations {
-		timea := stdoutStyles().Timeago.Render(timeago.Of(c.UpdatedAt))
-		left := stdoutStyles().SHA1.Render(c.ID[:sha1short])
-		right := stdoutStyles().ConversationList.Render(c.Title, timea)
-		if c.Model != nil {
-			right += stdoutStyles().Comment.Render(*c.Model)
-		}
-		opts = append(opts, huh.NewOption(left+" "+right, c.ID))
+	id := newConversationID()
+	title := strings.TrimSpace(config.Title)
+	if title == "" {
+		title = src.Title + " (fork)"
 	}
-	return opts
-}
 
-func selectFromList(conversations []Conversation) {
-	var selected string
-	if err := huh.NewForm(
-		huh.NewGroup(
-			huh.NewSelect[string]().
-				Title("Conversations").
-				Value(&selected).
-				Options(makeOptions(conversations)...),
-		),
-	).Run(); err != nil {
-		if !errors.Is(err, huh.ErrUserAborted) {
-			fmt.Fprintln(os.Stderr, err.Error())
-		}
-		return
+	if err := cache.write(id, &messages); err != nil {
+		return modsError{err, "Couldn't fork conversation."}
+	}
+	if err := db.Fork(src.ID, id, title, "", ""); err != nil {
+		_ = cache.delete(id) // remove leftovers
+		return modsError{err, "Couldn't fork conversation."}
 	}
 
-	_ = clipboard.WriteAll(selected)
-	termenv.Copy(selected)
-	printConfirmation("COPIED", selected)
-	// suggest actions to use this conversation ID
-	fmt.Println(stdoutStyles().Comment.Render(
-		"You can use this conversation ID with the following commands:",
-	))
-	suggestions := []string{"show", "continue", "delete"}
-	for _, flag := range suggestions {
-		fmt.Printf(
-			"  %-44s %s\n",
-			stdoutStyles().Flag.Render("--"+flag),
-			stdoutStyles().FlagDesc.Render(help[flag]),
+	if !config.Quiet {
+		fmt.Fprintln(
+			os.Stderr,
+			"Conversation forked:",
+			stderrStyles().InlineCode.Render(id[:sha1short]),
+			stderrStyles().Comment.Render(title),
 		)
 	}
+	return nil
 }
 
-func printList(conversations []Conversation) {
+func archiveConversation(in string, archive bool) error {
+	verb, fn := "archive", db.Archive
+	if !archive {
+		verb, fn = "unarchive", db.Unarchive
+	}
+
+	convo, err := db.Find(in)
+	if err != nil {
+		return modsError{err, "Couldn't find conversation to " + verb + "."}
+	}
+	if err := fn(convo.ID); err != nil {
+		return modsError{err, "Couldn't " + verb + " conversation."}
+	}
+
+	if !config.Quiet {
+		fmt.Fprintln(os.Stderr, "Conversation "+verb+"d:", convo.ID[:sha1minLen])
+	}
+	return nil
+}
+
+func pinConversation(in string, pin bool) error {
+	verb, fn := "pin", db.Pin
+	if !pin {
+		verb, fn = "unpin", db.Unpin
+	}
+
+	convo, err := db.Find(in)
+	if err != nil {
+		return modsError{err, "Couldn't find conversation to " + verb + "."}
+	}
+	if err := fn(convo.ID); err != nil {
+		return modsError{err, "Couldn't " + verb + " conversation."}
+	}
+
+	if !config.Quiet {
+		fmt.Fprintln(os.Stderr, "Conversation "+verb+"ned:", convo.ID[:sha1minLen])
+	}
+	return nil
+}
+
+func migrateModel() error {
+	oldModel, newModel, ok := strings.Cut(config.MigrateModel, "=")
+	oldModel, newModel = strings.TrimSpace(oldModel), strings.TrimSpace(newModel)
+	if !ok || oldModel == "" || newModel == "" || oldModel == newModel {
+		return modsError{
+			err: newUserErrorf(
+				"Use it like %s.",
+				stdoutStyles().InlineCode.Render("--migrate-model gpt-4=gpt-4-turbo"),
+			),
+			reason: fmt.Sprintf(
+				"Invalid %s value %s.",
+				stdoutStyles().InlineCode.Render("--migrate-model"),
+				stdoutStyles().InlineCode.Render(config.MigrateModel),
+			),
+		}
+	}
+
+	if !config.Yes {
+		if !isOutputTTY() || !isInputTTY() {
+			return newUserErrorf(
+				"To rename the model in your saved conversations, run: %s",
+				strings.Join(append(os.Args, "--yes"), " "),
+			)
+		}
+		var confirm bool
+		if err := huh.Run(
+			huh.NewConfirm().
+				Title(fmt.Sprintf("Rename %s to %s?", oldModel, newModel)).
+				Description("This will change all the saved conversations that used it.").
+				Value(&confirm),
+		); err != nil {
+			return modsError{err, "Couldn't rename the model."}
+		}
+		if !confirm {
+			return newUserErrorf("Aborted by user")
+		}
+	}
+
+	n, err := db.RenameModel(oldModel, newModel)
+	if err != nil {
+		return modsError{err, "Couldn't rename the model."}
+	}
+	if !config.Quiet {
+		fmt.Fprintf(
+			os.Stderr,
+			"Renamed %s to %s in %d conversations.\n",
+			stderrStyles().InlineCode.Render(oldModel),
+			stderrStyles().InlineCode.Render(newModel),
+			n,
+		)
+	}
+	return nil
+}
+
+func backupDB() error {
+	if err := db.Backup(config.BackupDB); err != nil {
+		return modsError{err, "Couldn't back up the database."}
+	}
+	if !config.Quiet {
+		fmt.Fprintf(
+			os.Stderr,
+			"Backup written to %s.\n",
+			stderrStyles().InlineCode.Render(config.BackupDB),
+		)
+	}
+	return nil
+}
+
+func vacuumDB() error {
+	before, _, err := db.size()
+	if err != nil {
+		return modsError{err, "Couldn't vacuum the database."}
+	}
+	if !config.Quiet {
+		fmt.Fprintln(os.Stderr, "Vacuuming the database, this can take a while…")
+	}
+	if err := db.Vacuum(); err != nil {
+		return modsError{err, "Couldn't vacuum the database."}
+	}
+	after, _, err := db.size()
+	if err != nil {
+		return modsError{err, "Couldn't vacuum the database."}
+	}
+	if !config.Quiet {
+		fmt.Fprintf(os.Stderr, "Database vacuumed, %d bytes reclaimed.\n", before-after)
+	}
+	return nil
+}
+
+func showStats() error {
+	stats, err := db.Stats()
+	if err != nil {
+		return modsError{err, "Couldn't compute statistics."}
+	}
+	if err := printStats(os.Stdout, stats, config.Raw); err != nil {
+		return modsError{err, "Couldn't print statistics."}
+	}
+	return nil
+}
+
+func printStats(w io.Writer, stats ConvoStats, raw bool) error {
+	if raw {
+		if err := json.NewEncoder(w).Encode(stats); err != nil {
+			return fmt.Errorf("printStats: %w", err)
+		}
+		return nil
+	}
+
+	styles := stdoutStyles()
+	orNone := func(s string) string {
+		if s == "" {
+			return styles.Comment.Render("none")
+		}
+		return s
+	}
+	for _, line := range [][2]string{
+		{"Conversations", strconv.Itoa(stats.Conversations)},
+		{"Total tokens", strconv.Itoa(stats.TotalTokens)},
+		{"Average tokens", strconv.FormatFloat(stats.AverageTokens, 'f', 0, 64)},
+		{"Most used model", orNone(stats.TopModel)},
+		{"Most used API", orNone(stats.TopAPI)},
+		{"Database size", fmt.Sprintf("%d bytes", stats.SizeBytes)},
+	} {
+		if _, err := fmt.Fprintf(w, "%s %s\n", styles.FlagDesc.Render(line[0]+":"), line[1]); err != nil {
+			return fmt.Errorf("printStats: %w", err)
+		}
+	}
+	if stats.NeedsVacuum() {
+		if _, err := fmt.Fprintf(
+			w,
+			"\n%s\n",
+			styles.Comment.Render(fmt.Sprintf(
+				"%d bytes of the database are unused, run mods --vacuum to reclaim them.",
+				stats.FragmentedBytes,
+			)),
+		); err != nil {
+			return fmt.Errorf("printStats: %w", err)
+		}
+	}
+	return nil
+}
+
+func listConversations(opts ...listOption) error {
+	opts = append(
+		opts,
+		withTags(config.FilterTags),
+		withModel(config.FilterModel),
+		withAPI(config.FilterAPI),
+		withSort(config.Sort, listOrder()),
+	)
+	if isPaginated() {
+		total, err := db.Count(opts...)
+		if err != nil {
+			return modsError{err, "Couldn't list saves."}
+		}
+		config.pages = pageCount(total, config.PageSize)
+		config.Page = min(listPage(), config.pages)
+		// remembered for --next-page and --prev-page.
+		_ = os.WriteFile(lastPagePath(), []byte(strconv.Itoa(config.Page)), 0o600) //nolint:mnd
+		opts = append(opts, withPage(config.Page, config.PageSize))
+	}
+	conversations, err := db.List(opts...)
+	if err != nil {
+		return modsError{err, "Couldn't list saves."}
+	}
+	// the counts are only shown, so they are best effort.
+	_ = cache.counts(conversations)
+	return showConversations(conversations)
+}
+
+func searchConversations(query string) error {
+	if err := indexConversations(db, cache); err != nil {
+		return modsError{err, "Couldn't index saves."}
+	}
+	conversations, err := db.Search(query)
+	if err != nil {
+		return modsError{err, "Couldn't search saves."}
+	}
+	_ = cache.counts(conversations)
+	return showConversations(conversations)
+}
+
+func validateExport() error {
+	if config.Show == "" && !config.ShowLast {
+		return modsError{
+			err: newUserErrorf(
+				"Use it together with %s or %s.",
+				stdoutStyles().InlineCode.Render("--show"),
+				stdoutStyles().InlineCode.Render("--show-last"),
+			),
+			reason: fmt.Sprintf(
+				"%s needs a conversation to export.",
+				stdoutStyles().InlineCode.Render("--export"),
+			),
+		}
+	}
+	if !slices.Contains(exportFormats, config.Export) {
+		return modsError{
+			err: newUserErrorf(
+				"Valid formats are: %s.",
+				strings.Join(exportFormats, ", "),
+			),
+			reason: fmt.Sprintf(
+				"Unknown export format %s.",
+				stdoutStyles().InlineCode.Render(config.Export),
+			),
+		}
+	}
+	return nil
+}
+
+func validateConversationID() error {
+	if len(config.ConversationID) != sha1len || !sha1reg.MatchString(config.ConversationID) {
+		return modsError{
+			err: newUserErrorf("It must be 40 lowercase hexadecimal characters, like the output of sha1sum."),
+			reason: fmt.Sprintf(
+				"Invalid %s %s.",
+				stdoutStyles().InlineCode.Render("--conversation-id"),
+				stdoutStyles().InlineCode.Render(config.ConversationID),
+			),
+		}
+	}
+	return nil
+}
+
+func validateFilters() error {
+	if !config.List && !config.ListArchived {
+		return modsError{
+			err: newUserErrorf(
+				"Use it together with %s or %s.",
+				stdoutStyles().InlineCode.Render("--list"),
+				stdoutStyles().InlineCode.Render("--list-archived"),
+			),
+			reason: fmt.Sprintf(
+				"%s and %s only filter the listed conversations.",
+				stdoutStyles().InlineCode.Render("--filter-model"),
+				stdoutStyles().InlineCode.Render("--filter-api"),
+			),
+		}
+	}
+	return nil
+}
+
+func exportSavedConversation(id string) error {
+	convo, err := db.Find(id)
+	if err != nil {
+		return modsError{err, "Could not find the conversation."}
+	}
+	var messages []openai.ChatCompletionMessage
+	if err := cache.read(convo.ID, &messages); err != nil {
+		return modsError{err, "There was an error loading the conversation."}
+	}
+	if err := exportConversation(os.Stdout, config.Export, *convo, messages); err != nil {
+		return modsError{err, "Couldn't export the conversation."}
+	}
+	return nil
+}
+
+func importSavedConversation(path string) error {
+	format, err := importFormat(path)
+	if err != nil {
+		return modsError{err, "Only .md and .json files can be imported."}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return modsError{err, "Couldn't open the file to import."}
+	}
+	defer f.Close() //nolint:errcheck
+
+	fm, messages, err := importConversation(f, format)
+	if err != nil {
+		return modsError{err, "Couldn't read the conversation to import."}
+	}
+
+	id := newConversationID()
+	title := strings.TrimSpace(fm.Title)
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if err := cache.write(id, &messages); err != nil {
+		return modsError{err, "Couldn't import the conversation."}
+	}
+	if err := db.Save(id, title, fm.Model); err != nil {
+		_ = cache.delete(id) // remove leftovers
+		return modsError{err, "Couldn't import the conversation."}
+	}
+	if fm.API != "" {
+		if err := db.SetAPI(id, fm.API); err != nil {
+			return modsError{err, "Couldn't import the conversation."}
+		}
+	}
+	_ = db.Index(id, messagesText(messages))
+
+	if !config.Quiet {
+		fmt.Fprintln(
+			os.Stderr,
+			"Conversation imported:",
+			stderrStyles().InlineCode.Render(id[:sha1short]),
+			stderrStyles().Comment.Render(title),
+		)
+	}
+	return nil
+}
+
+func showConversations(conversations []Conversation) error {
+	if len(conversations) == 0 {
+		fmt.Fprintln(os.Stderr, "No conversations found.")
+		return nil
+	}
+
+	if isInputTTY() && isOutputTTY() {
+		selectFromList(conversations)
+		return nil
+	}
+	printList(conversations)
+	return nil
+}
+
+func roleNames(prefix string) []string {
+	roles := make([]string, 0, len(config.Roles))
+	for role := range config.Roles {
+		if prefix != "" && !strings.HasPrefix(role, prefix) {
+			continue
+		}
+		roles = append(roles, role)
+	}
+	slices.Sort(roles)
+	return roles
+}
+
+func listRoles() error {
+	for _, role := range roleNames("") {
+		s := role
+		if role == config.Role {
+			s = role + stdoutStyles().Timeago.Render(" (default)")
+		}
+		fmt.Println(s)
+	}
+	return nil
+}
+
+func printModels(w io.Writer, apis APIs, raw bool) {
+	for _, api := range apis {
+		names := make([]string, 0, len(api.Models))
+		for name := range api.Models {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+
+		for _, name := range names {
+			mod := api.Models[name]
+			if raw {
+				fmt.Fprintf(
+					w, "%s\t%s\t%s\t%d\n",
+					api.Name, name, strings.Join(mod.Aliases, ","), mod.MaxChars,
+				)
+				continue
+			}
+
+			s := stdoutStyles().SHA1.Render(api.Name+"/") + name
+			if len(mod.Aliases) > 0 {
+				s += stdoutStyles().Comment.Render(" (" + strings.Join(mod.Aliases, ", ") + ")")
+			}
+			if mod.MaxChars > 0 {
+				s += stdoutStyles().Timeago.Render(fmt.Sprintf(" %d chars", mod.MaxChars))
+			}
+			fmt.Fprintln(w, s)
+		}
+	}
+}
+
+func selectFromList(conversations []Conversation) {
+	m, err := tea.NewProgram(newPicker(conversations, listTitle()), tea.WithAltScreen()).Run()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return
+	}
+	selected := m.(*pickerModel).selected
+	if selected == "" {
+		return
+	}
+
+	_ = clipboard.WriteAll(selected)
+	termenv.Copy(selected)
+	printConfirmation("COPIED", selected)
+	// suggest actions to use this conversation ID
+	fmt.Println(stdoutStyles().Comment.Render(
+		"You can use this conversation ID with the following commands:",
+	))
+	suggestions := []string{"show", "continue", "delete"}
+	for _, flag := range suggestions {
+		fmt.Printf(
+			"  %-44s %s\n",
+			stdoutStyles().Flag.Render("--"+flag),
+			stdoutStyles().FlagDesc.Render(help[flag]),
+		)
+	}
+}
+
+func isPaginated() bool {
+	return config.Paginate || config.Page > 0 || config.NextPage || config.PrevPage
+}
+
+// lastPagePath is where the page --list showed last is kept.
+func lastPagePath() string {
+	return filepath.Join(config.CachePath, "list-page")
+}
+
+// listPage returns the page to list: the one given by --page, or the one
+// next to the page listed last, or else the first.
+func listPage() int {
+	if config.Page > 0 {
+		return config.Page
+	}
+	if !config.NextPage && !config.PrevPage {
+		return 1
+	}
+	last := 1
+	if bts, err := os.ReadFile(lastPagePath()); err == nil {
+		if n, err := strconv.Atoi(strings.TrimSpace(string(bts))); err == nil {
+			last = n
+		}
+	}
+	if config.NextPage {
+		return last + 1
+	}
+	return max(last-1, 1)
+}
+
+// listOrder returns the order given by --sort-asc or --sort-desc, if any.
+func listOrder() string {
+	switch {
+	case config.SortAsc:
+		return "ASC"
+	case config.SortDesc:
+		return "DESC"
+	default:
+		return ""
+	}
+}
+
+// listTitle describes the listed conversations, including the filters in use.
+func listTitle() string {
+	var filters []string
+	if config.FilterModel != "" {
+		filters = append(filters, "model: "+config.FilterModel)
+	}
+	if config.FilterAPI != "" {
+		filters = append(filters, "api: "+config.FilterAPI)
+	}
+	if len(config.FilterTags) > 0 {
+		filters = append(filters, "tags: "+strings.Join(config.FilterTags, ","))
+	}
+	title := "Conversations"
+	if config.ListArchived {
+		title = "Archived conversations"
+	}
+	if len(filters) > 0 {
+		title += " (" + strings.Join(filters, ", ") + ")"
+	}
+	if config.Sort != "updated" || listOrder() != "" {
+		title += ", sorted by " + config.Sort
+		if order := listOrder(); order != "" {
+			title += " " + strings.ToLower(order)
+		}
+	}
+	if config.pages > 0 {
+		title += fmt.Sprintf(", page %d of %d", config.Page, config.pages)
+	}
+	return title
+}
+
+// pinMark marks pinned conversations in lists.
+func pinMark(raw bool) string {
+	if raw {
+		return "*"
+	}
+	return "📌"
+}
+
+func printList(conversations []Conversation) {
 	for _, conversation := range conversations {
+		title := conversation.Title
+		if conversation.Pinned {
+			title = pinMark(config.Raw) + " " + title
+		}
 		_, _ = fmt.Fprintf(
 			os.Stdout,
-			"%s\t%s\t%s\n",
+			"%s\t%s\t%s\t%d\t%d\n",
 			stdoutStyles().SHA1.Render(conversation.ID[:sha1short]),
-			conversation.Title,
+			title,
 			stdoutStyles().Timeago.Render(timeago.Of(conversation.UpdatedAt)),
+			conversation.MessageCount,
+			conversation.ApproxTokens,
 		)
 	}
+	if config.pages > 0 {
+		// on stderr, so the list can still be piped.
+		fmt.Fprintf(os.Stderr, "Page %d of %d\n", config.Page, config.pages)
+	}
+}
+
+// pickCompletion asks which of the completions to save.
+func pickCompletion(mods *Mods) error {
+	opts := make([]huh.Option[int], 0, len(mods.completions))
+	for i, c := range mods.completions {
+		opts = append(opts, huh.NewOption(fmt.Sprintf("%d. %s", i+1, firstLine(strings.TrimSpace(c))), i))
+	}
+	var picked int
+	if err := huh.Run(
+		huh.NewSelect[int]().
+			Title("Which answer do you want to save?").
+			Options(opts...).
+			Value(&picked),
+	); err != nil {
+		if errors.Is(err, huh.ErrUserAborted) {
+			return nil
+		}
+		return modsError{err, "Couldn't pick the answer to save."}
+	}
+	mods.keepCompletion(picked)
+	return nil
 }
 
 func saveConversation(mods *Mods) error {
-	if config.NoCache {
-		if !config.Quiet {
+	cfg := mods.Config
+	if cfg.NoCache {
+		if !cfg.Quiet {
 			fmt.Fprintf(
 				os.Stderr,
 				"\nConversation was not saved because %s or %s is set.\n",
@@ -672,8 +1700,8 @@ func saveConversation(mods *Mods) error {
 	}
 
 	// if message is a sha1, use the last prompt instead.
-	id := config.cacheWriteToID
-	title := strings.TrimSpace(config.cacheWriteToTitle)
+	id := cfg.cacheWriteToID
+	title := strings.TrimSpace(cfg.cacheWriteToTitle)
 
 	if sha1reg.MatchString(title) || title == "" {
 		title = firstLine(lastPrompt(mods.messages))
@@ -682,41 +1710,295 @@ func saveConversation(mods *Mods) error {
 	if err := cache.write(id, &mods.messages); err != nil {
 		return modsError{err, fmt.Sprintf(
 			"There was a problem writing %s to the cache. Use %s / %s to disable it.",
-			config.cacheWriteToID,
+			cfg.cacheWriteToID,
 			stderrStyles().InlineCode.Render("--no-cache"),
 			stderrStyles().InlineCode.Render("NO_CACHE"),
 		)}
 	}
-	if err := db.Save(id, title, config.Model); err != nil {
+	if err := db.Save(id, title, cfg.Model); err != nil {
 		_ = cache.delete(id) // remove leftovers
 		return modsError{err, fmt.Sprintf(
 			"There was a problem writing %s to the cache. Use %s / %s to disable it.",
-			config.cacheWriteToID,
+			cfg.cacheWriteToID,
+			stderrStyles().InlineCode.Render("--no-cache"),
+			stderrStyles().InlineCode.Render("NO_CACHE"),
+		)}
+	}
+	// use the API the model was resolved to, which takes aliases and
+	// fallbacks into account.
+	api := mods.model.API
+	if api == "" {
+		api = cfg.API
+	}
+	if err := db.SetAPI(id, api); err != nil {
+		return modsError{err, fmt.Sprintf(
+			"There was a problem writing %s to the cache. Use %s / %s to disable it.",
+			cfg.cacheWriteToID,
 			stderrStyles().InlineCode.Render("--no-cache"),
 			stderrStyles().InlineCode.Render("NO_CACHE"),
 		)}
 	}
+	if cfg.CacheTTL > 0 {
+		if err := cache.writeTTL(id, time.Now().Add(cfg.CacheTTL)); err != nil {
+			return modsError{err, "There was a problem setting the conversation's expiry."}
+		}
+	}
+	if len(cfg.Tags) > 0 {
+		if err := db.Tag(id, cfg.Tags); err != nil {
+			return modsError{err, "There was a problem tagging the conversation."}
+		}
+	}
 
-	if !config.Quiet {
+	tokens := mods.tokensUsed
+	if tokens == 0 {
+		tokens = estimateTokens(messagesText(mods.messages))
+	}
+	if err := db.SetTokens(id, tokens); err != nil {
+		return modsError{err, fmt.Sprintf(
+			"There was a problem writing %s to the cache. Use %s / %s to disable it.",
+			cfg.cacheWriteToID,
+			stderrStyles().InlineCode.Render("--no-cache"),
+			stderrStyles().InlineCode.Render("NO_CACHE"),
+		)}
+	}
+	// the search index is best effort, missing entries are added back on the
+	// next search.
+	_ = db.Index(id, messagesText(mods.messages))
+
+	if err := evictOversizedConversations(db, cache, id, cfg.CacheMaxSize, cfg.CacheMaxCount); err != nil {
+		return modsError{err, "There was a problem deleting old conversations."}
+	}
+
+	if !cfg.Quiet {
 		fmt.Fprintln(
 			os.Stderr,
 			"\nConversation saved:",
-			stderrStyles().InlineCode.Render(config.cacheWriteToID[:sha1short]),
+			stderrStyles().InlineCode.Render(cfg.cacheWriteToID[:sha1short]),
 			stderrStyles().Comment.Render(title),
 		)
 	}
 	return nil
 }
 
+func showParallel(mods *Mods) error {
+	results := mods.parallel
+	if !slices.ContainsFunc(results, func(r *Mods) bool { return r.Error == nil }) {
+		return *results[0].Error
+	}
+
+	if isOutputTTY() && !config.Raw {
+		printParallel(os.Stdout, results, mods.width)
+	} else {
+		fmt.Println(parallelText(results))
+	}
+
+	text := parallelText(results)
+	switch {
+	case config.OutputFile != "" && config.Append:
+		if err := appendOutputFile(config.OutputFile, text, config.AppendSeparator); err != nil {
+			return err
+		}
+	case config.OutputFile != "":
+		if err := writeOutputFile(config.OutputFile, text); err != nil {
+			return err
+		}
+	}
+	if config.Copy {
+		if err := copyOutput(text); err != nil {
+			handleError(err)
+		}
+	}
+
+	if config.NoCache {
+		return saveConversation(mods)
+	}
+	return saveParallel(results)
+}
+
+func showTimes(mods *Mods) error {
+	results := mods.parallel
+	if !slices.ContainsFunc(results, func(r *Mods) bool { return r.Error == nil }) {
+		return *results[0].Error
+	}
+
+	fmt.Println(timesText(results, defaultAppendSeparator))
+	if config.OutputFile != "" {
+		for i, r := range results {
+			if err := writeOutputFile(numberedPath(config.OutputFile, i+1), parallelResult(r)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if config.NoCache {
+		return saveConversation(mods)
+	}
+	return saveTimes(results)
+}
+
+// dryRunRequest is what --dry-run prints.
+type dryRunRequest struct {
+	API         string                         `json:"api"`
+	Model       string                         `json:"model"`
+	Messages    []openai.ChatCompletionMessage `json:"messages"`
+	Temperature float32                        `json:"temperature"`
+	TopP        float32                        `json:"top_p"`
+	MaxTokens   int                            `json:"max_tokens,omitempty"`
+	Stop        []string                       `json:"stop,omitempty"`
+}
+
+func printDryRun(w io.Writer, mods *Mods) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(dryRunRequest{
+		API:         mods.model.API,
+		Model:       mods.model.Name,
+		Messages:    mods.messages,
+		Temperature: mods.Config.Temperature,
+		TopP:        mods.Config.TopP,
+		MaxTokens:   mods.Config.MaxTokens,
+		Stop:        mods.Config.Stop,
+	}); err != nil {
+		return fmt.Errorf("printDryRun: %w", err)
+	}
+	return nil
+}
+
+func printEstimate(w io.Writer, messages []openai.ChatCompletionMessage, mod Model, raw bool) {
+	text := messagesText(messages)
+	tokens := estimateTokens(text)
+	if raw {
+		fmt.Fprintln(w, tokens)
+		return
+	}
+
+	styles := stdoutStyles()
+	fmt.Fprintf(
+		w,
+		"%s ~%d tokens (%d chars)\n",
+		styles.FlagDesc.Render("Estimated prompt size:"),
+		tokens,
+		len(text),
+	)
+	if mod.MaxChars <= 0 {
+		return
+	}
+	fmt.Fprintf(
+		w,
+		"%s %d chars (~%d tokens)\n",
+		styles.FlagDesc.Render("Limit for "+mod.Name+":"),
+		mod.MaxChars,
+		mod.MaxChars/4, //nolint:mnd
+	)
+	// only the user input is truncated, see setupStreamContext.
+	if len(lastPrompt(messages)) > mod.MaxChars {
+		fmt.Fprintf(
+			w,
+			"The input is over the limit and will be truncated, use %s to send it whole.\n",
+			styles.InlineCode.Render("--no-limit"),
+		)
+	}
+}
+
+func copyOutput(s string) error {
+	// OSC 52 only makes sense if there's a terminal to interpret it.
+	var osc52 *termenv.Output
+	if isOutputTTY() || isErrTTY() {
+		osc52 = termenv.NewOutput(os.Stderr)
+	}
+	msg, err := copyText(s, clipboard.WriteAll, osc52)
+	if err != nil {
+		return err
+	}
+	if !config.Quiet {
+		fmt.Fprintln(os.Stderr, "\n"+msg)
+	}
+	return nil
+}
+
+// copyText copies s with write, falling back to an OSC 52 sequence written to
+// osc52, if any. It returns a message telling what was done.
+func copyText(s string, write func(string) error, osc52 *termenv.Output) (string, error) {
+	err := write(s)
+	if err == nil {
+		return "Response copied to the clipboard.", nil
+	}
+	if osc52 == nil {
+		return "", modsError{err, "Couldn't copy the response to the clipboard."}
+	}
+	osc52.Copy(s)
+	return "Response sent to the terminal clipboard, if it supports OSC 52.", nil
+}
+
+const defaultAppendSeparator = "\n\n---\n\n"
+
+func writeOutputFile(path, content string) error {
+	if err := checkOutputDir(path); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil { //nolint:gosec,mnd
+		return modsError{err, "Couldn't write output file."}
+	}
+	return nil
+}
+
+// appendOutputFile appends content to the given file, writing sep before it
+// if the file already has content.
+func appendOutputFile(path, content, sep string) error {
+	if err := checkOutputDir(path); err != nil {
+		return err
+	}
+	if info, err := os.Stat(path); err == nil && info.Size() > 0 {
+		content = sep + content
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644) //nolint:gosec,mnd
+	if err != nil {
+		return modsError{err, "Couldn't write output file."}
+	}
+	if _, err := f.WriteString(content); err != nil {
+		_ = f.Close()
+		return modsError{err, "Couldn't write output file."}
+	}
+	if err := f.Close(); err != nil {
+		return modsError{err, "Couldn't write output file."}
+	}
+	return nil
+}
+
+func checkOutputDir(path string) error {
+	dir := filepath.Dir(path)
+	if _, err := os.Stat(dir); err != nil {
+		return modsError{err, fmt.Sprintf(
+			"Output directory %s does not exist.",
+			stderrStyles().InlineCode.Render(dir),
+		)}
+	}
+	return nil
+}
+
 func isNoArgs() bool {
 	return config.Prefix == "" &&
+		config.PromptFile == "" &&
 		config.Show == "" &&
 		!config.ShowLast &&
 		config.Delete == "" &&
 		config.DeleteOlderThan == 0 &&
 		!config.ShowHelp &&
 		!config.List &&
+		!config.ListArchived &&
+		config.Archive == "" &&
+		config.Unarchive == "" &&
+		config.Pin == "" &&
+		config.Unpin == "" &&
+		config.Import == "" &&
+		config.MigrateModel == "" &&
+		config.BackupDB == "" &&
+		!config.Vacuum &&
+		config.Search == "" &&
+		config.Fork == "" &&
+		!config.Stats &&
 		!config.ListRoles &&
+		!config.ListModels &&
 		!config.Dirs &&
 		!config.Settings &&
 		!config.ResetSettings
@@ -1,8 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/muesli/termenv"
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
 )
 
 func TestIsCompletionCmd(t *testing.T) {
@@ -60,3 +68,270 @@ func TestIsManCmd(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteOutputFile(t *testing.T) {
+	t.Run("raw output", func(t *testing.T) {
+		output := "# Title\n\nsome **markdown**\n"
+		path := filepath.Join(t.TempDir(), "out.md")
+		require.NoError(t, writeOutputFile(path, output))
+
+		bts, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, output, string(bts))
+	})
+
+	t.Run("missing directory", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "nope", "out.md")
+		var merr modsError
+		require.ErrorAs(t, writeOutputFile(path, "content"), &merr)
+		require.ErrorIs(t, merr.err, os.ErrNotExist)
+	})
+}
+
+func TestAppendOutputFile(t *testing.T) {
+	t.Run("accumulates", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "out.md")
+		for _, s := range []string{"first", "second", "third"} {
+			require.NoError(t, appendOutputFile(path, s, defaultAppendSeparator))
+		}
+
+		bts, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, "first\n\n---\n\nsecond\n\n---\n\nthird", string(bts))
+	})
+
+	t.Run("custom separator", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "out.md")
+		require.NoError(t, os.WriteFile(path, []byte("existing"), 0o644))
+		require.NoError(t, appendOutputFile(path, "new", "\n"))
+
+		bts, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, "existing\nnew", string(bts))
+	})
+
+	t.Run("missing directory", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "nope", "out.md")
+		var merr modsError
+		require.ErrorAs(t, appendOutputFile(path, "content", "\n"), &merr)
+		require.ErrorIs(t, merr.err, os.ErrNotExist)
+	})
+}
+
+func TestPrintModels(t *testing.T) {
+	apis := APIs{
+		{
+			Name: "openai",
+			Models: map[string]Model{
+				"gpt-4o":      {Aliases: []string{"4o"}, MaxChars: 392000},
+				"gpt-4o-mini": {Aliases: []string{"4o-mini", "mini"}, MaxChars: 392000},
+			},
+		},
+		{
+			Name: "ollama",
+			Models: map[string]Model{
+				"llama3": {},
+			},
+		},
+	}
+
+	t.Run("raw", func(t *testing.T) {
+		var b bytes.Buffer
+		printModels(&b, apis, true)
+		require.Equal(t, strings.Join([]string{
+			"openai\tgpt-4o\t4o\t392000",
+			"openai\tgpt-4o-mini\t4o-mini,mini\t392000",
+			"ollama\tllama3\t\t0",
+			"",
+		}, "\n"), b.String())
+	})
+
+	t.Run("styled", func(t *testing.T) {
+		var b bytes.Buffer
+		printModels(&b, apis, false)
+		lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+		require.Len(t, lines, 3)
+		require.Contains(t, lines[0], "gpt-4o")
+		require.Contains(t, lines[0], "392000 chars")
+		require.Contains(t, lines[1], "4o-mini, mini")
+		require.Contains(t, lines[2], "llama3")
+	})
+}
+
+func TestPrintStats(t *testing.T) {
+	stats := ConvoStats{
+		Conversations: 2,
+		TotalTokens:   300,
+		AverageTokens: 150,
+		TopModel:      "gpt-4o",
+		TopAPI:        "openai",
+	}
+
+	t.Run("raw", func(t *testing.T) {
+		var b bytes.Buffer
+		require.NoError(t, printStats(&b, stats, true))
+		require.JSONEq(t, `{
+			"conversations": 2,
+			"total_tokens": 300,
+			"average_tokens": 150,
+			"top_model": "gpt-4o",
+			"top_api": "openai",
+			"size_bytes": 0,
+			"fragmented_bytes": 0
+		}`, b.String())
+	})
+
+	t.Run("suggests vacuum", func(t *testing.T) {
+		var b bytes.Buffer
+		require.NoError(t, printStats(&b, ConvoStats{SizeBytes: 1000, FragmentedBytes: 300}, false))
+		require.Contains(t, b.String(), "300 bytes of the database are unused")
+
+		b.Reset()
+		require.NoError(t, printStats(&b, ConvoStats{SizeBytes: 1000, FragmentedBytes: 100}, false))
+		require.NotContains(t, b.String(), "--vacuum")
+	})
+
+	t.Run("styled", func(t *testing.T) {
+		var b bytes.Buffer
+		require.NoError(t, printStats(&b, ConvoStats{}, false))
+		require.Contains(t, b.String(), "Conversations: 0")
+		require.Contains(t, b.String(), "none")
+	})
+}
+
+func TestPrintEstimate(t *testing.T) {
+	messages := []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: "be brief"},
+		{Role: openai.ChatMessageRoleUser, Content: strings.Repeat("a", 391)},
+	}
+	mod := Model{Name: "gpt-4o", MaxChars: 1000}
+
+	t.Run("raw", func(t *testing.T) {
+		var b bytes.Buffer
+		printEstimate(&b, messages, mod, true)
+		require.Equal(t, "100\n", b.String())
+	})
+
+	t.Run("under the limit", func(t *testing.T) {
+		var b bytes.Buffer
+		printEstimate(&b, messages, mod, false)
+		require.Contains(t, b.String(), "~100 tokens (400 chars)")
+		require.Contains(t, b.String(), "1000 chars (~250 tokens)")
+		require.NotContains(t, b.String(), "--no-limit")
+	})
+
+	t.Run("over the limit", func(t *testing.T) {
+		var b bytes.Buffer
+		printEstimate(&b, messages, Model{Name: "tiny", MaxChars: 100}, false)
+		require.Contains(t, b.String(), "--no-limit")
+	})
+
+	t.Run("only the input counts", func(t *testing.T) {
+		var b bytes.Buffer
+		printEstimate(&b, []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: strings.Repeat("s", 200)},
+			{Role: openai.ChatMessageRoleUser, Content: strings.Repeat("u", 200)},
+			{Role: openai.ChatMessageRoleAssistant, Content: strings.Repeat("a", 200)},
+			{Role: openai.ChatMessageRoleUser, Content: strings.Repeat("u", 50)},
+		}, Model{Name: "tiny", MaxChars: 100}, false)
+		require.NotContains(t, b.String(), "--no-limit")
+	})
+}
+
+func TestValidateRename(t *testing.T) {
+	oldConfig := config
+	t.Cleanup(func() { config = oldConfig })
+
+	for name, tc := range map[string]struct {
+		config Config
+		reason string
+	}{
+		"empty title": {
+			config: Config{Rename: "", ShowLast: true},
+			reason: "needs a title",
+		},
+		"blank title": {
+			config: Config{Rename: "  \t", ShowLast: true},
+			reason: "needs a title",
+		},
+		"no conversation": {
+			config: Config{Rename: "new title"},
+			reason: "needs a conversation",
+		},
+		"ok": {
+			config: Config{Rename: "new title", ShowLast: true},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config = tc.config
+			err := validateRename()
+			if tc.reason == "" {
+				require.NoError(t, err)
+				return
+			}
+			var merr modsError
+			require.ErrorAs(t, err, &merr)
+			require.Contains(t, merr.reason, tc.reason)
+		})
+	}
+}
+
+func TestValidateConversationID(t *testing.T) {
+	oldConfig := config
+	t.Cleanup(func() { config = oldConfig })
+
+	for id, valid := range map[string]bool{
+		"df31ae23ab8b75b5643c2f846c570997edc71333": true,
+		"DF31AE23AB8B75B5643C2F846C570997EDC71333": false,
+		"df31ae23": false,
+		"df31ae23ab8b75b5643c2f846c570997edc7133g":   false,
+		"x df31ae23ab8b75b5643c2f846c570997edc71333": false,
+	} {
+		t.Run(id, func(t *testing.T) {
+			config = Config{ConversationID: id}
+			if valid {
+				require.NoError(t, validateConversationID())
+			} else {
+				require.Error(t, validateConversationID())
+			}
+		})
+	}
+}
+
+func TestCopyText(t *testing.T) {
+	ok := func(string) error { return nil }
+	fail := func(string) error { return errors.New("no clipboard") }
+
+	t.Run("clipboard", func(t *testing.T) {
+		var b bytes.Buffer
+		msg, err := copyText("hi", ok, termenv.NewOutput(&b))
+		require.NoError(t, err)
+		require.Equal(t, "Response copied to the clipboard.", msg)
+		require.Empty(t, b.String())
+	})
+
+	t.Run("osc52 fallback", func(t *testing.T) {
+		var b bytes.Buffer
+		msg, err := copyText("hi", fail, termenv.NewOutput(&b))
+		require.NoError(t, err)
+		require.Contains(t, msg, "OSC 52")
+		require.Contains(t, b.String(), "]52;c;aGk=")
+	})
+
+	t.Run("no terminal", func(t *testing.T) {
+		_, err := copyText("hi", fail, nil)
+		var merr modsError
+		require.ErrorAs(t, err, &merr)
+		require.Equal(t, "Couldn't copy the response to the clipboard.", merr.reason)
+	})
+}
+
+func TestIsNoArgsPromptFile(t *testing.T) {
+	oldConfig := config
+	t.Cleanup(func() { config = oldConfig })
+
+	config = defaultConfig()
+	require.True(t, isNoArgs())
+	config.PromptFile = "prompt.md"
+	require.False(t, isNoArgs())
+}
@@ -12,11 +12,60 @@ func lastPrompt(messages []openai.ChatCompletionMessage) string {
 		if msg.Role != openai.ChatMessageRoleUser {
 			continue
 		}
-		result = msg.Content
+		result = messageText(msg)
 	}
 	return result
 }
 
+// messageText returns the text content of a message, including the text
+// parts of multi-part messages.
+func messageText(msg openai.ChatCompletionMessage) string {
+	if len(msg.MultiContent) == 0 {
+		return msg.Content
+	}
+	var parts []string
+	for _, part := range msg.MultiContent {
+		if part.Type == openai.ChatMessagePartTypeText {
+			parts = append(parts, part.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// messagesText returns the text content of all the given messages.
+func messagesText(messages []openai.ChatCompletionMessage) string {
+	parts := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		parts = append(parts, messageText(msg))
+	}
+	return strings.Join(parts, "\n")
+}
+
+// messageMarkdown renders a message as a markdown paragraph prefixed with
+// its role.
+func messageMarkdown(msg openai.ChatCompletionMessage) string {
+	prefix := "\n"
+	switch msg.Role {
+	case openai.ChatMessageRoleSystem:
+		prefix += "**System**: "
+	case openai.ChatMessageRoleUser:
+		prefix += "**Prompt**: "
+	case openai.ChatMessageRoleAssistant:
+		prefix += "**Assistant**: "
+	case openai.ChatMessageRoleFunction:
+		prefix += "**Function**: "
+	case openai.ChatMessageRoleTool:
+		prefix += "**Tool**: "
+	}
+	return prefix + messageText(msg) + "\n"
+}
+
+// estimateTokens approximates the number of tokens in s, using the rule of
+// thumb of 1 token for every 4 characters.
+func estimateTokens(s string) int {
+	return len(s) / 4 //nolint:mnd
+}
+
 func firstLine(s string) string {
 	first, _, _ := strings.Cut(s, "\n")
 	return first
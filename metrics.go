@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// metricsShutdownTimeout is how long the metrics server is given to finish
+// the scrapes in flight when mods quits.
+const metricsShutdownTimeout = time.Second
+
+// metricsRegistry holds the metrics --metrics-addr exposes. It is kept apart
+// from the default registry so that only mods' own metrics are served.
+var metricsRegistry = prometheus.NewRegistry()
+
+var (
+	requestDuration = promauto.With(metricsRegistry).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mods_request_duration_seconds",
+		Help:    "How long requests took, retries included.",
+		Buckets: []float64{0.5, 1, 2.5, 5, 10, 20, 40, 80, 160}, //nolint:mnd
+	}, []string{"model", "api"})
+	tokensTotal = promauto.With(metricsRegistry).NewCounterVec(prometheus.CounterOpts{
+		Name: "mods_tokens_total",
+		Help: "Tokens sent and received.",
+	}, []string{"direction"})
+	retriesTotal = promauto.With(metricsRegistry).NewCounterVec(prometheus.CounterOpts{
+		Name: "mods_retries_total",
+		Help: "Requests retried.",
+	}, []string{"reason"})
+	errorsTotal = promauto.With(metricsRegistry).NewCounterVec(prometheus.CounterOpts{
+		Name: "mods_errors_total",
+		Help: "Requests that failed.",
+	}, []string{"code"})
+)
+
+// serveMetrics starts serving the metrics on addr in the background, and
+// returns a function that stops it.
+func serveMetrics(addr string) (func(), error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, modsError{err, "Couldn't start the metrics server."}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{}))
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: metricsShutdownTimeout}
+	go func() { _ = srv.Serve(ln) }()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), metricsShutdownTimeout)
+			defer cancel()
+			_ = srv.Shutdown(ctx)
+		})
+	}, nil
+}
+
+// recordRequest records the duration and tokens of the request that just
+// finished.
+func (m *Mods) recordRequest() {
+	requestDuration.WithLabelValues(m.model.Name, m.model.API).
+		Observe(m.finishedAt.Sub(m.startedAt).Seconds())
+	input, output := m.usage()
+	tokensTotal.WithLabelValues("input").Add(float64(input))
+	tokensTotal.WithLabelValues("output").Add(float64(output))
+}
+
+// retryReason names why err is being retried.
+func retryReason(err error) string {
+	ae := &openai.APIError{}
+	if !errors.As(err, &ae) {
+		return "other"
+	}
+	switch {
+	case ae.HTTPStatusCode == http.StatusTooManyRequests:
+		return "rate_limit"
+	case ae.Code == "context_length_exceeded":
+		return "context_length"
+	case ae.HTTPStatusCode == http.StatusNotFound:
+		return "fallback"
+	case ae.HTTPStatusCode >= http.StatusInternalServerError:
+		return "server_error"
+	default:
+		return "other"
+	}
+}
+
+// errorCode returns the HTTP status code of err, or "none" when the request
+// did not get a response.
+func errorCode(err error) string {
+	ae := &openai.APIError{}
+	if errors.As(err, &ae) && ae.HTTPStatusCode > 0 {
+		return strconv.Itoa(ae.HTTPStatusCode)
+	}
+	return "none"
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryReason(t *testing.T) {
+	for expected, err := range map[string]error{
+		"rate_limit":     &openai.APIError{HTTPStatusCode: http.StatusTooManyRequests},
+		"context_length": &openai.APIError{HTTPStatusCode: http.StatusBadRequest, Code: "context_length_exceeded"},
+		"fallback":       &openai.APIError{HTTPStatusCode: http.StatusNotFound},
+		"server_error":   &openai.APIError{HTTPStatusCode: http.StatusBadGateway},
+		"other":          io.ErrUnexpectedEOF,
+	} {
+		require.Equal(t, expected, retryReason(err))
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	require.Equal(t, "429", errorCode(&openai.APIError{HTTPStatusCode: http.StatusTooManyRequests}))
+	require.Equal(t, "none", errorCode(io.ErrUnexpectedEOF))
+}
+
+func TestServeMetrics(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	stop, err := serveMetrics(addr)
+	require.NoError(t, err)
+
+	// other tests send requests too.
+	tokensTotal.Reset()
+	retriesTotal.Reset()
+
+	start := time.Now()
+	mods := &Mods{
+		Config:           &Config{MaxRetries: 1},
+		model:            Model{Name: "gpt-4o", API: "metrics-test"},
+		startedAt:        start,
+		finishedAt:       start.Add(2 * time.Second),
+		promptTokens:     10,
+		completionTokens: 5,
+	}
+	mods.recordRequest()
+	mods.retry("", modsError{err: &openai.APIError{HTTPStatusCode: http.StatusTooManyRequests}})
+
+	resp, err := http.Get("http://" + addr + "/metrics")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	require.Contains(t, string(body), `mods_request_duration_seconds_count{api="metrics-test",model="gpt-4o"} 1`)
+	require.Contains(t, string(body), `mods_tokens_total{direction="input"} 10`)
+	require.Contains(t, string(body), `mods_tokens_total{direction="output"} 5`)
+	require.Contains(t, string(body), `mods_retries_total{reason="rate_limit"} 1`)
+
+	stop()
+	stop()
+	_, err = http.Get("http://" + addr + "/metrics") //nolint:bodyclose
+	require.Error(t, err)
+}
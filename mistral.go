@@ -0,0 +1,13 @@
+package main
+
+const mistralBaseURL = "https://api.mistral.ai/v1"
+
+// mistralBodyFields returns the Mistral specific request fields for the given
+// API settings.
+func mistralBodyFields(api API) map[string]any {
+	fields := map[string]any{}
+	if api.SafePrompt {
+		fields["safe_prompt"] = true
+	}
+	return fields
+}
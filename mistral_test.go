@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtraBodyTransport(t *testing.T) {
+	for name, tc := range map[string]struct {
+		body   string
+		fields map[string]any
+		want   string
+	}{
+		"no fields": {
+			body: `{"model":"mistral-large-latest"}`,
+			want: `{"model":"mistral-large-latest"}`,
+		},
+		"safe prompt": {
+			body:   `{"model":"mistral-large-latest","stream":true}`,
+			fields: mistralBodyFields(API{SafePrompt: true}),
+			want:   `{"model":"mistral-large-latest","safe_prompt":true,"stream":true}`,
+		},
+		"overrides": {
+			body:   `{"model":"a","seed":12345678901234567}`,
+			fields: map[string]any{"model": "b"},
+			want:   `{"model":"b","seed":12345678901234567}`,
+		},
+		"not json": {
+			body:   `hello`,
+			fields: map[string]any{"model": "b"},
+			want:   `hello`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var got string
+			ts := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				bts, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				got = string(bts)
+			}))
+			t.Cleanup(ts.Close)
+
+			client := withExtraBody(&http.Client{}, tc.fields)
+			resp, err := client.Post(ts.URL, "application/json", strings.NewReader(tc.body))
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+			if json.Valid([]byte(tc.want)) {
+				require.JSONEq(t, tc.want, got)
+				return
+			}
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func mistralChunk(content, finishReason string) string {
+	finish := "null"
+	if finishReason != "" {
+		finish = `"` + finishReason + `"`
+	}
+	return fmt.Sprintf(
+		`data: {"id":"cmpl-1","object":"chat.completion.chunk","created":1720000000,"model":"mistral-small-latest","choices":[{"index":0,"delta":{"role":"assistant","content":%q},"finish_reason":%s}]}`,
+		content, finish,
+	)
+}
+
+func TestMistralStream(t *testing.T) {
+	for name, tc := range map[string]struct {
+		events  []string
+		content string
+		finish  openai.FinishReason
+	}{
+		"single chunk": {
+			events: []string{
+				mistralChunk("Hello!", "stop"),
+			},
+			content: "Hello!",
+			finish:  openai.FinishReasonStop,
+		},
+		"multiple chunks": {
+			events: []string{
+				mistralChunk("", ""),
+				mistralChunk("Hel", ""),
+				mistralChunk("lo, ", ""),
+				mistralChunk("world!", ""),
+				mistralChunk("", "stop"),
+			},
+			content: "Hello, world!",
+			finish:  openai.FinishReasonStop,
+		},
+		"empty lines": {
+			events: []string{
+				"",
+				mistralChunk("a", ""),
+				"",
+				"",
+				mistralChunk("b", "stop"),
+			},
+			content: "ab",
+			finish:  openai.FinishReasonStop,
+		},
+		"model length": {
+			events: []string{
+				mistralChunk("cut", ""),
+				mistralChunk(" off", "model_length"),
+			},
+			content: "cut off",
+			finish:  "model_length",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var body map[string]any
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+				w.Header().Set("Content-Type", "text/event-stream")
+				for _, event := range tc.events {
+					_, _ = fmt.Fprintf(w, "%s\n\n", event)
+				}
+				_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+			}))
+			t.Cleanup(ts.Close)
+
+			ccfg := openai.DefaultConfig("fake")
+			ccfg.BaseURL = ts.URL
+			ccfg.HTTPClient = withExtraBody(ccfg.HTTPClient, mistralBodyFields(API{SafePrompt: true}))
+			stream, err := openai.NewClientWithConfig(ccfg).CreateChatCompletionStream(
+				context.Background(),
+				openai.ChatCompletionRequest{
+					Model:    "mistral-small-latest",
+					Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}},
+				},
+			)
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = stream.Close() })
+
+			var content strings.Builder
+			var finish openai.FinishReason
+			for {
+				resp, err := stream.Recv()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				require.NoError(t, err)
+				for _, choice := range resp.Choices {
+					content.WriteString(choice.Delta.Content)
+					if choice.FinishReason != "" {
+						finish = choice.FinishReason
+					}
+				}
+			}
+			require.Equal(t, tc.content, content.String())
+			require.Equal(t, tc.finish, finish)
+			require.Equal(t, true, body["safe_prompt"])
+			require.Equal(t, true, body["stream"])
+		})
+	}
+}
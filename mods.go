@@ -6,9 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"math"
+	"net"
 	"net/http"
-	"net/url"
 	"os"
 	"os/exec"
 	"regexp"
@@ -25,6 +26,7 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/x/exp/ordered"
 	openai "github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type state int
@@ -43,10 +45,24 @@ const (
 type Mods struct {
 	Output        string
 	Input         string
+	stdin         string
 	Styles        styles
 	Error         *modsError
 	state         state
 	retries       int
+	retryAfter    time.Duration
+	tokensUsed    int
+	cacheHit      bool
+	startedAt     time.Time
+	firstTokenAt  time.Time
+	finishedAt    time.Time
+	logprobs      []openai.ChatCompletionTokenLogprob
+	responseKey   string
+	responseHit   bool
+	echoLen       int
+	prepared      bool
+	parallel      []*Mods
+	model         Model
 	system        string
 	renderer      *lipgloss.Renderer
 	glam          *glamour.TermRenderer
@@ -65,6 +81,30 @@ type Mods struct {
 
 	content      []string
 	contentMutex *sync.Mutex
+
+	// token counts and cost of the request, for --max-cost.
+	promptTokens     int
+	completionTokens int
+	cost             float64
+
+	// completions are the answers of a request with --completions, of which
+	// Output shows them all.
+	completions []string
+
+	// schema is the --schema the response must follow.
+	schema *responseSchema
+
+	// stopMetrics stops the --metrics-addr server.
+	stopMetrics func()
+
+	// ctx carries the mods.request span, and streamCtx the llm.stream one.
+	ctx        context.Context
+	streamCtx  context.Context
+	streamSpan trace.Span
+
+	// logger writes to --log-file, and closeLog closes it.
+	logger   *slog.Logger
+	closeLog func()
 }
 
 func newMods(r *lipgloss.Renderer, cfg *Config, db *convoDB, cache *convoCache) *Mods {
@@ -95,6 +135,10 @@ type completionOutput struct {
 	stream  chatCompletionReceiver
 }
 
+// dryRunMsg is a tea.Msg sent when the messages were assembled but --dry-run
+// or --estimate-tokens prevent sending them.
+type dryRunMsg struct{}
+
 type chatCompletionReceiver interface {
 	Recv() (openai.ChatCompletionStreamResponse, error)
 	Close() error
@@ -134,14 +178,31 @@ func (m *Mods) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.Config.DeleteOlderThan != 0 ||
 			m.Config.ShowHelp ||
 			m.Config.List ||
+			m.Config.ListArchived ||
+			m.Config.Archive != "" ||
+			m.Config.Unarchive != "" ||
+			m.Config.Import != "" ||
+			m.Config.MigrateModel != "" ||
+			m.Config.Search != "" ||
+			m.Config.Export != "" ||
+			m.Config.Rename != "" ||
+			m.Config.Fork != "" ||
+			m.Config.Stats ||
 			m.Config.ListRoles ||
+			m.Config.ListModels ||
 			m.Config.Settings ||
-			m.Config.ResetSettings {
+			m.Config.ResetSettings ||
+			m.Config.Pin != "" ||
+			m.Config.Unpin != "" ||
+			m.Config.BackupDB != "" ||
+			m.Config.Vacuum {
 			return m, m.quit
 		}
 
+		// the parts of the prompt to echo before the response.
+		var echo []string
 		if m.Config.IncludePromptArgs {
-			m.appendToOutput(m.Config.Prefix + "\n\n")
+			echo = append(echo, m.Config.Prefix)
 		}
 
 		if m.Config.IncludePrompt > 0 {
@@ -149,16 +210,52 @@ func (m *Mods) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if len(parts) > m.Config.IncludePrompt {
 				parts = parts[0:m.Config.IncludePrompt]
 			}
-			m.appendToOutput(strings.Join(parts, "\n") + "\n")
+			echo = append(echo, strings.Join(parts, "\n"))
 		}
+
+		if m.Config.IncludePromptRange != "" {
+			parts := strings.Split(m.Input, "\n")
+			start, end, err := parseRange(m.Config.IncludePromptRange, len(parts))
+			if err != nil {
+				m.Error = &modsError{err, "Invalid --prompt-range value."}
+				m.state = errorState
+				return m, m.quit
+			}
+			echo = append(echo, strings.Join(parts[start:end], "\n"))
+		}
+
+		if len(echo) > 0 {
+			m.appendToOutput(strings.Join(echo, "\n\n") + m.Config.Separator)
+		}
+		// the response cache only keeps what comes after the echo.
+		m.echoLen = len(m.Output)
 		m.state = requestState
+		if len(m.Config.Parallel) > 0 {
+			cmds = append(cmds, m.startParallelCmd(msg.content))
+			break
+		}
+		if m.Config.Times > 1 {
+			cmds = append(cmds, m.startTimesCmd(msg.content))
+			break
+		}
 		cmds = append(cmds, m.startCompletionCmd(msg.content))
+	case dryRunMsg:
+		m.state = doneState
+		return m, m.quit
+	case parallelOutput:
+		m.parallel = msg.results
+		m.state = doneState
+		return m, m.quit
 	case completionOutput:
 		if msg.stream == nil {
 			m.state = doneState
 			return m, m.quit
 		}
 		if msg.content != "" {
+			if m.firstTokenAt.IsZero() {
+				m.firstTokenAt = time.Now()
+				m.log().Info("first token received", "duration_ms", m.firstTokenAt.Sub(m.startedAt).Milliseconds())
+			}
 			m.appendToOutput(msg.content)
 			m.state = responseState
 		}
@@ -228,7 +325,7 @@ func (m *Mods) View() string {
 		m.content = []string{}
 		m.contentMutex.Unlock()
 	case doneState:
-		if !isOutputTTY() {
+		if !isOutputTTY() && !m.Config.DryRun && !m.Config.EstimateTokens {
 			fmt.Printf("\n")
 		}
 		return ""
@@ -240,15 +337,37 @@ func (m *Mods) quit() tea.Msg {
 	if m.cancelRequest != nil {
 		m.cancelRequest()
 	}
+	if m.stopMetrics != nil {
+		m.stopMetrics()
+	}
+	if m.closeLog != nil {
+		m.closeLog()
+	}
 	return tea.Quit()
 }
 
 func (m *Mods) retry(content string, err modsError) tea.Msg {
 	m.retries++
+	retriesTotal.WithLabelValues(retryReason(err.err)).Inc()
 	if m.retries >= m.Config.MaxRetries {
 		return err
 	}
 	wait := time.Millisecond * 100 * time.Duration(math.Pow(2, float64(m.retries))) //nolint:mnd
+	if m.retryAfter > 0 {
+		maxWait := m.Config.MaxRetryWait
+		if maxWait <= 0 {
+			maxWait = maxRetryAfter
+		}
+		wait = min(m.retryAfter, maxWait)
+		m.retryAfter = 0
+	}
+	m.log().Warn(
+		"retrying request",
+		"attempt", m.retries+1,
+		"reason", retryReason(err.err),
+		"error", err.reason,
+		"wait_ms", wait.Milliseconds(),
+	)
 	time.Sleep(wait)
 	return completionInput{content}
 }
@@ -259,6 +378,11 @@ func (m *Mods) startCompletionCmd(content string) tea.Cmd {
 	}
 
 	return func() tea.Msg {
+		if m.startedAt.IsZero() {
+			m.startedAt = time.Now()
+		}
+		m.prepared = false
+
 		var ok bool
 		var mod Model
 		var api API
@@ -267,9 +391,13 @@ func (m *Mods) startCompletionCmd(content string) tea.Cmd {
 		var cccfg CohereClientConfig
 		var occfg OllamaClientConfig
 		var gccfg GoogleClientConfig
+		var bccfg BedrockClientConfig
 
 		cfg := m.Config
 		mod, ok = cfg.Models[cfg.Model]
+		if !ok {
+			mod, ok = openRouterModel(cfg.Model, cfg.MaxInputChars)
+		}
 		if !ok {
 			if cfg.API == "" {
 				return modsError{
@@ -311,12 +439,140 @@ func (m *Mods) startCompletionCmd(content string) tea.Cmd {
 			}
 		}
 
+		if cfg.NoStream && !supportsNoStream(mod.API) {
+			return modsError{
+				err: newUserErrorf(
+					"Non-streamed responses are not supported by the %s API yet.",
+					mod.API,
+				),
+				reason: fmt.Sprintf(
+					"Model %s does not support %s.",
+					m.Styles.InlineCode.Render(mod.Name),
+					m.Styles.InlineCode.Render("--no-stream"),
+				),
+			}
+		}
+
+		if cfg.Completions > 1 && !supportsCompletions(mod.API) {
+			return modsError{
+				err: newUserErrorf(
+					"Several completions are not supported by the %s API.",
+					mod.API,
+				),
+				reason: fmt.Sprintf(
+					"Model %s does not support %s.",
+					m.Styles.InlineCode.Render(mod.Name),
+					m.Styles.InlineCode.Render("--completions"),
+				),
+			}
+		}
+
+		if cfg.ResponseSchema != "" {
+			if mod.API != "openai" {
+				return modsError{
+					err: newUserErrorf(
+						"Structured outputs are not supported by the %s API.",
+						mod.API,
+					),
+					reason: fmt.Sprintf(
+						"Model %s does not support %s.",
+						m.Styles.InlineCode.Render(mod.Name),
+						m.Styles.InlineCode.Render("--schema"),
+					),
+				}
+			}
+			schema, err := loadResponseSchema(cfg.ResponseSchema)
+			if err != nil {
+				return err
+			}
+			m.schema = schema
+		}
+
+		if len(cfg.Images) > 0 && !supportsImages(mod) {
+			return modsError{
+				err: newUserErrorf(
+					"Images are not supported by the %s API or this model yet.",
+					mod.API,
+				),
+				reason: fmt.Sprintf(
+					"Model %s does not support %s.",
+					m.Styles.InlineCode.Render(mod.Name),
+					m.Styles.InlineCode.Render("--image"),
+				),
+			}
+		}
+
+		if mod.MaxChars == 0 {
+			mod.MaxChars = cfg.MaxInputChars
+		}
+		mod = withContextWindow(os.Stderr, mod, cfg)
+
+		if cfg.ThinkingBudget > 0 {
+			mod.ThinkingBudget = cfg.ThinkingBudget
+		}
+		if cfg.Grounding {
+			mod.Grounding = true
+		}
+		if err := validateTruncationStrategy(cfg.TruncationStrategy); err != nil {
+			return err
+		}
+		if err := m.validateThinkingBudget(mod); err != nil {
+			return err
+		}
+		if mod.Grounding && mod.API != "google" && mod.API != "vertexai" {
+			return modsError{
+				err: newUserErrorf(
+					"Grounding with Google Search is not supported by the %s API.",
+					mod.API,
+				),
+				reason: fmt.Sprintf(
+					"Model %s does not support %s.",
+					m.Styles.InlineCode.Render(mod.Name),
+					m.Styles.InlineCode.Render("--grounding"),
+				),
+			}
+		}
+		m.model = mod
+
+		if cfg.DryRun || cfg.EstimateTokens {
+			if err := m.setupStreamContext(content, mod); err != nil {
+				return err
+			}
+			return dryRunMsg{}
+		}
+
+		if useResponseCache(cfg) {
+			if err := m.setupStreamContext(content, mod); err != nil {
+				return err
+			}
+			m.responseKey = responseKey(mod, cfg, m.messages)
+			if out, ok := newResponseCache(cfg.CachePath).get(m.responseKey); ok {
+				m.responseKey = ""
+				m.responseHit = true
+				return completionOutput{stream: &singleCompletionStream{content: out}}
+			}
+			// the messages are ready, don't build them, and maybe pay for a
+			// summary, again.
+			m.prepared = true
+		}
+
+		if err := breaker.allow(mod.API, circuitBreakerTimeout(cfg)); err != nil {
+			return modsError{err, fmt.Sprintf("The %s API keeps failing.", mod.API)}
+		}
+
 		switch mod.API {
 		case "ollama":
 			occfg = DefaultOllamaConfig()
 			if api.BaseURL != "" {
 				occfg.BaseURL = api.BaseURL
 			}
+			if hasAPIKey(api) {
+				key, err := m.ensureKey(api, "OLLAMA_API_KEY", "https://github.com/ollama/ollama/blob/main/docs/faq.md")
+				if err != nil {
+					return modsError{err, "Ollama authentication failed"}
+				}
+				occfg.AuthToken = key
+			}
 		case "anthropic":
 			key, err := m.ensureKey(api, "ANTHROPIC_API_KEY", "https://console.anthropic.com/settings/keys")
 			if err != nil {
@@ -335,6 +591,49 @@ func (m *Mods) startCompletionCmd(content string) tea.Cmd {
 				return modsError{err, "Google authentication failed"}
 			}
 			gccfg = DefaultGoogleConfig(mod.Name, key)
+		case "vertexai":
+			if hasAPIKey(api) {
+				key, err := m.ensureKey(api, "GOOGLE_API_KEY", "https://cloud.google.com/vertex-ai/generative-ai/docs/start/express-mode/overview")
+				if err != nil {
+					return modsError{err, "Vertex AI authentication failed"}
+				}
+				gccfg = DefaultVertexAIKeyConfig(api.BaseURL, mod.Name, key)
+				break
+			}
+			project := vertexAIProject(api)
+			if project == "" {
+				return modsError{
+					err: newUserErrorf(
+						"Set %s in the vertexai API settings, or %s.",
+						m.Styles.InlineCode.Render("project"),
+						m.Styles.InlineCode.Render("GOOGLE_CLOUD_PROJECT"),
+					),
+					reason: "Vertex AI needs a Google Cloud project.",
+				}
+			}
+			token, err := vertexAIToken()
+			if err != nil {
+				return modsError{err, fmt.Sprintf(
+					"Vertex AI authentication failed; run %s or set an %s.",
+					m.Styles.InlineCode.Render("gcloud auth application-default login"),
+					m.Styles.InlineCode.Render("api-key"),
+				)}
+			}
+			gccfg = DefaultVertexAIConfig(api.BaseURL, project, api.Location, mod.Name, token)
+		case "bedrock":
+			creds, err := bedrockCredentials(api)
+			if err != nil {
+				return modsError{err, fmt.Sprintf(
+					"Bedrock authentication failed; set the %s environment variables, a %s or an %s.",
+					m.Styles.InlineCode.Render("AWS_*"),
+					m.Styles.InlineCode.Render("profile"),
+					m.Styles.InlineCode.Render("api-key-cmd"),
+				)}
+			}
+			bccfg = DefaultBedrockConfig(bedrockRegion(api), creds)
+			if api.BaseURL != "" {
+				bccfg.BaseURL = api.BaseURL
+			}
 		case "cohere":
 			key, err := m.ensureKey(api, "COHERE_API_KEY", "https://dashboard.cohere.com/api-keys")
 			if err != nil {
@@ -342,7 +641,7 @@ func (m *Mods) startCompletionCmd(content string) tea.Cmd {
 			}
 			cccfg = DefaultCohereConfig(key)
 			if api.BaseURL != "" {
-				ccfg.BaseURL = api.BaseURL
+				cccfg.BaseURL = api.BaseURL
 			}
 		case "azure", "azure-ad":
 			key, err := m.ensureKey(api, "AZURE_OPENAI_KEY", "https://aka.ms/oai/access")
@@ -356,13 +655,82 @@ func (m *Mods) startCompletionCmd(content string) tea.Cmd {
 			if api.User != "" {
 				cfg.User = api.User
 			}
+		case "groq":
+			key, err := m.ensureKey(api, "GROQ_API_KEY", "https://console.groq.com/keys")
+			if err != nil {
+				return modsError{err, "Groq authentication failed"}
+			}
+			ccfg = openai.DefaultConfig(key)
+			ccfg.BaseURL = groqBaseURL
+			if api.BaseURL != "" {
+				ccfg.BaseURL = api.BaseURL
+			}
+		case "xai":
+			key, err := m.ensureKey(api, "XAI_API_KEY", "https://console.x.ai")
+			if err != nil {
+				return modsError{err, "xAI authentication failed"}
+			}
+			ccfg = openai.DefaultConfig(key)
+			ccfg.BaseURL = xaiBaseURL
+			if api.BaseURL != "" {
+				ccfg.BaseURL = api.BaseURL
+			}
+		case "together":
+			key, err := m.ensureKey(api, "TOGETHER_API_KEY", "https://api.together.xyz/settings/api-keys")
+			if err != nil {
+				return modsError{err, "Together authentication failed"}
+			}
+			ccfg = openai.DefaultConfig(key)
+			ccfg.BaseURL = togetherBaseURL
+			if api.BaseURL != "" {
+				ccfg.BaseURL = api.BaseURL
+			}
+		case "deepseek":
+			key, err := m.ensureKey(api, "DEEPSEEK_API_KEY", "https://platform.deepseek.com/api_keys")
+			if err != nil {
+				return modsError{err, "DeepSeek authentication failed"}
+			}
+			ccfg = openai.DefaultConfig(key)
+			ccfg.BaseURL = deepseekBaseURL
+			if api.BaseURL != "" {
+				ccfg.BaseURL = api.BaseURL
+			}
+		case "openrouter":
+			key, err := m.ensureKey(api, "OPENROUTER_API_KEY", "https://openrouter.ai/settings/keys")
+			if err != nil {
+				return modsError{err, "OpenRouter authentication failed"}
+			}
+			ccfg = openai.DefaultConfig(key)
+			ccfg.BaseURL = openRouterBaseURL
+			if api.BaseURL != "" {
+				ccfg.BaseURL = api.BaseURL
+			}
+		case "mistral":
+			key, err := m.ensureKey(api, "MISTRAL_API_KEY", "https://console.mistral.ai/api-keys")
+			if err != nil {
+				return modsError{err, "Mistral authentication failed"}
+			}
+			ccfg = openai.DefaultConfig(key)
+			ccfg.BaseURL = mistralBaseURL
+			if api.BaseURL != "" {
+				ccfg.BaseURL = api.BaseURL
+			}
 		case "copilot":
-			token, err := getCopilotAuthToken()
+			var token string
+			host, err := copilotHostFor(api)
+			if err != nil {
+				return modsError{err, "Invalid GitHub Enterprise URL"}
+			}
+			if hasAPIKey(api) {
+				token, err = m.ensureKey(api, "GITHUB_TOKEN", "https://github.com/settings/copilot")
+			} else {
+				token, err = getCopilotAuthToken(host)
+			}
 			if err != nil {
 				return modsError{err, "Copilot authentication failed"}
 			}
 			ccfg = openai.DefaultConfig(token)
-			ccfg.BaseURL = api.BaseURL
+			ccfg.BaseURL, _ = copilotEndpoint(api)
 		default:
 			key, err := m.ensureKey(api, "OPENAI_API_KEY", "https://platform.openai.com/account/api-keys")
 			if err != nil {
@@ -375,36 +743,95 @@ func (m *Mods) startCompletionCmd(content string) tea.Cmd {
 		}
 
 		if cfg.HTTPProxy != "" {
-			proxyURL, err := url.Parse(cfg.HTTPProxy)
+			httpClient, err := newProxyClient(cfg.HTTPProxy)
 			if err != nil {
 				return modsError{err, "There was an error parsing your proxy URL."}
 			}
-			httpClient := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
 			ccfg.HTTPClient = httpClient
 			accfg.HTTPClient = httpClient
 			cccfg.HTTPClient = httpClient
 			occfg.HTTPClient = httpClient
+			bccfg.HTTPClient = httpClient
 		}
 
-		if mod.MaxChars == 0 {
-			mod.MaxChars = cfg.MaxInputChars
+		timeout := cfg.Timeout
+		if api.Timeout > 0 {
+			timeout = api.Timeout
+		}
+		if timeout > 0 {
+			ccfg.HTTPClient = withTimeout(ccfg.HTTPClient, timeout)
+			accfg.HTTPClient = withTimeout(accfg.HTTPClient, timeout)
+			cccfg.HTTPClient = withTimeout(cccfg.HTTPClient, timeout)
+			occfg.HTTPClient = withTimeout(occfg.HTTPClient, timeout)
+			gccfg.HTTPClient = withTimeout(gccfg.HTTPClient, timeout)
+			bccfg.HTTPClient = withTimeout(bccfg.HTTPClient, timeout)
+		}
+
+		if cfg.Debug {
+			path := debugLogPath(cfg.CachePath)
+			ccfg.HTTPClient = withDebugLog(ccfg.HTTPClient, path)
+			accfg.HTTPClient = withDebugLog(accfg.HTTPClient, path)
+			cccfg.HTTPClient = withDebugLog(cccfg.HTTPClient, path)
+			occfg.HTTPClient = withDebugLog(occfg.HTTPClient, path)
+			gccfg.HTTPClient = withDebugLog(gccfg.HTTPClient, path)
+			bccfg.HTTPClient = withDebugLog(bccfg.HTTPClient, path)
+		}
+
+		ccfg.HTTPClient = withRetryAfter(ccfg.HTTPClient, &m.retryAfter)
+		accfg.HTTPClient = withRetryAfter(accfg.HTTPClient, &m.retryAfter)
+		cccfg.HTTPClient = withRetryAfter(cccfg.HTTPClient, &m.retryAfter)
+		occfg.HTTPClient = withRetryAfter(occfg.HTTPClient, &m.retryAfter)
+		gccfg.HTTPClient = withRetryAfter(gccfg.HTTPClient, &m.retryAfter)
+		bccfg.HTTPClient = withRetryAfter(bccfg.HTTPClient, &m.retryAfter)
+
+		switch mod.API {
+		case "mistral":
+			if fields := mistralBodyFields(api); len(fields) > 0 {
+				ccfg.HTTPClient = withExtraBody(ccfg.HTTPClient, fields)
+			}
+		case "google", "vertexai":
+			gccfg.SafetySettings = googleSafetySettings(api.SafetySettings)
+		case "openrouter":
+			ccfg.HTTPClient = withOpenRouterHeaders(ccfg.HTTPClient, api)
+		case "together":
+			ccfg.HTTPClient = withTogetherErrors(ccfg.HTTPClient)
+			if fields := togetherBodyFields(cfg); len(fields) > 0 {
+				ccfg.HTTPClient = withExtraBody(ccfg.HTTPClient, fields)
+			}
 		}
 
+		m.startStreamSpan(mod, content)
+		m.log().Info("request started", "attempt", m.retries+1, "input_chars", len(content))
+		var msg tea.Msg
 		switch mod.API {
 		case "anthropic":
-			return m.createAnthropicStream(content, accfg, mod)
-		case "google":
-			return m.createGoogleStream(content, gccfg, mod)
+			msg = m.createAnthropicStream(content, accfg, mod)
+		case "google", "vertexai":
+			msg = m.createGoogleStream(content, gccfg, mod)
+		case "bedrock":
+			msg = m.createBedrockStream(content, bccfg, mod)
 		case "cohere":
-			return m.createCohereStream(content, cccfg, mod)
+			msg = m.createCohereStream(content, cccfg, mod)
 		case "ollama":
-			return m.createOllamaStream(content, occfg, mod)
+			msg = m.createOllamaStream(content, occfg, mod)
 		default:
-			return m.createOpenAIStream(content, ccfg, mod)
+			msg = m.createOpenAIStream(content, ccfg, mod)
 		}
+		if out, ok := msg.(modsError); ok {
+			m.endStreamSpan(out.err)
+		}
+		return msg
 	}
 }
 
+// ensureKey returns the API key of api, from the first of api-key,
+// api-key-env, api-key-cmd, op-item, api-key-secret-arn and the defaultEnv
+// environment variable that is set.
+//
+// Secrets stored in 1Password can be read with an api-key-cmd like
+// `op read "op://vault/item/field"`, or with op-item, which runs the
+// 1Password CLI with the item given as is, in the vault set in
+// MODS_OP_VAULT.
 func (m Mods) ensureKey(api API, defaultEnv, docsURL string) (string, error) {
 	key := api.APIKey
 	if key == "" && api.APIKeyEnv != "" && api.APIKeyCmd == "" {
@@ -421,6 +848,20 @@ func (m Mods) ensureKey(api API, defaultEnv, docsURL string) (string, error) {
 		}
 		key = strings.TrimSpace(string(out))
 	}
+	if key == "" && api.OPItem != "" {
+		var err error
+		key, err = opRead(api.OPItem)
+		if err != nil {
+			return "", modsError{err, "Cannot read op-item from 1Password"}
+		}
+	}
+	if key == "" && api.APIKeySecretARN != "" {
+		var err error
+		key, err = secretsManagerKey(api)
+		if err != nil {
+			return "", modsError{err, "Cannot read api-key-secret-arn from AWS Secrets Manager"}
+		}
+	}
 	if key == "" {
 		key = os.Getenv(defaultEnv)
 	}
@@ -442,10 +883,24 @@ func (m Mods) ensureKey(api API, defaultEnv, docsURL string) (string, error) {
 }
 
 func (m *Mods) handleRequestError(err error, mod Model, content string) tea.Msg {
+	errorsTotal.WithLabelValues(errorCode(err)).Inc()
+	m.endStreamSpan(err)
+	m.log().Error("request failed", "error", err.Error(), "status", errorCode(err))
+	if isAPIFailure(err) {
+		breaker.failure(mod.API, m.Config.MaxRetries)
+	}
 	ae := &openai.APIError{}
 	if errors.As(err, &ae) {
 		return m.handleAPIError(ae, mod, content)
 	}
+	var nerr net.Error
+	if errors.As(err, &nerr) && nerr.Timeout() {
+		return modsError{err, fmt.Sprintf(
+			"The %s API request timed out, use %s to wait longer.",
+			mod.API,
+			m.Styles.InlineCode.Render("--timeout"),
+		)}
+	}
 	return modsError{err, fmt.Sprintf(
 		"There was a problem with the %s API request.",
 		mod.API,
@@ -475,7 +930,7 @@ func (m *Mods) handleAPIError(err *openai.APIError, mod Model, content string) t
 				return pe
 			}
 
-			return m.retry(cutPrompt(err.Message, content), pe)
+			return m.retry(m.truncate(content, promptLimit(err.Message, content)), pe)
 		}
 		// bad request (do not retry)
 		return modsError{err: err, reason: fmt.Sprintf("%s API request error.", mod.API)}
@@ -501,23 +956,127 @@ func (m *Mods) handleAPIError(err *openai.APIError, mod Model, content string) t
 	}
 }
 
+// withContextWindow overrides the input limit of the model with
+// --context-window, warning when it goes over the configured one.
+func withContextWindow(w io.Writer, mod Model, cfg *Config) Model {
+	if cfg.ContextWindow <= 0 {
+		return mod
+	}
+	if mod.MaxChars > 0 && cfg.ContextWindow > mod.MaxChars && !cfg.Quiet {
+		fmt.Fprintf(
+			w,
+			"Warning: --context-window %d is over the max-input-chars of %s (%d).\n",
+			cfg.ContextWindow,
+			mod.Name,
+			mod.MaxChars,
+		)
+	}
+	mod.MaxChars = cfg.ContextWindow
+	return mod
+}
+
+// validateThinkingBudget makes sure extended thinking is only asked of
+// Anthropic models, and within the input limit of the model.
+func (m *Mods) validateThinkingBudget(mod Model) error {
+	if mod.ThinkingBudget <= 0 {
+		return nil
+	}
+	if mod.API != "anthropic" {
+		return modsError{
+			err: newUserErrorf(
+				"Extended thinking is not supported by the %s API yet.",
+				mod.API,
+			),
+			reason: fmt.Sprintf(
+				"Model %s does not support %s.",
+				m.Styles.InlineCode.Render(mod.Name),
+				m.Styles.InlineCode.Render("--thinking-budget"),
+			),
+		}
+	}
+	if mod.MaxChars > 0 && mod.ThinkingBudget > mod.MaxChars {
+		return modsError{
+			err: newUserErrorf(
+				"Use a thinking budget of at most %d, the %s of the model.",
+				mod.MaxChars,
+				m.Styles.InlineCode.Render("max-input-chars"),
+			),
+			reason: fmt.Sprintf(
+				"Thinking budget %d is too large.",
+				mod.ThinkingBudget,
+			),
+		}
+	}
+	return nil
+}
+
 func (m *Mods) receiveCompletionStreamCmd(msg completionOutput) tea.Cmd {
 	return func() tea.Msg {
 		resp, err := msg.stream.Recv()
 		if errors.Is(err, io.EOF) {
+			m.finishedAt = time.Now()
+			m.endStreamSpan(nil)
 			_ = msg.stream.Close()
+			if !m.responseHit {
+				// only a stream that made it to the end shows the API is fine.
+				breaker.success(m.model.API)
+			}
+			answer := m.Output
+			if len(m.completions) > 1 {
+				// the first one is saved unless another is picked.
+				answer = m.completions[0]
+			}
+			if m.schema != nil {
+				if err := m.schema.validate(answer); err != nil {
+					return modsError{err, "The response does not follow the schema."}
+				}
+			}
 			m.messages = append(m.messages, openai.ChatCompletionMessage{
 				Role:    openai.ChatMessageRoleAssistant,
-				Content: m.Output,
+				Content: answer,
 			})
+			m.addCost()
+			if !m.responseHit {
+				m.recordRequest()
+			}
+			input, output := m.usage()
+			m.log().Info(
+				"request completed",
+				"input_tokens", input,
+				"output_tokens", output,
+				"duration_ms", m.finishedAt.Sub(m.startedAt).Milliseconds(),
+				"cached", m.responseHit,
+			)
+			if m.responseKey != "" {
+				// not being able to cache should not lose the response.
+				_ = newResponseCache(m.Config.CachePath).set(m.responseKey, m.Output[m.echoLen:], responseTTL(m.Config))
+			}
 			return completionOutput{}
 		}
 		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				breaker.failure(m.model.API, m.Config.MaxRetries)
+			}
+			m.endStreamSpan(err)
+			m.log().Error("streaming failed", "error", err.Error())
 			_ = msg.stream.Close()
 			return modsError{err, "There was an error when streaming the API response."}
 		}
+		if resp.Usage != nil && resp.Usage.TotalTokens > 0 {
+			m.tokensUsed = resp.Usage.TotalTokens
+			m.promptTokens = resp.Usage.PromptTokens
+			m.completionTokens = resp.Usage.CompletionTokens
+		}
+		if resp.Usage != nil && resp.Usage.PromptTokensDetails != nil {
+			m.cacheHit = resp.Usage.PromptTokensDetails.CachedTokens > 0
+		}
+		// chunks without choices, like the usage one, carry no content.
+		msg.content = ""
 		if len(resp.Choices) > 0 {
 			msg.content = resp.Choices[0].Delta.Content
+			if lp := resp.Choices[0].Logprobs; lp != nil {
+				m.logprobs = append(m.logprobs, lp.Content...)
+			}
 		}
 		return msg
 	}
@@ -529,11 +1088,14 @@ type cacheDetailsMsg struct {
 
 func (m *Mods) findCacheOpsDetails() tea.Cmd {
 	return func() tea.Msg {
+		if id := m.Config.ConversationID; id != "" {
+			return m.conversationIDDetails(id)
+		}
 		continueLast := m.Config.ContinueLast || (m.Config.Continue != "" && m.Config.Title == "")
 		readID := ordered.First(m.Config.Continue, m.Config.Show)
 		writeID := ordered.First(m.Config.Title, m.Config.Continue)
 		title := writeID
-		model := config.Model
+		model := m.Config.Model
 
 		if readID != "" || continueLast || m.Config.ShowLast {
 			found, err := m.findReadID(readID)
@@ -579,6 +1141,41 @@ func (m *Mods) findCacheOpsDetails() tea.Cmd {
 	}
 }
 
+// keepCompletion makes the completion with the given index the answer that
+// is saved.
+func (m *Mods) keepCompletion(i int) {
+	n := len(m.messages)
+	if i < 0 || i >= len(m.completions) || n == 0 || m.messages[n-1].Role != openai.ChatMessageRoleAssistant {
+		return
+	}
+	m.messages[n-1].Content = m.completions[i]
+}
+
+// conversationIDDetails continues the conversation with the given ID if
+// there is one, or else starts it.
+func (m *Mods) conversationIDDetails(id string) tea.Msg {
+	dets := cacheDetailsMsg{
+		WriteID: id,
+		Title:   id,
+		Model:   m.Config.Model,
+	}
+	convo, err := m.db.Find(id)
+	if errors.Is(err, errNoMatches) {
+		return dets
+	}
+	if err != nil {
+		return modsError{
+			err:    err,
+			reason: "Could not find the conversation.",
+		}
+	}
+	dets.ReadID = convo.ID
+	if convo.Model != nil {
+		dets.Model = *convo.Model
+	}
+	return dets
+}
+
 func (m *Mods) findReadID(in string) (*Conversation, error) {
 	convo, err := m.db.Find(in)
 	if err == nil {
@@ -595,16 +1192,62 @@ func (m *Mods) findReadID(in string) (*Conversation, error) {
 }
 
 func (m *Mods) readStdinCmd() tea.Msg {
+	var input string
 	if !isInputTTY() {
 		reader := bufio.NewReader(os.Stdin)
 		stdinBytes, err := io.ReadAll(reader)
 		if err != nil {
 			return modsError{err, "Unable to read stdin."}
 		}
+		input = string(stdinBytes)
+	}
+	m.stdin = input
+	return m.gatherInput(input)
+}
 
-		return completionInput{increaseIndent(string(stdinBytes))}
+// gatherInput adds the output of --exec, --url and --file to the input read
+// from STDIN.
+func (m *Mods) gatherInput(input string) tea.Msg {
+	if m.Config.ExecCmd != "" {
+		if strings.TrimSpace(input) != "" {
+			return modsError{
+				err: newUserErrorf(
+					"Either pipe the input into mods or use %s.",
+					m.Styles.InlineCode.Render("--exec"),
+				),
+				reason: fmt.Sprintf(
+					"Can't use %s together with STDIN.",
+					m.Styles.InlineCode.Render("--exec"),
+				),
+			}
+		}
+		out, err := runExecCmd(m.Config.ExecCmd, m.Config.ExecTimeout)
+		if err != nil {
+			return modsError{err, "Could not run command."}
+		}
+		input = out
+	}
+
+	if len(m.Config.URLs) > 0 {
+		text, err := fetchURLs(m.Config.URLs, m.Config.HTTPProxy)
+		if err != nil {
+			return modsError{err, "Could not fetch URL."}
+		}
+		input = strings.TrimSpace(text + "\n\n" + input)
 	}
-	return completionInput{""}
+
+	if len(m.Config.Files) > 0 {
+		text, err := readFiles(m.Config.Files, m.Config.Force)
+		if err != nil {
+			return modsError{err, "Could not read file."}
+		}
+		input = strings.TrimSpace(text + "\n\n" + input)
+	}
+
+	if input == "" {
+		return completionInput{""}
+	}
+	return completionInput{increaseIndent(input)}
 }
 
 // noOmitFloat converts a 0.0 value to a float usable by the OpenAI client
@@ -699,27 +1342,72 @@ func responseType(cfg *Config) openai.ChatCompletionResponseFormatType {
 
 var tokenErrRe = regexp.MustCompile(`This model's maximum context length is (\d+) tokens. However, your messages resulted in (\d+) tokens`)
 
-func cutPrompt(msg, prompt string) string {
+// promptLimit returns how long prompt can be, given the context length error
+// msg from the API.
+func promptLimit(msg, prompt string) int {
 	found := tokenErrRe.FindStringSubmatch(msg)
 	if len(found) != 3 { //nolint:mnd
-		return prompt
+		return len(prompt)
 	}
 
 	maxt, _ := strconv.Atoi(found[1])
 	current, _ := strconv.Atoi(found[2])
 
 	if maxt > current {
-		return prompt
+		return len(prompt)
 	}
 
 	// 1 token =~ 4 chars
 	// cut 10 extra chars 'just in case'
 	reduceBy := 10 + (current-maxt)*4 //nolint:mnd
 	if len(prompt) > reduceBy {
-		return prompt[:len(prompt)-reduceBy]
+		return len(prompt) - reduceBy
+	}
+
+	return len(prompt)
+}
+
+// parseRange parses a range of lines like 3:7, :10 or 5:, counted from 1
+// and inclusive, and returns the bounds to slice n lines with. Negative
+// numbers count from the end, -1 being the last line.
+func parseRange(s string, n int) (int, int, error) {
+	from, to, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, 0, newUserErrorf("Use a range like %s, %s or %s.", "3:7", ":10", "5:")
+	}
+	line := func(v string) (int, error) {
+		i, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || i == 0 {
+			return 0, newUserErrorf("Invalid line %q: lines are counted from 1, or from -1 at the end.", v)
+		}
+		return i, nil
+	}
+
+	start, end := 0, n
+	if strings.TrimSpace(from) != "" {
+		i, err := line(from)
+		if err != nil {
+			return 0, 0, err
+		}
+		start = i - 1
+		if i < 0 {
+			start = n + i
+		}
+	}
+	if strings.TrimSpace(to) != "" {
+		i, err := line(to)
+		if err != nil {
+			return 0, 0, err
+		}
+		end = i
+		if i < 0 {
+			end = n + i + 1
+		}
 	}
 
-	return prompt
+	start = max(0, min(start, n))
+	end = max(start, min(end, n))
+	return start, end, nil
 }
 
 func increaseIndent(s string) string {
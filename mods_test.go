@@ -1,9 +1,21 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 	"github.com/sashabaranov/go-openai"
 	"github.com/stretchr/testify/require"
 )
@@ -149,6 +161,31 @@ func TestFindCacheOpsDetails(t *testing.T) {
 		require.Equal(t, "some title", dets.Title)
 	})
 
+	t.Run("conversation id exists", func(t *testing.T) {
+		mods := newMods(t)
+		id := newConversationID()
+		require.NoError(t, mods.db.Save(id, "message 1", "llama3"))
+		mods.Config.ConversationID = id
+		mods.Config.Model = "gpt-4"
+		mods.Config.Prefix = "prompt"
+		msg := mods.findCacheOpsDetails()()
+		dets := msg.(cacheDetailsMsg)
+		require.Equal(t, id, dets.ReadID)
+		require.Equal(t, id, dets.WriteID)
+		require.Equal(t, "llama3", dets.Model)
+	})
+
+	t.Run("conversation id is new", func(t *testing.T) {
+		mods := newMods(t)
+		require.NoError(t, mods.db.Save(newConversationID(), "message 1", "gpt-4"))
+		id := newConversationID()
+		mods.Config.ConversationID = id
+		msg := mods.findCacheOpsDetails()()
+		dets := msg.(cacheDetailsMsg)
+		require.Empty(t, dets.ReadID)
+		require.Equal(t, id, dets.WriteID)
+	})
+
 	t.Run("show invalid", func(t *testing.T) {
 		mods := newMods(t)
 		mods.Config.Show = "aaa"
@@ -159,6 +196,191 @@ func TestFindCacheOpsDetails(t *testing.T) {
 	})
 }
 
+func TestSingleCompletionStream(t *testing.T) {
+	mods := &Mods{
+		Config:       &Config{NoStream: true},
+		contentMutex: &sync.Mutex{},
+	}
+
+	msg := mods.receiveCompletionStreamCmd(completionOutput{
+		stream: &singleCompletionStream{
+			content: "the whole answer",
+			usage:   &openai.Usage{TotalTokens: 12},
+		},
+	})()
+	out := msg.(completionOutput)
+	require.Equal(t, "the whole answer", out.content)
+	require.Equal(t, 12, mods.tokensUsed)
+	mods.appendToOutput(out.content)
+
+	msg = mods.receiveCompletionStreamCmd(out)()
+	require.Nil(t, msg.(completionOutput).stream)
+	require.Equal(t, []openai.ChatCompletionMessage{
+		{
+			Role:    openai.ChatMessageRoleAssistant,
+			Content: "the whole answer",
+		},
+	}, mods.messages)
+}
+
+func TestStreamUsage(t *testing.T) {
+	var includeUsage atomic.Bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		includeUsage.Store(req.StreamOptions != nil && req.StreamOptions.IncludeUsage)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range []string{
+			`{"choices":[{"index":0,"delta":{"role":"assistant","content":"Hello"}}]}`,
+			`{"choices":[{"index":0,"delta":{"content":"!"},"finish_reason":"stop"}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":5,"completion_tokens":2,"total_tokens":7}}`,
+			`[DONE]`,
+		} {
+			_, _ = fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	cfg := &Config{
+		Model:  "gpt-4o",
+		Prefix: "hi",
+		Quiet:  true,
+		Models: map[string]Model{
+			"gpt-4o": {Name: "gpt-4o", API: "openai", MaxChars: 1000},
+		},
+		APIs: APIs{
+			{Name: "openai", BaseURL: ts.URL, APIKey: "fake"},
+		},
+	}
+	mods := newMods(lipgloss.DefaultRenderer(), cfg, nil, nil)
+	p := tea.NewProgram(
+		mods,
+		tea.WithInput(nil),
+		tea.WithOutput(io.Discard),
+		tea.WithoutRenderer(),
+	)
+	_, err := p.Run()
+	require.NoError(t, err)
+	require.Nil(t, mods.Error)
+	require.True(t, includeUsage.Load())
+	require.Equal(t, "Hello!", mods.Output)
+	require.Equal(t, 7, mods.tokensUsed)
+}
+
+func TestNoStreamSavesConversation(t *testing.T) {
+	var streamed atomic.Bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		streamed.Store(req.Stream)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{
+				{
+					Message: openai.ChatCompletionMessage{
+						Role:    openai.ChatMessageRoleAssistant,
+						Content: "the whole answer",
+					},
+				},
+			},
+		})
+	}))
+	t.Cleanup(ts.Close)
+
+	// saveConversation uses the global state.
+	oldConfig, oldDB, oldCache := config, db, cache
+	t.Cleanup(func() { config, db, cache = oldConfig, oldDB, oldCache })
+	config = Config{
+		Model:    "gpt-4o",
+		Prefix:   "the question",
+		Quiet:    true,
+		NoStream: true,
+		Models: map[string]Model{
+			"gpt-4o": {Name: "gpt-4o", API: "openai", MaxChars: 1000},
+		},
+		APIs: APIs{
+			{Name: "openai", BaseURL: ts.URL, APIKey: "fake"},
+		},
+	}
+	db = testDB(t)
+	cache = newCache(t.TempDir())
+
+	mods := newMods(lipgloss.DefaultRenderer(), &config, db, cache)
+	p := tea.NewProgram(
+		mods,
+		tea.WithInput(nil),
+		tea.WithOutput(io.Discard),
+		tea.WithoutRenderer(),
+	)
+	_, err := p.Run()
+	require.NoError(t, err)
+	require.Nil(t, mods.Error)
+	require.False(t, streamed.Load())
+	require.Equal(t, "the whole answer", mods.Output)
+
+	require.NoError(t, saveConversation(mods))
+	convo, err := db.Find(config.cacheWriteToID)
+	require.NoError(t, err)
+	require.Equal(t, "openai", *convo.API)
+
+	var messages []openai.ChatCompletionMessage
+	require.NoError(t, cache.read(config.cacheWriteToID, &messages))
+	require.Equal(t, []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleUser, Content: "the question"},
+		{Role: openai.ChatMessageRoleAssistant, Content: "the whole answer"},
+	}, messages)
+}
+
+func TestNoStreamUnsupported(t *testing.T) {
+	for _, api := range []string{"google", "bedrock"} {
+		t.Run(api, func(t *testing.T) {
+			cfg := &Config{
+				Model:    "some-model",
+				NoStream: true,
+				Models: map[string]Model{
+					"some-model": {Name: "some-model", API: api},
+				},
+				APIs: APIs{{Name: api}},
+			}
+			mods := newMods(lipgloss.DefaultRenderer(), cfg, nil, nil)
+			msg := mods.startCompletionCmd("hi")()
+			var merr modsError
+			require.ErrorAs(t, msg.(error), &merr)
+			require.Contains(t, merr.reason, "--no-stream")
+		})
+	}
+}
+
+func TestSetupStreamContextSystem(t *testing.T) {
+	newMods := func(cfg *Config) *Mods {
+		cfg.NoLimit = true
+		return &Mods{Config: cfg}
+	}
+
+	t.Run("system only", func(t *testing.T) {
+		mods := newMods(&Config{System: "respond only in spanish"})
+		require.NoError(t, mods.setupStreamContext("hello", Model{}))
+		require.Equal(t, []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: "respond only in spanish"},
+			{Role: openai.ChatMessageRoleUser, Content: "hello"},
+		}, mods.messages)
+	})
+
+	t.Run("system appends to role", func(t *testing.T) {
+		mods := newMods(&Config{
+			Role:   "shell",
+			Roles:  map[string][]string{"shell": {"you are a shell expert"}},
+			System: "respond only in spanish",
+		})
+		require.NoError(t, mods.setupStreamContext("hello", Model{}))
+		require.Equal(t, []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: "you are a shell expert"},
+			{Role: openai.ChatMessageRoleSystem, Content: "respond only in spanish"},
+			{Role: openai.ChatMessageRoleUser, Content: "hello"},
+		}, mods.messages)
+	})
+}
+
 func TestRemoveWhitespace(t *testing.T) {
 	t.Run("only whitespaces", func(t *testing.T) {
 		require.Equal(t, "", removeWhitespace(" \n"))
@@ -269,7 +491,491 @@ func tokenErrMsg(l, ml int) string {
 func TestCutPrompt(t *testing.T) {
 	for name, tc := range cutPromptTests {
 		t.Run(name, func(t *testing.T) {
-			require.Equal(t, tc.expected, cutPrompt(tc.msg, tc.prompt))
+			require.Equal(t, tc.expected, truncateInput(truncateTail, tc.prompt, promptLimit(tc.msg, tc.prompt)))
+		})
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(ts.Close)
+
+	cfg := &Config{
+		Model:  "gpt-4o",
+		Prefix: "hello",
+		System: "be brief",
+		Quiet:  true,
+		DryRun: true,
+		Models: map[string]Model{
+			"gpt-4o": {Name: "gpt-4o", API: "openai", MaxChars: 1000},
+		},
+		APIs: APIs{
+			{Name: "openai", BaseURL: ts.URL, APIKey: "fake"},
+		},
+		NoCache: true,
+	}
+	mods := newMods(lipgloss.DefaultRenderer(), cfg, testDB(t), newCache(t.TempDir()))
+	p := tea.NewProgram(
+		mods,
+		tea.WithInput(nil),
+		tea.WithOutput(io.Discard),
+		tea.WithoutRenderer(),
+	)
+	_, err := p.Run()
+	require.NoError(t, err)
+	require.Nil(t, mods.Error)
+	require.Zero(t, hits.Load())
+
+	var b bytes.Buffer
+	require.NoError(t, printDryRun(&b, mods))
+	var req dryRunRequest
+	require.NoError(t, json.Unmarshal(b.Bytes(), &req))
+	require.Equal(t, "openai", req.API)
+	require.Equal(t, "gpt-4o", req.Model)
+	require.Equal(t, []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: "be brief"},
+		{Role: openai.ChatMessageRoleUser, Content: "hello"},
+	}, req.Messages)
+}
+
+// testRequestBody runs mods with the given config against a fake OpenAI API
+// and returns the JSON body of the request it sent.
+func testRequestBody(t *testing.T, cfg *Config) map[string]any {
+	t.Helper()
+	var body map[string]any
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"ok\"}}]}\n\n")
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(ts.Close)
+
+	if cfg.Model == "" {
+		cfg.Model = "gpt-4o"
+	}
+	cfg.Prefix = "hi"
+	cfg.Quiet = true
+	cfg.Models = map[string]Model{
+		cfg.Model: {Name: cfg.Model, API: "openai", MaxChars: 1000},
+	}
+	cfg.APIs = APIs{
+		{Name: "openai", BaseURL: ts.URL, APIKey: "fake"},
+	}
+	mods := newMods(lipgloss.DefaultRenderer(), cfg, nil, nil)
+	p := tea.NewProgram(
+		mods,
+		tea.WithInput(nil),
+		tea.WithOutput(io.Discard),
+		tea.WithoutRenderer(),
+	)
+	_, err := p.Run()
+	require.NoError(t, err)
+	require.Nil(t, mods.Error)
+	return body
+}
+
+func TestMaxCompletionTokens(t *testing.T) {
+	t.Run("max tokens", func(t *testing.T) {
+		body := testRequestBody(t, &Config{MaxTokens: 100})
+		require.Equal(t, float64(100), body["max_tokens"])
+		require.NotContains(t, body, "max_completion_tokens")
+	})
+
+	t.Run("max completion tokens", func(t *testing.T) {
+		body := testRequestBody(t, &Config{MaxTokens: 100, MaxCompletionTokens: 200})
+		require.Equal(t, float64(200), body["max_completion_tokens"])
+		require.NotContains(t, body, "max_tokens")
+	})
+
+	t.Run("o-series", func(t *testing.T) {
+		// the o1 models only take the default temperature and top_p.
+		body := testRequestBody(t, &Config{Model: "o1-preview", MaxTokens: 100, Temperature: 1, TopP: 1})
+		require.Equal(t, float64(100), body["max_completion_tokens"])
+		require.NotContains(t, body, "max_tokens")
+	})
+}
+
+func TestPenalties(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		body := testRequestBody(t, &Config{PresencePenalty: -1, FrequencyPenalty: -1})
+		require.NotContains(t, body, "presence_penalty")
+		require.NotContains(t, body, "frequency_penalty")
+	})
+
+	t.Run("set", func(t *testing.T) {
+		body := testRequestBody(t, &Config{PresencePenalty: 0.5, FrequencyPenalty: 1.5})
+		require.InDelta(t, 0.5, body["presence_penalty"], 0.001)
+		require.InDelta(t, 1.5, body["frequency_penalty"], 0.001)
+	})
+}
+
+func TestSeed(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		body := testRequestBody(t, &Config{Seed: -1})
+		require.NotContains(t, body, "seed")
+	})
+
+	t.Run("set", func(t *testing.T) {
+		body := testRequestBody(t, &Config{Seed: 42})
+		require.Equal(t, float64(42), body["seed"])
+	})
+
+	t.Run("zero", func(t *testing.T) {
+		body := testRequestBody(t, &Config{Seed: 0})
+		require.Equal(t, float64(0), body["seed"])
+	})
+}
+
+func TestTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	for name, tc := range map[string]struct {
+		timeout    time.Duration
+		apiTimeout time.Duration
+	}{
+		"global":  {timeout: 50 * time.Millisecond},
+		"per api": {timeout: time.Minute, apiTimeout: 50 * time.Millisecond},
+	} {
+		t.Run(name, func(t *testing.T) {
+			testBreaker(t)
+			cfg := &Config{
+				Model:   "gpt-4o",
+				Prefix:  "hi",
+				Quiet:   true,
+				Timeout: tc.timeout,
+				Models: map[string]Model{
+					"gpt-4o": {Name: "gpt-4o", API: "openai", MaxChars: 1000},
+				},
+				APIs: APIs{
+					{Name: "openai", BaseURL: ts.URL, APIKey: "fake", Timeout: tc.apiTimeout},
+				},
+			}
+			mods := newMods(lipgloss.DefaultRenderer(), cfg, nil, nil)
+			start := time.Now()
+			msg := mods.startCompletionCmd("")()
+			require.Less(t, time.Since(start), 500*time.Millisecond)
+			merr, ok := msg.(modsError)
+			require.True(t, ok, "expected a modsError, got %T", msg)
+			require.Contains(t, merr.reason, "timed out")
+		})
+	}
+}
+
+func TestAPIKeyCmd(t *testing.T) {
+	var headers http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+		switch r.URL.Path {
+		case "/chat":
+			_, _ = fmt.Fprint(w, "{\"message\":{\"role\":\"assistant\",\"content\":\"hi\"}}\n{\"done\":true}\n")
+		case "/messages":
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = fmt.Fprint(w, "data: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"hi\"}}\n\n")
+		default:
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hi\"}}]}\n\n")
+			_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	for api, header := range map[string]string{
+		"ollama":    "Authorization",
+		"anthropic": "X-Api-Key",
+		"copilot":   "Authorization",
+		"openai":    "Authorization",
+	} {
+		t.Run(api, func(t *testing.T) {
+			headers = nil
+			mods := newMods(lipgloss.DefaultRenderer(), &Config{
+				Model:   "model",
+				Quiet:   true,
+				NoLimit: true,
+				Models:  map[string]Model{"model": {Name: "model", API: api}},
+				APIs: APIs{
+					{Name: api, BaseURL: ts.URL, APIKeyCmd: "echo 'secret from cmd'"},
+				},
+			}, nil, nil)
+			require.Nil(t, mods.runHeadless("hello"))
+			require.Equal(t, "hi", mods.Output)
+			require.Contains(t, headers.Get(header), "secret from cmd")
+		})
+	}
+}
+
+func TestContextWindow(t *testing.T) {
+	cfg := &Config{
+		Model:         "gpt-4o",
+		Prefix:        strings.Repeat("a", 100),
+		Quiet:         true,
+		DryRun:        true,
+		ContextWindow: 50,
+		Models: map[string]Model{
+			"gpt-4o": {Name: "gpt-4o", API: "openai", MaxChars: 20},
+		},
+		APIs: APIs{
+			{Name: "openai", APIKey: "fake"},
+		},
+		NoCache: true,
+	}
+	mods := newMods(lipgloss.DefaultRenderer(), cfg, nil, nil)
+	require.IsType(t, dryRunMsg{}, mods.startCompletionCmd("")())
+	require.Equal(t, 50, mods.model.MaxChars)
+	require.Len(t, lastPrompt(mods.messages), 50)
+
+	t.Run("warning", func(t *testing.T) {
+		var b strings.Builder
+		mod := withContextWindow(&b, Model{Name: "gpt-4o", MaxChars: 20}, &Config{ContextWindow: 50})
+		require.Equal(t, 50, mod.MaxChars)
+		require.Contains(t, b.String(), "over the max-input-chars of gpt-4o (20)")
+
+		b.Reset()
+		mod = withContextWindow(&b, Model{Name: "gpt-4o", MaxChars: 100}, &Config{ContextWindow: 50})
+		require.Equal(t, 50, mod.MaxChars)
+		require.Empty(t, b.String())
+	})
+
+	t.Run("unset", func(t *testing.T) {
+		mod := withContextWindow(io.Discard, Model{MaxChars: 20}, &Config{})
+		require.Equal(t, 20, mod.MaxChars)
+	})
+}
+
+func TestParseRange(t *testing.T) {
+	lines := []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}
+	for rng, expected := range map[string][]string{
+		"3:7":    {"3", "4", "5", "6", "7"},
+		":3":     {"1", "2", "3"},
+		"8:":     {"8", "9", "10"},
+		"-3:":    {"8", "9", "10"},
+		":-8":    {"1", "2", "3"},
+		"-4:-2":  {"7", "8", "9"},
+		"2:2":    {"2"},
+		":":      lines,
+		"5:100":  {"5", "6", "7", "8", "9", "10"},
+		"7:3":    {},
+		"-100:2": {"1", "2"},
+		" 2 : 3": {"2", "3"},
+	} {
+		t.Run(rng, func(t *testing.T) {
+			start, end, err := parseRange(rng, len(lines))
+			require.NoError(t, err)
+			require.Equal(t, expected, lines[start:end])
+		})
+	}
+
+	for _, rng := range []string{"3", "0:2", "a:b", "1:x"} {
+		t.Run(rng, func(t *testing.T) {
+			_, _, err := parseRange(rng, len(lines))
+			require.Error(t, err)
 		})
 	}
 }
+
+func TestPromptSeparator(t *testing.T) {
+	for name, tc := range map[string]struct {
+		config   Config
+		expected string
+	}{
+		"none": {
+			config:   Config{Separator: "\n\n"},
+			expected: "",
+		},
+		"prompt args": {
+			config:   Config{IncludePromptArgs: true, Separator: "\n\n"},
+			expected: "the question\n\n",
+		},
+		"prompt": {
+			config:   Config{IncludePrompt: 2, Separator: "\n\n"},
+			expected: "line 1\nline 2\n\n",
+		},
+		"prompt args and prompt": {
+			config:   Config{IncludePromptArgs: true, IncludePrompt: 1, Separator: "\n---\n"},
+			expected: "the question\n\nline 1\n---\n",
+		},
+		"prompt range": {
+			config:   Config{IncludePromptRange: "-1:", Separator: "\n### Response\n"},
+			expected: "line 3\n### Response\n",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := tc.config
+			cfg.Prefix = "the question"
+			cfg.Quiet = true
+			mods := newMods(lipgloss.DefaultRenderer(), &cfg, nil, nil)
+			mods.Update(completionInput{"line 1\nline 2\nline 3"})
+			require.Equal(t, tc.expected, mods.Output)
+		})
+	}
+}
+
+func TestOneShotFlagsQuit(t *testing.T) {
+	for name, cfg := range map[string]Config{
+		"pin":    {Pin: "abc"},
+		"unpin":  {Unpin: "abc"},
+		"backup": {BackupDB: "backup.db"},
+		"vacuum": {Vacuum: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			mods := newMods(lipgloss.DefaultRenderer(), &cfg, nil, nil)
+			_, cmd := mods.Update(completionInput{"piped input"})
+			require.NotNil(t, cmd)
+			require.IsType(t, tea.QuitMsg{}, cmd())
+		})
+	}
+}
+
+func TestCompletions(t *testing.T) {
+	run := func(t *testing.T, cfg *Config) *Mods {
+		t.Helper()
+		p := tea.NewProgram(
+			newMods(lipgloss.DefaultRenderer(), cfg, nil, nil),
+			tea.WithInput(nil),
+			tea.WithOutput(io.Discard),
+			tea.WithoutRenderer(),
+		)
+		m, err := p.Run()
+		require.NoError(t, err)
+		return m.(*Mods)
+	}
+
+	t.Run("openai", func(t *testing.T) {
+		var body map[string]any
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = fmt.Fprint(w, `{"choices":[
+				{"index":0,"message":{"role":"assistant","content":"first"}},
+				{"index":1,"message":{"role":"assistant","content":"second"}}
+			]}`)
+		}))
+		t.Cleanup(ts.Close)
+
+		mods := run(t, &Config{
+			Model:       "gpt-4o",
+			Prefix:      "hi",
+			Quiet:       true,
+			Completions: 2,
+			Models:      map[string]Model{"gpt-4o": {Name: "gpt-4o", API: "openai", MaxChars: 1000}},
+			APIs:        APIs{{Name: "openai", BaseURL: ts.URL, APIKey: "fake"}},
+		})
+		require.Nil(t, mods.Error)
+		require.Equal(t, float64(2), body["n"])
+		require.NotEqual(t, true, body["stream"])
+		require.Equal(t, "first\n---\nsecond", mods.Output)
+		require.Equal(t, []string{"first", "second"}, mods.completions)
+		require.Equal(t, "first", mods.messages[len(mods.messages)-1].Content)
+
+		mods.keepCompletion(1)
+		require.Equal(t, "second", mods.messages[len(mods.messages)-1].Content)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		mods := run(t, &Config{
+			Model:       "claude",
+			Prefix:      "hi",
+			Quiet:       true,
+			Completions: 2,
+			Models:      map[string]Model{"claude": {Name: "claude", API: "anthropic", MaxChars: 1000}},
+			APIs:        APIs{{Name: "anthropic", APIKey: "fake"}},
+		})
+		require.NotNil(t, mods.Error)
+		require.Contains(t, mods.Error.reason, "does not support")
+	})
+}
+
+func TestResponseSchema(t *testing.T) {
+	schema := filepath.Join("testdata", "schema.json")
+	run := func(t *testing.T, cfg *Config) *Mods {
+		t.Helper()
+		p := tea.NewProgram(
+			newMods(lipgloss.DefaultRenderer(), cfg, nil, nil),
+			tea.WithInput(nil),
+			tea.WithOutput(io.Discard),
+			tea.WithoutRenderer(),
+		)
+		m, err := p.Run()
+		require.NoError(t, err)
+		return m.(*Mods)
+	}
+	server := func(t *testing.T, content string, body *map[string]any) string {
+		t.Helper()
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(body))
+			w.Header().Set("Content-Type", "application/json")
+			bts, _ := json.Marshal(content)
+			_, _ = fmt.Fprintf(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":%s}}]}`, bts)
+		}))
+		t.Cleanup(ts.Close)
+		return ts.URL
+	}
+	config := func(url string) *Config {
+		return &Config{
+			Model:          "gpt-4o",
+			Prefix:         "hi",
+			Quiet:          true,
+			NoStream:       true,
+			ResponseSchema: schema,
+			Models:         map[string]Model{"gpt-4o": {Name: "gpt-4o", API: "openai", MaxChars: 1000}},
+			APIs:           APIs{{Name: "openai", BaseURL: url, APIKey: "fake"}},
+		}
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		var body map[string]any
+		mods := run(t, config(server(t, `{"name":"Carlos","age":42}`, &body)))
+		require.Nil(t, mods.Error)
+		format, ok := body["response_format"].(map[string]any)
+		require.True(t, ok)
+		require.Equal(t, "json_schema", format["type"])
+		jsonSchema, ok := format["json_schema"].(map[string]any)
+		require.True(t, ok)
+		require.Equal(t, "schema", jsonSchema["name"])
+		require.Equal(t, true, jsonSchema["strict"])
+		require.Contains(t, jsonSchema["schema"], "properties")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		var body map[string]any
+		mods := run(t, config(server(t, `{"name":"Carlos"}`, &body)))
+		require.NotNil(t, mods.Error)
+		require.ErrorIs(t, mods.Error.err, errSchemaMismatch)
+		require.Contains(t, mods.Error.err.Error(), `{"name":"Carlos"}`)
+	})
+
+	t.Run("not json", func(t *testing.T) {
+		var body map[string]any
+		mods := run(t, config(server(t, "Carlos, 42", &body)))
+		require.NotNil(t, mods.Error)
+		require.ErrorIs(t, mods.Error.err, errSchemaMismatch)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		mods := run(t, &Config{
+			Model:          "claude",
+			Prefix:         "hi",
+			Quiet:          true,
+			ResponseSchema: schema,
+			Models:         map[string]Model{"claude": {Name: "claude", API: "anthropic", MaxChars: 1000}},
+			APIs:           APIs{{Name: "anthropic", APIKey: "fake"}},
+		})
+		require.NotNil(t, mods.Error)
+		require.Contains(t, mods.Error.reason, "does not support")
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := loadResponseSchema(filepath.Join("testdata", "nope.json"))
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const modsrcName = ".modsrc"
+
+// modsrc are the settings a project can override with a .modsrc file. They
+// are limited to what the responses look like, so that a repository can't
+// change where the prompts are sent or which keys are used.
+type modsrc struct {
+	Model         string   `yaml:"default-model"`
+	API           string   `yaml:"default-api"`
+	Role          string   `yaml:"role"`
+	Temperature   *float32 `yaml:"temp"`
+	MaxInputChars int      `yaml:"max-input-chars"`
+	Format        *bool    `yaml:"format"`
+	FormatAs      string   `yaml:"format-as"`
+}
+
+// findModsrc returns the path of the closest .modsrc file in dir or its
+// parents, stopping at home. It returns an empty string if there is none.
+func findModsrc(dir, home string) string {
+	for {
+		path := filepath.Join(dir, modsrcName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if dir == home || parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// applyModsrc overrides the settings in c with the ones set in the .modsrc
+// file at path.
+func applyModsrc(c *Config, path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("applyModsrc: %w", err)
+	}
+	var rc modsrc
+	if err := decodeStrict(content, &rc); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("applyModsrc: %s: %w", path, err)
+	}
+	if rc.Model != "" {
+		c.Model = rc.Model
+	}
+	if rc.API != "" {
+		c.API = rc.API
+	}
+	if rc.Role != "" {
+		c.Role = rc.Role
+	}
+	if rc.Temperature != nil {
+		c.Temperature = *rc.Temperature
+	}
+	if rc.MaxInputChars > 0 {
+		c.MaxInputChars = rc.MaxInputChars
+	}
+	if rc.Format != nil {
+		c.Format = *rc.Format
+	}
+	if rc.FormatAs != "" {
+		c.FormatAs = rc.FormatAs
+	}
+	return nil
+}
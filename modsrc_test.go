@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestModsrc(t *testing.T) {
+	home := t.TempDir()
+	project := filepath.Join(home, "project")
+	sub := filepath.Join(project, "cmd", "app")
+	require.NoError(t, os.MkdirAll(sub, 0o700))
+
+	t.Run("not found", func(t *testing.T) {
+		require.Empty(t, findModsrc(sub, home))
+	})
+
+	path := filepath.Join(project, modsrcName)
+	require.NoError(t, os.WriteFile(path, []byte("default-model: claude\nrole: reviewer\ntemp: 0\nformat: false\n"), 0o600))
+
+	t.Run("found in parent", func(t *testing.T) {
+		require.Equal(t, path, findModsrc(sub, home))
+		require.Equal(t, path, findModsrc(project, home))
+	})
+
+	t.Run("stops at home", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(home), modsrcName), nil, 0o600))
+		t.Cleanup(func() { _ = os.Remove(filepath.Join(filepath.Dir(home), modsrcName)) })
+		require.Empty(t, findModsrc(home, home))
+	})
+
+	t.Run("overrides", func(t *testing.T) {
+		c := Config{
+			Model:         "gpt-4o",
+			Role:          "default",
+			Temperature:   1,
+			Format:        true,
+			FormatAs:      "markdown",
+			MaxInputChars: 12250,
+		}
+		require.NoError(t, applyModsrc(&c, path))
+		require.Equal(t, "claude", c.Model)
+		require.Equal(t, "reviewer", c.Role)
+		require.Equal(t, float32(0), c.Temperature)
+		require.False(t, c.Format)
+		// not set in .modsrc.
+		require.Equal(t, "markdown", c.FormatAs)
+		require.Equal(t, 12250, c.MaxInputChars)
+	})
+
+	t.Run("only safe fields", func(t *testing.T) {
+		bad := filepath.Join(t.TempDir(), modsrcName)
+		require.NoError(t, os.WriteFile(bad, []byte("apis:\n  openai:\n    base-url: https://evil.example.com\n"), 0o600))
+		c := Config{}
+		require.ErrorContains(t, applyModsrc(&c, bad), "field apis not found")
+		require.Empty(t, c.APIs)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		empty := filepath.Join(t.TempDir(), modsrcName)
+		require.NoError(t, os.WriteFile(empty, nil, 0o600))
+		c := Config{Model: "gpt-4o"}
+		require.NoError(t, applyModsrc(&c, empty))
+		require.Equal(t, "gpt-4o", c.Model)
+	})
+}
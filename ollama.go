@@ -8,12 +8,14 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 
 	openai "github.com/sashabaranov/go-openai"
 )
 
 // OllamaClientConfig represents the configuration for the Ollama API client.
 type OllamaClientConfig struct {
+	AuthToken          string
 	BaseURL            string
 	HTTPClient         *http.Client
 	EmptyMessagesLimit uint
@@ -48,12 +50,52 @@ type OllamaMessageCompletionRequestOptions struct {
 // OllamaMessageCompletionRequest represents the request body for the generate completion API.
 type OllamaMessageCompletionRequest struct {
 	Model     string                                `json:"model"`
-	Messages  []openai.ChatCompletionMessage        `json:"messages"`
+	Messages  []OllamaInputMessage                  `json:"messages"`
 	Options   OllamaMessageCompletionRequestOptions `json:"options,omitempty"`
-	Stream    bool                                  `json:"stream,omitempty"`
+	Stream    bool                                  `json:"stream"`
 	KeepAlive string                                `json:"keep_alive,omitempty"`
 }
 
+// OllamaInputMessage is a message sent to the Ollama chat API.
+type OllamaInputMessage struct {
+	Role    string   `json:"role"`
+	Content string   `json:"content"`
+	Images  []string `json:"images,omitempty"`
+}
+
+// ollamaMessages converts the given messages into Ollama's format, which
+// takes the images of user messages as base64 data.
+func ollamaMessages(messages []openai.ChatCompletionMessage) ([]OllamaInputMessage, error) {
+	result := make([]OllamaInputMessage, 0, len(messages))
+	for _, message := range messages {
+		msg := OllamaInputMessage{
+			Role:    message.Role,
+			Content: messageText(message),
+		}
+		for _, part := range message.MultiContent {
+			if part.Type != openai.ChatMessagePartTypeImageURL || part.ImageURL == nil ||
+				message.Role != openai.ChatMessageRoleUser {
+				continue
+			}
+			data, err := ollamaImageData(part.ImageURL.URL)
+			if err != nil {
+				return nil, err
+			}
+			msg.Images = append(msg.Images, data)
+		}
+		result = append(result, msg)
+	}
+	return result, nil
+}
+
+func ollamaImageData(u string) (string, error) {
+	mediaType, data, ok := strings.Cut(strings.TrimPrefix(u, "data:"), ",")
+	if !strings.HasPrefix(u, "data:") || !ok || !strings.HasSuffix(mediaType, ";base64") {
+		return "", fmt.Errorf("ollamaImageData: images must be base64 data URIs")
+	}
+	return data, nil
+}
+
 // OllamaRequestBuilder is an interface for building HTTP requests for the Ollama API.
 type OllamaRequestBuilder interface {
 	Build(ctx context.Context, method, url string, body any, header http.Header) (*http.Request, error)
@@ -96,6 +138,10 @@ func (c *OllamaClient) newRequest(ctx context.Context, method, url string, sette
 	if err != nil {
 		return nil, fmt.Errorf("OllamaClient.newRequest: %w", err)
 	}
+	if c.config.AuthToken != "" {
+		// Ollama has no auth of its own, but it may be behind a proxy that does.
+		req.Header.Set("Authorization", "Bearer "+c.config.AuthToken)
+	}
 	return req, nil
 }
 
@@ -285,3 +331,32 @@ func (c *OllamaClient) CreateChatCompletionStream(
 	}
 	return
 }
+
+// CreateChatCompletion — API call to create a chat completion without
+// streaming, returning the whole response at once.
+func (c *OllamaClient) CreateChatCompletion(
+	ctx context.Context,
+	request OllamaMessageCompletionRequest,
+) (*OllamaCompletionMessageResponse, error) {
+	request.Stream = false
+	req, err := c.newRequest(ctx, http.MethodPost, c.config.BaseURL+ollamaChatCompletionsSuffix, withBody(request))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("content-type", "application/json")
+
+	resp, err := c.config.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if isFailureStatusCode(resp) {
+		return nil, c.handleErrorResp(resp)
+	}
+
+	var response OllamaCompletionMessageResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("OllamaClient.CreateChatCompletion: %w", err)
+	}
+	return &response, nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// opDefaultField is the field 1Password stores API keys in.
+const opDefaultField = "credential"
+
+// opReference returns the 1Password secret reference of the given op-item,
+// which can be a full op:// reference, an item/field or just an item, in
+// which case its credential field is used. Items are looked up in vault.
+func opReference(item, vault string) (string, error) {
+	if strings.HasPrefix(item, "op://") {
+		return item, nil
+	}
+	if vault == "" {
+		return "", newUserErrorf("Set MODS_OP_VAULT to the 1Password vault of %q, or use a full op:// reference.", item)
+	}
+	if !strings.Contains(item, "/") {
+		item += "/" + opDefaultField
+	}
+	return "op://" + vault + "/" + item, nil
+}
+
+// opRead reads the secret of the given op-item with the 1Password CLI.
+func opRead(item string) (string, error) {
+	ref, err := opReference(item, os.Getenv("MODS_OP_VAULT"))
+	if err != nil {
+		return "", err
+	}
+	// the reference is passed as is, so spaces and quotes in vault and item
+	// names need no escaping.
+	out, err := exec.Command("op", "read", "--no-newline", ref).Output()
+	if err != nil {
+		return "", fmt.Errorf("opRead: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
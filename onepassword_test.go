@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/caarlos0/go-shellwords"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOPReadCmdParsing(t *testing.T) {
+	for cmd, want := range map[string][]string{
+		`op read "op://Private/OpenAI API/credential"`:             {"op", "read", "op://Private/OpenAI API/credential"},
+		`op read 'op://Private/OpenAI API/credential'`:             {"op", "read", "op://Private/OpenAI API/credential"},
+		`op read op://Private/OpenAI\ API/credential --no-newline`: {"op", "read", "op://Private/OpenAI API/credential", "--no-newline"},
+		`op read "op://Private/Bob's key/credential"`:              {"op", "read", "op://Private/Bob's key/credential"},
+		`op read "op://Private/the \"main\" key/credential"`:       {"op", "read", `op://Private/the "main" key/credential`},
+		`op read --account my.1password.com op://Work/OpenAI/key`:  {"op", "read", "--account", "my.1password.com", "op://Work/OpenAI/key"},
+	} {
+		t.Run(cmd, func(t *testing.T) {
+			args, err := shellwords.Parse(cmd)
+			require.NoError(t, err)
+			require.Equal(t, want, args)
+		})
+	}
+}
+
+func TestOPReference(t *testing.T) {
+	for item, want := range map[string]string{
+		"OpenAI":                        "op://Private/OpenAI/credential",
+		"OpenAI API/api key":            "op://Private/OpenAI API/api key",
+		"op://Work/OpenAI API/password": "op://Work/OpenAI API/password",
+	} {
+		ref, err := opReference(item, "Private")
+		require.NoError(t, err)
+		require.Equal(t, want, ref)
+	}
+
+	_, err := opReference("OpenAI", "")
+	require.ErrorContains(t, err, "MODS_OP_VAULT")
+}
+
+func TestEnsureKeyOPItem(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake op is a shell script")
+	}
+	bin := t.TempDir()
+	require.NoError(t, os.WriteFile(
+		filepath.Join(bin, "op"),
+		[]byte("#!/bin/sh\nprintf 'key for %s' \"$3\"\n"),
+		0o700,
+	))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("MODS_OP_VAULT", "Private")
+
+	key, err := Mods{}.ensureKey(API{OPItem: "OpenAI API"}, "OPENAI_API_KEY", "")
+	require.NoError(t, err)
+	require.Equal(t, "key for op://Private/OpenAI API/credential", key)
+}
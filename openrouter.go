@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const (
+	openRouterBaseURL = "https://openrouter.ai/api/v1"
+	openRouterPrefix  = "openrouter/"
+)
+
+// openRouterModel returns the model for names like
+// openrouter/anthropic/claude-3.5-sonnet, which route any model to
+// OpenRouter without adding it to the settings.
+func openRouterModel(name string, maxChars int) (Model, bool) {
+	model, ok := strings.CutPrefix(name, openRouterPrefix)
+	if !ok || model == "" {
+		return Model{}, false
+	}
+	return Model{Name: model, API: "openrouter", MaxChars: maxChars}, true
+}
+
+// openRouterTransport adds the headers OpenRouter uses to attribute requests
+// to an app.
+type openRouterTransport struct {
+	base     http.RoundTripper
+	siteURL  string
+	siteName string
+}
+
+func (t *openRouterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if t.siteURL != "" {
+		req.Header.Set("HTTP-Referer", t.siteURL)
+	}
+	if t.siteName != "" {
+		req.Header.Set("X-Title", t.siteName)
+	}
+	return t.base.RoundTrip(req) //nolint:wrapcheck
+}
+
+// withOpenRouterHeaders wraps the given client so that requests carry the
+// site of the API settings.
+func withOpenRouterHeaders(doer openai.HTTPDoer, api API) *http.Client {
+	client := cloneClient(doer)
+	client.Transport = &openRouterTransport{
+		base:     baseTransport(client),
+		siteURL:  api.SiteURL,
+		siteName: api.SiteName,
+	}
+	return client
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenRouterModel(t *testing.T) {
+	mod, ok := openRouterModel("openrouter/anthropic/claude-3.5-sonnet", 100)
+	require.True(t, ok)
+	require.Equal(t, Model{Name: "anthropic/claude-3.5-sonnet", API: "openrouter", MaxChars: 100}, mod)
+
+	_, ok = openRouterModel("gpt-4o", 100)
+	require.False(t, ok)
+	_, ok = openRouterModel("openrouter/", 100)
+	require.False(t, ok)
+}
+
+func TestWithOpenRouterHeaders(t *testing.T) {
+	var got http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		got = r.Header
+	}))
+	t.Cleanup(ts.Close)
+
+	client := withOpenRouterHeaders(&http.Client{}, API{
+		SiteURL:  "https://example.com",
+		SiteName: "Example",
+	})
+	resp, err := client.Get(ts.URL)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, "https://example.com", got.Get("HTTP-Referer"))
+	require.Equal(t, "Example", got.Get("X-Title"))
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// parallelOutput is a tea.Msg sent once all the --parallel requests are done.
+type parallelOutput struct {
+	results []*Mods
+}
+
+// runHeadless sends content to the configured model without going through
+// the Bubble Tea program, and returns once the whole response is in m.Output.
+func (m *Mods) runHeadless(content string) *modsError {
+	msg := m.startCompletionCmd(content)()
+	for {
+		switch out := msg.(type) {
+		case completionInput:
+			// retrying.
+			msg = m.startCompletionCmd(out.content)()
+		case completionOutput:
+			if out.stream == nil {
+				return nil
+			}
+			m.Output += out.content
+			msg = m.receiveCompletionStreamCmd(out)()
+		case dryRunMsg:
+			return nil
+		case modsError:
+			return &out
+		default:
+			return &modsError{
+				err:    fmt.Errorf("unexpected message %T", msg),
+				reason: "There was an error running the request.",
+			}
+		}
+	}
+}
+
+// startParallelCmd sends content to each of the --parallel models at the
+// same time.
+func (m *Mods) startParallelCmd(content string) tea.Cmd {
+	return func() tea.Msg {
+		results := make([]*Mods, len(m.Config.Parallel))
+		var wg sync.WaitGroup
+		for i, model := range m.Config.Parallel {
+			cfg := *m.Config
+			cfg.Model = model
+			cfg.Parallel = nil
+			sub := newMods(m.renderer, &cfg, m.db, m.cache)
+			results[i] = sub
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sub.Error = sub.runHeadless(content)
+			}()
+		}
+		wg.Wait()
+		return parallelOutput{results}
+	}
+}
+
+// parallelText returns the responses one after the other, each under a
+// header with its model name.
+func parallelText(results []*Mods) string {
+	parts := make([]string, 0, len(results))
+	for _, r := range results {
+		parts = append(parts, fmt.Sprintf("# %s\n\n%s", r.Config.Model, parallelResult(r)))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func parallelResult(r *Mods) string {
+	if r.Error != nil {
+		return "Error: " + r.Error.reason + " " + r.Error.err.Error()
+	}
+	return strings.TrimSpace(r.Output)
+}
+
+// printParallel prints the responses side by side, in columns that fit in
+// the given width.
+func printParallel(w io.Writer, results []*Mods, width int) {
+	const gap = 2
+	colWidth := (width - gap*(len(results)-1)) / len(results)
+	if colWidth < 20 { //nolint:mnd
+		fmt.Fprintln(w, parallelText(results))
+		return
+	}
+
+	r := lipgloss.NewRenderer(w)
+	cols := make([]string, 0, len(results)*2) //nolint:mnd
+	for i, res := range results {
+		content := parallelResult(res)
+		if gr, err := glamour.NewTermRenderer(
+			glamour.WithEnvironmentConfig(),
+			glamour.WithWordWrap(colWidth),
+		); err == nil && res.Error == nil {
+			if out, err := gr.Render(content); err == nil {
+				content = strings.TrimSpace(out)
+			}
+		}
+		if i > 0 {
+			cols = append(cols, strings.Repeat(" ", gap))
+		}
+		cols = append(cols, r.NewStyle().Width(colWidth).Render(
+			lipgloss.JoinVertical(
+				lipgloss.Left,
+				outputHeader.Render(res.Config.Model),
+				"",
+				content,
+			),
+		))
+	}
+	fmt.Fprintln(w, lipgloss.JoinHorizontal(lipgloss.Top, cols...))
+}
+
+// saveParallel saves each response as its own conversation, with the model
+// name appended to the title.
+func saveParallel(results []*Mods) error {
+	for _, r := range results {
+		if r.Error != nil {
+			continue
+		}
+		title := strings.TrimSpace(r.Config.cacheWriteToTitle)
+		if sha1reg.MatchString(title) || title == "" {
+			title = firstLine(lastPrompt(r.messages))
+		}
+		r.Config.cacheWriteToID = newConversationID()
+		r.Config.cacheWriteToTitle = fmt.Sprintf("%s (%s)", title, r.Config.Model)
+		if err := saveConversation(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
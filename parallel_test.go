@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParallel(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if req.Model == "broken" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprintf(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hello from %s\"}}]}\n\n", req.Model)
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(ts.Close)
+
+	// saveConversation uses the global state.
+	oldConfig, oldDB, oldCache := config, db, cache
+	t.Cleanup(func() { config, db, cache = oldConfig, oldDB, oldCache })
+	config = Config{
+		Prefix:     "the question",
+		Quiet:      true,
+		MaxRetries: 1,
+		Parallel:   []string{"model-a", "model-b", "broken"},
+		Models: map[string]Model{
+			"model-a": {Name: "model-a", API: "openai", MaxChars: 1000},
+			"model-b": {Name: "model-b", API: "openai", MaxChars: 1000},
+			"broken":  {Name: "broken", API: "openai", MaxChars: 1000},
+		},
+		APIs: APIs{
+			{Name: "openai", BaseURL: ts.URL, APIKey: "fake"},
+		},
+	}
+	db = testDB(t)
+	cache = newCache(t.TempDir())
+
+	mods := newMods(lipgloss.DefaultRenderer(), &config, db, cache)
+	p := tea.NewProgram(
+		mods,
+		tea.WithInput(nil),
+		tea.WithOutput(io.Discard),
+		tea.WithoutRenderer(),
+	)
+	_, err := p.Run()
+	require.NoError(t, err)
+	require.Nil(t, mods.Error)
+	require.Len(t, mods.parallel, 3)
+	require.Equal(t, "hello from model-a", mods.parallel[0].Output)
+	require.Equal(t, "hello from model-b", mods.parallel[1].Output)
+	require.NotNil(t, mods.parallel[2].Error)
+
+	text := parallelText(mods.parallel)
+	require.Contains(t, text, "# model-a\n\nhello from model-a\n\n# model-b\n\nhello from model-b\n\n# broken\n\nError:")
+
+	require.NoError(t, saveParallel(mods.parallel))
+	convos, err := db.List()
+	require.NoError(t, err)
+	titles := make([]string, 0, len(convos))
+	for _, c := range convos {
+		titles = append(titles, c.Title)
+	}
+	require.ElementsMatch(t, []string{"the question (model-a)", "the question (model-b)"}, titles)
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	timeago "github.com/caarlos0/timea.go"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// pickerFooter documents the keys of the conversation picker.
+const pickerFooter = "/ filter • esc clear • ↑/↓ move • enter copy • q quit"
+
+// conversationItem is a conversation in the picker.
+type conversationItem struct {
+	Conversation
+}
+
+func (i conversationItem) FilterValue() string { return i.Title }
+
+// conversationLabel renders a conversation as a single line.
+func conversationLabel(c Conversation) string {
+	timea := stdoutStyles().Timeago.Render(timeago.Of(c.UpdatedAt))
+	left := stdoutStyles().SHA1.Render(c.ID[:sha1short])
+	title := c.Title
+	if c.Pinned {
+		title = pinMark(false) + " " + title
+	}
+	right := stdoutStyles().ConversationList.Render(title, timea)
+	if c.Model != nil {
+		right += stdoutStyles().Comment.Render(*c.Model)
+	}
+	if tags := c.TagList(); len(tags) > 0 {
+		right += stdoutStyles().Comment.Render(" #" + strings.Join(tags, " #"))
+	}
+	if c.MessageCount > 0 {
+		right += stdoutStyles().Comment.Render(fmt.Sprintf(" (%d messages, ~%d tokens)", c.MessageCount, c.ApproxTokens))
+	}
+	return left + " " + right
+}
+
+// conversationDelegate renders conversations one per line, with a cursor
+// next to the selected one.
+type conversationDelegate struct{}
+
+func (conversationDelegate) Height() int                         { return 1 }
+func (conversationDelegate) Spacing() int                        { return 0 }
+func (conversationDelegate) Update(tea.Msg, *list.Model) tea.Cmd { return nil }
+func (conversationDelegate) Render(w io.Writer, m list.Model, index int, item list.Item) {
+	c, ok := item.(conversationItem)
+	if !ok {
+		return
+	}
+	cursor := "  "
+	if index == m.Index() {
+		cursor = stdoutStyles().Flag.Render("> ")
+	}
+	fmt.Fprint(w, cursor+conversationLabel(c.Conversation))
+}
+
+// filterConversations returns the conversations whose ID starts with term,
+// followed by those whose title fuzzily matches it, best matches first.
+func filterConversations(conversations []Conversation, term string) []Conversation {
+	term = strings.TrimSpace(term)
+	if term == "" {
+		return conversations
+	}
+	var result []Conversation
+	byID := map[string]bool{}
+	for _, c := range conversations {
+		if strings.HasPrefix(c.ID, strings.ToLower(term)) {
+			result = append(result, c)
+			byID[c.ID] = true
+		}
+	}
+	titles := make([]string, len(conversations))
+	for i, c := range conversations {
+		titles[i] = c.Title
+	}
+	for _, rank := range list.DefaultFilter(term, titles) {
+		if c := conversations[rank.Index]; !byID[c.ID] {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// pickerModel lets the user pick a conversation, filtering them as they
+// type.
+type pickerModel struct {
+	conversations []Conversation
+	filter        textinput.Model
+	list          list.Model
+	selected      string
+}
+
+func newPicker(conversations []Conversation, title string) *pickerModel {
+	filter := textinput.New()
+	filter.Prompt = "/ "
+	filter.Placeholder = "filter by title or ID"
+
+	l := list.New(nil, conversationDelegate{}, 0, 0)
+	l.Title = title
+	l.Styles.Title = stdoutStyles().Flag.Bold(true)
+	l.SetFilteringEnabled(false)
+	l.SetShowHelp(false)
+	l.SetShowStatusBar(false)
+	l.DisableQuitKeybindings()
+
+	m := &pickerModel{
+		conversations: conversations,
+		filter:        filter,
+		list:          l,
+	}
+	m.applyFilter()
+	return m
+}
+
+func (m *pickerModel) applyFilter() {
+	matches := filterConversations(m.conversations, m.filter.Value())
+	items := make([]list.Item, 0, len(matches))
+	for _, c := range matches {
+		items = append(items, conversationItem{c})
+	}
+	m.list.SetItems(items)
+	m.list.ResetSelected()
+}
+
+func (m *pickerModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m *pickerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		// leave room for the filter and the footer.
+		m.list.SetSize(msg.Width, msg.Height-4) //nolint:mnd
+		return m, nil
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c":
+			return m, tea.Quit
+		case "enter":
+			if item, ok := m.list.SelectedItem().(conversationItem); ok {
+				m.selected = item.ID
+			}
+			return m, tea.Quit
+		case "esc":
+			if m.filter.Value() == "" && !m.filter.Focused() {
+				return m, tea.Quit
+			}
+			m.filter.Reset()
+			m.filter.Blur()
+			m.applyFilter()
+			return m, nil
+		case "up", "down", "pgup", "pgdown":
+			var cmd tea.Cmd
+			m.list, cmd = m.list.Update(msg)
+			return m, cmd
+		}
+		if m.filter.Focused() {
+			value := m.filter.Value()
+			var cmd tea.Cmd
+			m.filter, cmd = m.filter.Update(msg)
+			if m.filter.Value() != value {
+				m.applyFilter()
+			}
+			return m, cmd
+		}
+		switch msg.String() {
+		case "/":
+			return m, m.filter.Focus()
+		case "q":
+			return m, tea.Quit
+		}
+	}
+	var cmd tea.Cmd
+	m.list, cmd = m.list.Update(msg)
+	return m, cmd
+}
+
+func (m *pickerModel) View() string {
+	return lipgloss.JoinVertical(
+		lipgloss.Left,
+		m.filter.View(),
+		m.list.View(),
+		stdoutStyles().Comment.Render(pickerFooter),
+	)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/require"
+)
+
+var pickerConversations = []Conversation{
+	{ID: "df31ae23ab8b75b5643c2f846c570997edc71333", Title: "how to write a Makefile"},
+	{ID: "6c33f71694bf41a18c844a96d1f62f153e5f6f44", Title: "football teams"},
+	{ID: "fc5012d8c67073ea0a46a3c05488a0e1d87df74b", Title: "6 ways to cook rice"},
+}
+
+func conversationIDs(conversations []Conversation) []string {
+	ids := []string{}
+	for _, c := range conversations {
+		ids = append(ids, c.ID[:4])
+	}
+	return ids
+}
+
+func TestFilterConversations(t *testing.T) {
+	require.Len(t, filterConversations(pickerConversations, ""), 3)
+	require.Equal(t, []string{"df31"}, conversationIDs(filterConversations(pickerConversations, "makefile")))
+	require.Equal(t, []string{"df31"}, conversationIDs(filterConversations(pickerConversations, "mkfl")))
+	require.Equal(t, []string{"6c33", "fc50"}, conversationIDs(filterConversations(pickerConversations, "6")))
+	require.Equal(t, []string{"fc50"}, conversationIDs(filterConversations(pickerConversations, "FC50")))
+	require.Empty(t, filterConversations(pickerConversations, "basketball"))
+}
+
+func TestPicker(t *testing.T) {
+	keys := func(m tea.Model, keys ...tea.KeyMsg) (tea.Model, tea.Cmd) {
+		t.Helper()
+		var cmd tea.Cmd
+		for _, k := range keys {
+			m, cmd = m.Update(k)
+		}
+		return m, cmd
+	}
+	runes := func(s string) tea.KeyMsg {
+		return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+	}
+	visible := func(m tea.Model) int {
+		return len(m.(*pickerModel).list.Items())
+	}
+
+	t.Run("filter and select", func(t *testing.T) {
+		m, _ := newPicker(pickerConversations, "Conversations").Update(tea.WindowSizeMsg{Width: 80, Height: 20})
+		m, _ = keys(m, runes("/"), runes("r"), runes("i"), runes("c"), runes("e"))
+		require.Equal(t, 1, visible(m))
+		require.Contains(t, m.View(), "6 ways to cook rice")
+
+		_, cmd := keys(m, tea.KeyMsg{Type: tea.KeyEnter})
+		require.Equal(t, "fc5012d8c67073ea0a46a3c05488a0e1d87df74b", m.(*pickerModel).selected)
+		require.IsType(t, tea.QuitMsg{}, cmd())
+	})
+
+	t.Run("esc clears the filter", func(t *testing.T) {
+		m, _ := keys(newPicker(pickerConversations, ""), runes("/"), runes("z"), runes("z"))
+		require.Zero(t, visible(m))
+
+		m, cmd := keys(m, tea.KeyMsg{Type: tea.KeyEsc})
+		require.Nil(t, cmd)
+		require.Equal(t, 3, visible(m))
+
+		_, cmd = keys(m, tea.KeyMsg{Type: tea.KeyEsc})
+		require.IsType(t, tea.QuitMsg{}, cmd())
+		require.Empty(t, m.(*pickerModel).selected)
+	})
+
+	t.Run("q only quits outside the filter", func(t *testing.T) {
+		m, _ := keys(newPicker(pickerConversations, ""), runes("/"), runes("q"))
+		require.Equal(t, "q", m.(*pickerModel).filter.Value())
+	})
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// maxRetryAfter is the longest we wait before retrying a rate limited
+// request, unless --max-retry-wait says otherwise.
+const maxRetryAfter = time.Minute
+
+// retryAfterTransport records how long rate limited responses ask to wait, so
+// the next retry can wait as long as the server asked for.
+type retryAfterTransport struct {
+	base http.RoundTripper
+	wait *time.Duration
+}
+
+func (t *retryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err //nolint:wrapcheck
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		*t.wait = rateLimitWait(resp.Header, time.Now())
+	}
+	return resp, nil
+}
+
+// withRetryAfter wraps the given client so that rate limit responses set wait.
+func withRetryAfter(doer openai.HTTPDoer, wait *time.Duration) *http.Client {
+	client := cloneClient(doer)
+	client.Transport = &retryAfterTransport{base: baseTransport(doer), wait: wait}
+	return client
+}
+
+// rateLimitWait returns how long a rate limited response asks to wait, from
+// its Retry-After header, or OpenAI's x-ratelimit-reset-requests.
+func rateLimitWait(h http.Header, now time.Time) time.Duration {
+	if wait := parseRetryAfter(h.Get("Retry-After"), now); wait > 0 {
+		return wait
+	}
+	// e.g. 1s or 6m0s.
+	if wait, err := time.ParseDuration(h.Get("X-Ratelimit-Reset-Requests")); err == nil && wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// parseRetryAfter parses a Retry-After header value, which is either a number
+// of seconds or an HTTP date.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.ParseFloat(v, 64); err == nil && secs > 0 {
+		return time.Duration(secs * float64(time.Second))
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
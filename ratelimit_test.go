@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	for name, tc := range map[string]struct {
+		value string
+		want  time.Duration
+	}{
+		"empty":   {"", 0},
+		"seconds": {"3", 3 * time.Second},
+		"float":   {"1.5", 1500 * time.Millisecond},
+		"date":    {now.Add(10 * time.Second).Format(http.TimeFormat), 10 * time.Second},
+		"past":    {now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		"garbage": {"soon", 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.want, parseRetryAfter(tc.value, now))
+		})
+	}
+}
+
+func TestRetryAfterTransport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Retry-After", "2")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	t.Cleanup(ts.Close)
+
+	var wait time.Duration
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	base := &http.Client{Timeout: 5 * time.Second, Jar: jar}
+	client := withRetryAfter(base, &wait)
+	require.Equal(t, base.Timeout, client.Timeout)
+	require.Equal(t, base.Jar, client.Jar)
+	require.Nil(t, base.Transport)
+
+	resp, err := client.Get(ts.URL)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, 2*time.Second, wait)
+}
+
+func TestRateLimitWait(t *testing.T) {
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	for name, tc := range map[string]struct {
+		header http.Header
+		want   time.Duration
+	}{
+		"none":         {http.Header{}, 0},
+		"retry after":  {http.Header{"Retry-After": {"3"}}, 3 * time.Second},
+		"openai reset": {http.Header{"X-Ratelimit-Reset-Requests": {"6m0s"}}, 6 * time.Minute},
+		"both": {
+			http.Header{"Retry-After": {"3"}, "X-Ratelimit-Reset-Requests": {"20ms"}},
+			3 * time.Second,
+		},
+		"garbage reset": {http.Header{"X-Ratelimit-Reset-Requests": {"soon"}}, 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.want, rateLimitWait(tc.header, now))
+		})
+	}
+}
+
+func TestRetryAfterLimit(t *testing.T) {
+	mods := &Mods{
+		Config:     &Config{MaxRetries: 5, MaxRetryWait: 10 * time.Millisecond},
+		retryAfter: maxRetryAfter + time.Second,
+	}
+	merr := modsError{reason: "rate limited"}
+	start := time.Now()
+	require.Equal(t, completionInput{"hi"}, mods.retry("hi", merr))
+	require.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+	require.Less(t, time.Since(start), time.Second)
+	require.Zero(t, mods.retryAfter)
+}
+
+func TestRetryAfterWait(t *testing.T) {
+	mods := &Mods{
+		Config:     &Config{MaxRetries: 5},
+		retryAfter: 20 * time.Millisecond,
+	}
+	start := time.Now()
+	require.Equal(t, completionInput{"hi"}, mods.retry("hi", modsError{}))
+	require.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+}
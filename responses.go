@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// defaultResponseTTL is how long responses are cached when --cache-ttl is
+// not set.
+const defaultResponseTTL = time.Hour
+
+// responseCache keeps responses to identical requests, so they can be
+// answered without calling the API again.
+type responseCache struct {
+	dir string
+	now func() time.Time
+}
+
+func newResponseCache(cachePath string) *responseCache {
+	return &responseCache{
+		dir: filepath.Join(cachePath, "responses"),
+		now: time.Now,
+	}
+}
+
+// useResponseCache reports whether responses should be cached: when asked
+// to with --cache-responses, or when the temperature makes them
+// deterministic.
+func useResponseCache(cfg *Config) bool {
+	if cfg.NoCache || cfg.CachePath == "" || cfg.Completions > 1 {
+		return false
+	}
+	return cfg.CacheResponses || cfg.Temperature == 0
+}
+
+// responseKey identifies a request by its model, sampling and length
+// settings, schema and messages.
+func responseKey(mod Model, cfg *Config, messages []openai.ChatCompletionMessage) string {
+	bts, _ := json.Marshal(struct {
+		API                 string
+		Model               string
+		Temperature         float32
+		TopP                float32
+		TopK                int
+		Stop                []string
+		Seed                int64
+		PresencePenalty     float32
+		FrequencyPenalty    float32
+		MaxTokens           int
+		MaxCompletionTokens int
+		ThinkingBudget      int
+		Messages            []openai.ChatCompletionMessage
+		Schema              string `json:",omitempty"`
+	}{
+		API:                 mod.API,
+		Model:               mod.Name,
+		Temperature:         cfg.Temperature,
+		TopP:                cfg.TopP,
+		TopK:                cfg.TopK,
+		Stop:                cfg.Stop,
+		Seed:                cfg.Seed,
+		PresencePenalty:     cfg.PresencePenalty,
+		FrequencyPenalty:    cfg.FrequencyPenalty,
+		MaxTokens:           cfg.MaxTokens,
+		MaxCompletionTokens: cfg.MaxCompletionTokens,
+		ThinkingBudget:      mod.ThinkingBudget,
+		Messages:            messages,
+		Schema:              cfg.ResponseSchema,
+	})
+	sum := sha256.Sum256(bts)
+	return hex.EncodeToString(sum[:])
+}
+
+// get returns the cached response for key, if it has not expired. The
+// first line of the file is when it expires.
+func (c *responseCache) get(key string) (string, bool) {
+	bts, err := os.ReadFile(filepath.Join(c.dir, key))
+	if err != nil {
+		return "", false
+	}
+	expiry, content, ok := strings.Cut(string(bts), "\n")
+	if !ok {
+		return "", false
+	}
+	expires, err := time.Parse(time.RFC3339, expiry)
+	if err != nil || !expires.After(c.now()) {
+		_ = os.Remove(filepath.Join(c.dir, key))
+		return "", false
+	}
+	return content, true
+}
+
+// set caches the response for key for ttl.
+func (c *responseCache) set(key, content string, ttl time.Duration) error {
+	if err := os.MkdirAll(c.dir, 0o700); err != nil { //nolint:mnd
+		return fmt.Errorf("set: %w", err)
+	}
+	expires := c.now().Add(ttl).UTC().Format(time.RFC3339)
+	if err := os.WriteFile(filepath.Join(c.dir, key), []byte(expires+"\n"+content), 0o600); err != nil { //nolint:mnd
+		return fmt.Errorf("set: %w", err)
+	}
+	return nil
+}
+
+func responseTTL(cfg *Config) time.Duration {
+	if cfg.CacheTTL > 0 {
+		return cfg.CacheTTL
+	}
+	return defaultResponseTTL
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseCache(t *testing.T) {
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	c := newResponseCache(t.TempDir())
+	c.now = func() time.Time { return now }
+
+	_, ok := c.get("key")
+	require.False(t, ok)
+
+	require.NoError(t, c.set("key", "the response\non two lines", time.Hour))
+	out, ok := c.get("key")
+	require.True(t, ok)
+	require.Equal(t, "the response\non two lines", out)
+
+	now = now.Add(time.Hour)
+	_, ok = c.get("key")
+	require.False(t, ok)
+	require.NoFileExists(t, filepath.Join(c.dir, "key"))
+
+	require.NoError(t, os.WriteFile(filepath.Join(c.dir, "bad"), []byte("garbage"), 0o600))
+	_, ok = c.get("bad")
+	require.False(t, ok)
+}
+
+func TestResponseKey(t *testing.T) {
+	mod := Model{Name: "gpt-4o", API: "openai"}
+	messages := []openai.ChatCompletionMessage{{Role: "user", Content: "hi"}}
+	key := responseKey(mod, &Config{}, messages)
+	require.Len(t, key, 64)
+	require.Equal(t, key, responseKey(mod, &Config{}, messages))
+	require.NotEqual(t, key, responseKey(mod, &Config{Temperature: 0.5}, messages))
+	for name, cfg := range map[string]*Config{
+		"stop":                  {Stop: []string{"\n"}},
+		"seed":                  {Seed: 42},
+		"presence-penalty":      {PresencePenalty: 1},
+		"frequency-penalty":     {FrequencyPenalty: 1},
+		"max-completion-tokens": {MaxCompletionTokens: 100},
+	} {
+		require.NotEqual(t, key, responseKey(mod, cfg, messages), name)
+	}
+	thinking := mod
+	thinking.ThinkingBudget = 1024
+	require.NotEqual(t, key, responseKey(thinking, &Config{}, messages))
+	require.NotEqual(t, key, responseKey(Model{Name: "gpt-4o-mini", API: "openai"}, &Config{}, messages))
+	require.NotEqual(t, key, responseKey(mod, &Config{}, []openai.ChatCompletionMessage{{Role: "user", Content: "hello"}}))
+}
+
+func TestUseResponseCache(t *testing.T) {
+	require.False(t, useResponseCache(&Config{Temperature: 1}))
+	require.True(t, useResponseCache(&Config{Temperature: 1, CacheResponses: true, CachePath: "x"}))
+	require.True(t, useResponseCache(&Config{CachePath: "x"}))
+	require.False(t, useResponseCache(&Config{CachePath: "x", NoCache: true}))
+	require.False(t, useResponseCache(&Config{CacheResponses: true}))
+}
+
+func TestCachedResponses(t *testing.T) {
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		n := hits.Add(1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprintf(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"response %d\"}}]}\n\n", n)
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(ts.Close)
+
+	cfg := &Config{
+		Model:          "gpt-4o",
+		Prefix:         "hello",
+		Quiet:          true,
+		MaxRetries:     1,
+		Temperature:    1,
+		CacheResponses: true,
+		CachePath:      t.TempDir(),
+		Models: map[string]Model{
+			"gpt-4o": {Name: "gpt-4o", API: "openai", MaxChars: 1000},
+		},
+		APIs: APIs{
+			{Name: "openai", BaseURL: ts.URL, APIKey: "fake"},
+		},
+	}
+	run := func() *Mods {
+		mods := newMods(lipgloss.DefaultRenderer(), cfg, nil, nil)
+		require.Nil(t, mods.runHeadless(""))
+		return mods
+	}
+
+	require.Equal(t, "response 1", run().Output)
+	second := run()
+	require.Equal(t, "response 1", second.Output)
+	require.Equal(t, "response 1", second.messages[len(second.messages)-1].Content)
+	require.Equal(t, int32(1), hits.Load())
+
+	cfg.Prefix = "something else"
+	require.Equal(t, "response 2", run().Output)
+}
+
+func TestCachedResponsesEcho(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"the answer\"}}]}\n\n")
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(ts.Close)
+
+	cfg := &Config{
+		Model:             "gpt-4o",
+		Prefix:            "the question",
+		Quiet:             true,
+		IncludePromptArgs: true,
+		Separator:         "\n\n",
+		CacheResponses:    true,
+		CachePath:         t.TempDir(),
+		Models:            map[string]Model{"gpt-4o": {Name: "gpt-4o", API: "openai", MaxChars: 1000}},
+		APIs:              APIs{{Name: "openai", BaseURL: ts.URL, APIKey: "fake"}},
+	}
+	run := func() *Mods {
+		p := tea.NewProgram(
+			newMods(lipgloss.DefaultRenderer(), cfg, nil, nil),
+			tea.WithInput(nil),
+			tea.WithOutput(io.Discard),
+			tea.WithoutRenderer(),
+		)
+		m, err := p.Run()
+		require.NoError(t, err)
+		return m.(*Mods)
+	}
+
+	require.Equal(t, "the question\n\nthe answer", run().Output)
+	second := run()
+	require.True(t, second.responseHit)
+	require.Equal(t, "the question\n\nthe answer", second.Output)
+}
+
+func TestCachedResponsesSummarizeOnce(t *testing.T) {
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"short\"}}]}\n\n")
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(ts.Close)
+
+	mods := newMods(lipgloss.DefaultRenderer(), &Config{
+		Model:              "gpt-4o",
+		Quiet:              true,
+		CacheResponses:     true,
+		CachePath:          t.TempDir(),
+		TruncationStrategy: truncateSummarize,
+		Models:             map[string]Model{"gpt-4o": {Name: "gpt-4o", API: "openai", MaxChars: 20}},
+		APIs:               APIs{{Name: "openai", BaseURL: ts.URL, APIKey: "fake"}},
+	}, nil, nil)
+	require.Nil(t, mods.runHeadless("a prompt that is way too long for the model"))
+	// one summary and one answer.
+	require.Equal(t, int32(2), hits.Load())
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// errSchemaMismatch is returned when a response doesn't match the --schema.
+var errSchemaMismatch = errors.New("response does not match the schema")
+
+// schemaNameRe matches what OpenAI doesn't allow in a schema name.
+var schemaNameRe = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// responseSchema is a JSON Schema the response of a request must follow.
+type responseSchema struct {
+	name       string
+	raw        json.RawMessage
+	definition jsonschema.Definition
+}
+
+// loadResponseSchema reads the JSON Schema in the given file.
+func loadResponseSchema(path string) (*responseSchema, error) {
+	bts, err := os.ReadFile(path)
+	if err != nil {
+		return nil, modsError{err, "Could not read the schema file."}
+	}
+	var definition jsonschema.Definition
+	if err := json.Unmarshal(bts, &definition); err != nil {
+		return nil, modsError{err, "Could not parse the schema file."}
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	name = schemaNameRe.ReplaceAllString(name, "_")
+	if name == "" {
+		name = "response"
+	}
+	return &responseSchema{
+		name:       name,
+		raw:        json.RawMessage(bts),
+		definition: definition,
+	}, nil
+}
+
+// responseFormat asks OpenAI for a response following the schema.
+func (s *responseSchema) responseFormat() *openai.ChatCompletionResponseFormat {
+	return &openai.ChatCompletionResponseFormat{
+		Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+		JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+			Name:   s.name,
+			Schema: s.raw,
+			Strict: true,
+		},
+	}
+}
+
+// validate checks the given response against the schema, returning an error
+// with the raw response if it doesn't match.
+func (s *responseSchema) validate(response string) error {
+	var data any
+	if err := json.Unmarshal([]byte(response), &data); err != nil {
+		return fmt.Errorf("%w: %v\n\n%s", errSchemaMismatch, err, response)
+	}
+	if !jsonschema.Validate(s.definition, data) {
+		return fmt.Errorf("%w:\n\n%s", errSchemaMismatch, response)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// secretTTL is how long secrets read from AWS Secrets Manager are reused,
+// e.g. by the requests of --parallel and --times.
+const secretTTL = 15 * time.Minute
+
+type secretsManagerClient interface {
+	GetSecretValue(
+		ctx context.Context,
+		params *secretsmanager.GetSecretValueInput,
+		optFns ...func(*secretsmanager.Options),
+	) (*secretsmanager.GetSecretValueOutput, error)
+}
+
+// newSecretsManagerClient is a variable so tests can replace it.
+var newSecretsManagerClient = func(region string, creds aws.Credentials) secretsManagerClient {
+	return secretsmanager.New(secretsmanager.Options{
+		Region: region,
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return creds, nil
+		}),
+	})
+}
+
+type cachedSecret struct {
+	value   string
+	expires time.Time
+}
+
+var secretCache = struct {
+	sync.Mutex
+	secrets map[string]cachedSecret
+}{secrets: map[string]cachedSecret{}}
+
+// secretsManagerRegion returns the region of the secret, which is part of
+// its ARN, falling back to the region in the API settings and environment.
+func secretsManagerRegion(arn string, api API) string {
+	// arn:aws:secretsmanager:<region>:<account>:secret:<name>
+	if parts := strings.Split(arn, ":"); len(parts) > 3 && parts[3] != "" {
+		return parts[3]
+	}
+	for _, region := range []string{api.Region, os.Getenv("MODS_AWS_REGION")} {
+		if region != "" {
+			return region
+		}
+	}
+	return bedrockRegion(API{})
+}
+
+// secretsManagerKey reads the API key from the AWS Secrets Manager secret
+// set in api-key-secret-arn, signing in like Bedrock does, with the AWS
+// environment variables or profile.
+func secretsManagerKey(api API) (string, error) {
+	arn := api.APIKeySecretARN
+	secretCache.Lock()
+	defer secretCache.Unlock()
+	if cached, ok := secretCache.secrets[arn]; ok && time.Now().Before(cached.expires) {
+		return cached.value, nil
+	}
+
+	region := secretsManagerRegion(arn, api)
+	if region == "" {
+		return "", newUserErrorf("Set the region of the secret with MODS_AWS_REGION or AWS_REGION.")
+	}
+	creds, err := bedrockCredentials(API{Profile: api.Profile})
+	if err != nil {
+		return "", err
+	}
+	out, err := newSecretsManagerClient(region, creds).GetSecretValue(
+		context.Background(),
+		&secretsmanager.GetSecretValueInput{SecretId: aws.String(arn)},
+	)
+	if err != nil {
+		return "", fmt.Errorf("secretsManagerKey: %w", err)
+	}
+	if out.SecretString == nil {
+		return "", errors.New("secretsManagerKey: the secret has no string value")
+	}
+
+	value := strings.TrimSpace(*out.SecretString)
+	secretCache.secrets[arn] = cachedSecret{value: value, expires: time.Now().Add(secretTTL)}
+	return value, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSecretsManager struct {
+	region string
+	calls  int
+}
+
+func (f *fakeSecretsManager) GetSecretValue(
+	_ context.Context,
+	params *secretsmanager.GetSecretValueInput,
+	_ ...func(*secretsmanager.Options),
+) (*secretsmanager.GetSecretValueOutput, error) {
+	f.calls++
+	return &secretsmanager.GetSecretValueOutput{
+		SecretString: aws.String("key of " + *params.SecretId + "\n"),
+	}, nil
+}
+
+func TestSecretsManagerKey(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	fake := &fakeSecretsManager{}
+	newClient := newSecretsManagerClient
+	newSecretsManagerClient = func(region string, creds aws.Credentials) secretsManagerClient {
+		require.Equal(t, "AKID", creds.AccessKeyID)
+		fake.region = region
+		return fake
+	}
+	t.Cleanup(func() { newSecretsManagerClient = newClient })
+
+	const arn = "arn:aws:secretsmanager:eu-west-1:123456789012:secret:openai-AbCdEf"
+	api := API{APIKeySecretARN: arn}
+	key, err := Mods{}.ensureKey(api, "OPENAI_API_KEY", "")
+	require.NoError(t, err)
+	require.Equal(t, "key of "+arn, key)
+	require.Equal(t, "eu-west-1", fake.region)
+
+	// cached.
+	key, err = secretsManagerKey(api)
+	require.NoError(t, err)
+	require.Equal(t, "key of "+arn, key)
+	require.Equal(t, 1, fake.calls)
+}
+
+func TestSecretsManagerRegion(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	t.Setenv("MODS_AWS_REGION", "")
+	require.Equal(t, "eu-west-1", secretsManagerRegion("arn:aws:secretsmanager:eu-west-1:123:secret:x", API{Region: "us-east-2"}))
+	require.Equal(t, "us-east-2", secretsManagerRegion("openai", API{Region: "us-east-2"}))
+	require.Equal(t, "", secretsManagerRegion("openai", API{}))
+	t.Setenv("MODS_AWS_REGION", "ap-south-1")
+	require.Equal(t, "ap-south-1", secretsManagerRegion("openai", API{}))
+	t.Setenv("AWS_REGION", "us-west-2")
+	require.Equal(t, "ap-south-1", secretsManagerRegion("openai", API{}))
+}
@@ -9,6 +9,7 @@ import (
 
 const (
 	sha1short         = 7
+	sha1len           = 40
 	sha1minLen        = 4
 	sha1ReadBlockSize = 4096
 )
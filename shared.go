@@ -8,6 +8,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
 )
 
 type httpHeader http.Header
@@ -147,3 +151,88 @@ func (jm *JSONUnmarshaler) Unmarshal(data []byte, v any) error {
 	}
 	return nil
 }
+
+// baseTransport returns the transport used by the given client, so it can be
+// wrapped without losing things like proxy settings.
+func baseTransport(doer openai.HTTPDoer) http.RoundTripper {
+	if c, ok := doer.(*http.Client); ok && c != nil && c.Transport != nil {
+		return c.Transport
+	}
+	return http.DefaultTransport
+}
+
+// newProxyClient returns an HTTP client that sends requests through the given
+// proxy URL.
+func newProxyClient(proxy string) (*http.Client, error) {
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("newProxyClient: %w", err)
+	}
+	return &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}, nil
+}
+
+// cloneClient returns a copy of the given client, keeping its timeout, cookie
+// jar and redirect policy, so its transport can be replaced.
+func cloneClient(doer openai.HTTPDoer) *http.Client {
+	if c, ok := doer.(*http.Client); ok && c != nil {
+		client := *c
+		return &client
+	}
+	return &http.Client{}
+}
+
+// withTimeout returns a copy of the given client that gives up on requests
+// after timeout.
+func withTimeout(doer openai.HTTPDoer, timeout time.Duration) *http.Client {
+	client := cloneClient(doer)
+	client.Timeout = timeout
+	return client
+}
+
+// extraBodyTransport merges extra fields into the JSON body of outgoing
+// requests, for parameters that go-openai doesn't know about.
+type extraBodyTransport struct {
+	base   http.RoundTripper
+	fields map[string]any
+}
+
+func (t *extraBodyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil || len(t.fields) == 0 {
+		return t.base.RoundTrip(req) //nolint:wrapcheck
+	}
+	data, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("extraBodyTransport: %w", err)
+	}
+
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(data, &body); err == nil {
+		for k, v := range t.fields {
+			raw, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("extraBodyTransport: %w", err)
+			}
+			body[k] = raw
+		}
+		if data, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("extraBodyTransport: %w", err)
+		}
+	}
+
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.ContentLength = int64(len(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	return t.base.RoundTrip(req) //nolint:wrapcheck
+}
+
+// withExtraBody wraps the given client so that fields are added to every
+// request body.
+func withExtraBody(doer openai.HTTPDoer, fields map[string]any) *http.Client {
+	client := cloneClient(doer)
+	client.Transport = &extraBodyTransport{base: baseTransport(doer), fields: fields}
+	return client
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -14,7 +15,7 @@ func (m *Mods) createOpenAIStream(content string, ccfg openai.ClientConfig, mod
 	cfg := m.Config
 
 	client := openai.NewClientWithConfig(ccfg)
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(m.streamContext())
 	m.cancelRequest = cancel
 
 	if err := m.setupStreamContext(content, mod); err != nil {
@@ -28,12 +29,80 @@ func (m *Mods) createOpenAIStream(content string, ccfg openai.ClientConfig, mod
 		User:     cfg.User,
 	}
 
+	if cfg.Seed >= 0 {
+		seed := int(cfg.Seed)
+		req.Seed = &seed
+	}
+
+	if cfg.LogProbs || cfg.TopLogProbs > 0 {
+		req.LogProbs = true
+		req.TopLogProbs = cfg.TopLogProbs
+	}
+
+	// only OpenAI is known to accept stream_options.
+	if mod.API == "openai" {
+		req.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	}
+
 	if mod.API != "perplexity" || !strings.Contains(mod.Name, "online") {
 		req.Temperature = noOmitFloat(cfg.Temperature)
 		req.TopP = noOmitFloat(cfg.TopP)
 		req.Stop = cfg.Stop
-		req.MaxTokens = cfg.MaxTokens
+		switch {
+		case cfg.MaxCompletionTokens > 0:
+			req.MaxCompletionTokens = cfg.MaxCompletionTokens
+		case isReasoningModel(mod.Name):
+			// the o-series models refuse max_tokens.
+			req.MaxCompletionTokens = cfg.MaxTokens
+		default:
+			req.MaxTokens = cfg.MaxTokens
+		}
 		req.ResponseFormat = responseFormat(cfg)
+		if m.schema != nil {
+			req.ResponseFormat = m.schema.responseFormat()
+		}
+		if cfg.PresencePenalty >= 0 {
+			req.PresencePenalty = cfg.PresencePenalty
+		}
+		if cfg.FrequencyPenalty >= 0 {
+			req.FrequencyPenalty = cfg.FrequencyPenalty
+		}
+	}
+
+	// several completions are easier to tell apart when they come whole.
+	if cfg.Completions > 1 {
+		req.N = cfg.Completions
+	}
+
+	if cfg.NoStream || cfg.Completions > 1 {
+		req.Stream = false
+		req.StreamOptions = nil
+		resp, err := client.CreateChatCompletion(ctx, req)
+		if err != nil {
+			return m.handleRequestError(err, mod, content)
+		}
+		var answer string
+		var logprobs []openai.ChatCompletionTokenLogprob
+		if len(resp.Choices) > 0 {
+			answer = resp.Choices[0].Message.Content
+			logprobs = streamLogProbs(resp.Choices[0].LogProbs)
+		}
+		if len(resp.Choices) > 1 {
+			m.completions = make([]string, 0, len(resp.Choices))
+			for _, choice := range resp.Choices {
+				m.completions = append(m.completions, choice.Message.Content)
+			}
+			answer = strings.Join(m.completions, completionsSeparator)
+		}
+		var stream chatCompletionReceiver = &singleCompletionStream{
+			content:  answer,
+			usage:    &resp.Usage,
+			logprobs: logprobs,
+		}
+		if stripsThinking(mod, cfg.Raw) {
+			stream = &thinkStrippingStream{chatCompletionReceiver: stream}
+		}
+		return m.receiveCompletionStreamCmd(completionOutput{stream: stream})()
 	}
 
 	stream, err := client.CreateChatCompletionStream(ctx, req)
@@ -41,6 +110,11 @@ func (m *Mods) createOpenAIStream(content string, ccfg openai.ClientConfig, mod
 		return m.handleRequestError(err, mod, content)
 	}
 
+	if stripsThinking(mod, cfg.Raw) {
+		return m.receiveCompletionStreamCmd(completionOutput{
+			stream: &thinkStrippingStream{chatCompletionReceiver: stream},
+		})()
+	}
 	return m.receiveCompletionStreamCmd(completionOutput{stream: stream})()
 }
 
@@ -48,16 +122,21 @@ func (m *Mods) createOllamaStream(content string, occfg OllamaClientConfig, mod
 	cfg := m.Config
 
 	client := NewOllamaClientWithConfig(occfg)
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(m.streamContext())
 	m.cancelRequest = cancel
 
 	if err := m.setupStreamContext(content, mod); err != nil {
 		return err
 	}
 
+	messages, err := ollamaMessages(m.messages)
+	if err != nil {
+		return modsError{err, "Couldn't attach images to the request."}
+	}
+
 	req := OllamaMessageCompletionRequest{
 		Model:    mod.Name,
-		Messages: m.messages,
+		Messages: messages,
 		Stream:   true,
 		Options: OllamaMessageCompletionRequestOptions{
 			Temperature: noOmitFloat(cfg.Temperature),
@@ -73,6 +152,20 @@ func (m *Mods) createOllamaStream(content string, occfg OllamaClientConfig, mod
 		req.Options.NumCtx = cfg.MaxTokens
 	}
 
+	if cfg.Seed >= 0 {
+		req.Options.Seed = int(cfg.Seed)
+	}
+
+	if cfg.NoStream {
+		resp, err := client.CreateChatCompletion(ctx, req)
+		if err != nil {
+			return m.handleRequestError(err, mod, content)
+		}
+		return m.receiveCompletionStreamCmd(completionOutput{
+			stream: &singleCompletionStream{content: resp.Message.Content},
+		})()
+	}
+
 	stream, err := client.CreateChatCompletionStream(ctx, req)
 	if err != nil {
 		return m.handleRequestError(err, mod, content)
@@ -85,7 +178,7 @@ func (m *Mods) createGoogleStream(content string, gccfg GoogleClientConfig, mod
 	cfg := m.Config
 
 	client := NewGoogleClientWithConfig(gccfg)
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(m.streamContext())
 	m.cancelRequest = cancel
 
 	if err := m.setupStreamContext(content, mod); err != nil {
@@ -140,6 +233,11 @@ func (m *Mods) createGoogleStream(content string, gccfg GoogleClientConfig, mod
 	req := GoogleMessageCompletionRequest{
 		Contents:         messages,
 		GenerationConfig: generationConfig,
+		SafetySettings:   gccfg.SafetySettings,
+	}
+
+	if mod.Grounding {
+		req.Tools = append(req.Tools, GoogleTool{GoogleSearch: &GoogleSearch{}})
 	}
 
 	stream, err := client.CreateChatCompletionStream(ctx, req)
@@ -147,6 +245,11 @@ func (m *Mods) createGoogleStream(content string, gccfg GoogleClientConfig, mod
 		return m.handleRequestError(err, mod, content)
 	}
 
+	if mod.Grounding && cfg.Format {
+		return m.receiveCompletionStreamCmd(completionOutput{
+			stream: &googleCitationStream{GoogleChatCompletionStream: stream},
+		})()
+	}
 	return m.receiveCompletionStreamCmd(completionOutput{stream: stream})()
 }
 
@@ -154,7 +257,7 @@ func (m *Mods) createAnthropicStream(content string, accfg AnthropicClientConfig
 	cfg := m.Config
 
 	client := NewAnthropicClientWithConfig(accfg)
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(m.streamContext())
 	m.cancelRequest = cancel
 
 	if err := m.setupStreamContext(content, mod); err != nil {
@@ -173,10 +276,14 @@ func (m *Mods) createAnthropicStream(content string, accfg AnthropicClientConfig
 		}
 	}
 
+	input, err := anthropicMessages(messages)
+	if err != nil {
+		return modsError{err, "Couldn't attach images to the request."}
+	}
+
 	req := AnthropicMessageCompletionRequest{
 		Model:         mod.Name,
-		Messages:      messages,
-		System:        m.system,
+		Messages:      input,
 		Stream:        true,
 		Temperature:   noOmitFloat(cfg.Temperature),
 		TopP:          noOmitFloat(cfg.TopP),
@@ -184,12 +291,43 @@ func (m *Mods) createAnthropicStream(content string, accfg AnthropicClientConfig
 		StopSequences: cfg.Stop,
 	}
 
+	if m.system != "" {
+		req.System = []AnthropicInputContent{{Type: "text", Text: m.system}}
+	}
+
 	if cfg.MaxTokens > 0 {
 		req.MaxTokens = cfg.MaxTokens
 	} else {
 		req.MaxTokens = 4096
 	}
 
+	if mod.ThinkingBudget > 0 {
+		// the budget counts towards max_tokens, and thinking does not go
+		// along with sampling options.
+		req.Thinking = &AnthropicThinking{Type: "enabled", BudgetTokens: mod.ThinkingBudget}
+		if cfg.MaxTokens <= 0 {
+			req.MaxTokens += mod.ThinkingBudget
+		}
+		req.Temperature = 0
+		req.TopP = 0
+		req.TopK = 0
+	}
+
+	if cfg.PromptCaching {
+		anthropicCacheControl(&req)
+	}
+
+	if cfg.NoStream {
+		resp, err := client.CreateChatCompletion(ctx, req)
+		if err != nil {
+			return m.handleRequestError(err, mod, content)
+		}
+		usage := resp.usage()
+		return m.receiveCompletionStreamCmd(completionOutput{
+			stream: &singleCompletionStream{content: resp.text(), usage: &usage},
+		})()
+	}
+
 	stream, err := client.CreateChatCompletionStream(ctx, req)
 	if err != nil {
 		return m.handleRequestError(err, mod, content)
@@ -198,11 +336,65 @@ func (m *Mods) createAnthropicStream(content string, accfg AnthropicClientConfig
 	return m.receiveCompletionStreamCmd(completionOutput{stream: stream})()
 }
 
+func (m *Mods) createBedrockStream(content string, bccfg BedrockClientConfig, mod Model) tea.Msg {
+	cfg := m.Config
+
+	client := NewBedrockClientWithConfig(bccfg)
+	ctx, cancel := context.WithCancel(m.streamContext())
+	m.cancelRequest = cancel
+
+	if err := m.setupStreamContext(content, mod); err != nil {
+		return err
+	}
+
+	var system []BedrockContent
+	var messages []BedrockMessage
+	for _, message := range m.messages {
+		text := messageText(message)
+		if message.Role == openai.ChatMessageRoleSystem {
+			system = append(system, BedrockContent{Text: text})
+			continue
+		}
+		role := "user"
+		if message.Role == openai.ChatMessageRoleAssistant {
+			role = "assistant"
+		}
+		// Bedrock wants the roles to alternate, so consecutive messages
+		// with the same role are merged.
+		if n := len(messages); n > 0 && messages[n-1].Role == role {
+			messages[n-1].Content = append(messages[n-1].Content, BedrockContent{Text: text})
+			continue
+		}
+		messages = append(messages, BedrockMessage{
+			Role:    role,
+			Content: []BedrockContent{{Text: text}},
+		})
+	}
+
+	req := BedrockConverseRequest{
+		Messages: messages,
+		System:   system,
+		InferenceConfig: BedrockInferenceConfig{
+			MaxTokens:     cfg.MaxTokens,
+			Temperature:   &cfg.Temperature,
+			TopP:          &cfg.TopP,
+			StopSequences: cfg.Stop,
+		},
+	}
+
+	stream, err := client.CreateChatCompletionStream(ctx, mod.Name, req)
+	if err != nil {
+		return m.handleRequestError(err, mod, content)
+	}
+
+	return m.receiveCompletionStreamCmd(completionOutput{stream: stream})()
+}
+
 func (m *Mods) createCohereStream(content string, cccfg CohereClientConfig, mod Model) tea.Msg {
 	cfg := m.Config
 
 	client := NewCohereClientWithConfig(cccfg)
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(m.streamContext())
 	m.cancelRequest = cancel
 
 	if err := m.setupStreamContext(content, mod); err != nil {
@@ -252,6 +444,25 @@ func (m *Mods) createCohereStream(content string, cccfg CohereClientConfig, mod
 		req.MaxTokens = cohere.Int(cfg.MaxTokens)
 	}
 
+	if cfg.NoStream {
+		resp, err := client.CreateChatCompletion(ctx, &cohere.ChatRequest{
+			Model:         req.Model,
+			ChatHistory:   req.ChatHistory,
+			Message:       req.Message,
+			Preamble:      req.Preamble,
+			Temperature:   req.Temperature,
+			P:             req.P,
+			StopSequences: req.StopSequences,
+			MaxTokens:     req.MaxTokens,
+		})
+		if err != nil {
+			return m.handleRequestError(CohereToOpenAIAPIError(err), mod, content)
+		}
+		return m.receiveCompletionStreamCmd(completionOutput{
+			stream: &singleCompletionStream{content: resp.Text},
+		})()
+	}
+
 	stream, err := client.CreateChatCompletionStream(ctx, req)
 	if err != nil {
 		return m.handleRequestError(CohereToOpenAIAPIError(err), mod, content)
@@ -261,6 +472,10 @@ func (m *Mods) createCohereStream(content string, cccfg CohereClientConfig, mod
 }
 
 func (m *Mods) setupStreamContext(content string, mod Model) error {
+	if m.prepared {
+		m.prepared = false
+		return nil
+	}
 	cfg := m.Config
 	m.messages = []openai.ChatCompletionMessage{}
 	if cfg.Format {
@@ -286,6 +501,17 @@ func (m *Mods) setupStreamContext(content string, mod Model) error {
 					reason: "Could not use role",
 				}
 			}
+			content, err = expandRole(content, cfg.Vars, cfg.TemplateLeft, cfg.TemplateRight)
+			if err != nil {
+				return modsError{
+					err: err,
+					reason: fmt.Sprintf(
+						"Could not fill in role %s, check the values given with %s.",
+						m.Styles.InlineCode.Render(cfg.Role),
+						m.Styles.InlineCode.Render("--var"),
+					),
+				}
+			}
 			m.messages = append(m.messages, openai.ChatCompletionMessage{
 				Role:    openai.ChatMessageRoleSystem,
 				Content: content,
@@ -293,12 +519,20 @@ func (m *Mods) setupStreamContext(content string, mod Model) error {
 		}
 	}
 
+	if cfg.System != "" {
+		m.messages = append(m.messages, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: cfg.System,
+		})
+	}
+
 	if prefix := cfg.Prefix; prefix != "" {
 		content = strings.TrimSpace(prefix + "\n\n" + content)
 	}
 
-	if !cfg.NoLimit && len(content) > mod.MaxChars {
-		content = content[:mod.MaxChars]
+	// when estimating, keep the whole prompt so we can tell how much is over.
+	if !cfg.NoLimit && !cfg.EstimateTokens && len(content) > mod.MaxChars {
+		content = m.truncate(content, mod.MaxChars)
 	}
 
 	if !cfg.NoCache && cfg.cacheReadFromID != "" {
@@ -314,10 +548,97 @@ func (m *Mods) setupStreamContext(content string, mod Model) error {
 		}
 	}
 
-	m.messages = append(m.messages, openai.ChatCompletionMessage{
-		Role:    openai.ChatMessageRoleUser,
-		Content: content,
-	})
+	if len(cfg.Images) > 0 {
+		images, err := processImageFiles(cfg.Images)
+		if err != nil {
+			return modsError{err, "Could not read image."}
+		}
+		m.messages = append(m.messages, openai.ChatCompletionMessage{
+			Role: openai.ChatMessageRoleUser,
+			MultiContent: append([]openai.ChatMessagePart{
+				{
+					Type: openai.ChatMessagePartTypeText,
+					Text: content,
+				},
+			}, images...),
+		})
+	} else {
+		m.messages = append(m.messages, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleUser,
+			Content: content,
+		})
+	}
+
+	if !cfg.NoLimit && !cfg.EstimateTokens {
+		m.messages = truncateMessages(m.messages, mod.MaxChars, cfg.TruncationKeepLast)
+	}
 
+	if !cfg.DryRun && !cfg.EstimateTokens {
+		return m.checkCost(mod)
+	}
 	return nil
 }
+
+// supportsNoStream reports whether mods can make non-streamed requests to the
+// given API.
+func supportsNoStream(api string) bool {
+	switch api {
+	case "google", "vertexai", "bedrock":
+		return false
+	default:
+		return true
+	}
+}
+
+// isReasoningModel reports whether the given OpenAI model is one of the
+// o-series, like o1-preview or o3-mini.
+func isReasoningModel(name string) bool {
+	return len(name) > 1 && name[0] == 'o' && name[1] >= '0' && name[1] <= '9'
+}
+
+// completionsSeparator separates the answers of a request with --completions.
+const completionsSeparator = "\n---\n"
+
+// supportsCompletions reports whether the given API can return more than one
+// completion for a request.
+func supportsCompletions(api string) bool {
+	switch api {
+	case "anthropic", "google", "vertexai", "bedrock", "cohere", "ollama":
+		return false
+	default:
+		return true
+	}
+}
+
+var _ chatCompletionReceiver = &singleCompletionStream{}
+
+// singleCompletionStream adapts a non-streamed response to the
+// chatCompletionReceiver interface, delivering the whole content at once.
+type singleCompletionStream struct {
+	content  string
+	usage    *openai.Usage
+	logprobs []openai.ChatCompletionTokenLogprob
+	done     bool
+}
+
+func (s *singleCompletionStream) Close() error { return nil }
+func (s *singleCompletionStream) Recv() (openai.ChatCompletionStreamResponse, error) {
+	if s.done {
+		return openai.ChatCompletionStreamResponse{}, io.EOF
+	}
+	s.done = true
+	return openai.ChatCompletionStreamResponse{
+		Choices: []openai.ChatCompletionStreamChoice{
+			{
+				Delta: openai.ChatCompletionStreamChoiceDelta{
+					Content: s.content,
+					Role:    openai.ChatMessageRoleAssistant,
+				},
+				Logprobs: &openai.ChatCompletionStreamChoiceLogprobs{
+					Content: s.logprobs,
+				},
+			},
+		},
+		Usage: s.usage,
+	}, nil
+}
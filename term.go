@@ -16,6 +16,10 @@ var isOutputTTY = OnceValue(func() bool {
 	return isatty.IsTerminal(os.Stdout.Fd())
 })
 
+var isErrTTY = OnceValue(func() bool {
+	return isatty.IsTerminal(os.Stderr.Fd())
+})
+
 var stdoutRenderer = OnceValue(func() *lipgloss.Renderer {
 	return lipgloss.DefaultRenderer()
 })
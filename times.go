@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxVariedTemperature is the upper bound of the temperatures picked by
+// --temp-vary.
+const maxVariedTemperature = 2.0
+
+// startTimesCmd sends content to the model --times times, one after the
+// other.
+func (m *Mods) startTimesCmd(content string) tea.Cmd {
+	return func() tea.Msg {
+		results := make([]*Mods, 0, m.Config.Times)
+		for i := 0; i < m.Config.Times; i++ {
+			cfg := *m.Config
+			cfg.Times = 0
+			if cfg.TempVary {
+				cfg.Temperature = rand.Float32() * maxVariedTemperature //nolint:gosec
+			}
+			sub := newMods(m.renderer, &cfg, m.db, m.cache)
+			sub.Error = sub.runHeadless(content)
+			results = append(results, sub)
+		}
+		return parallelOutput{results}
+	}
+}
+
+// timesText returns the responses of all runs, separated by sep.
+func timesText(results []*Mods, sep string) string {
+	parts := make([]string, 0, len(results))
+	for _, r := range results {
+		parts = append(parts, parallelResult(r))
+	}
+	return strings.Join(parts, sep)
+}
+
+// numberedPath returns path with n added before its extension, e.g. out_1.md.
+func numberedPath(path string, n int) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s_%d%s", strings.TrimSuffix(path, ext), n, ext)
+}
+
+// saveTimes saves each run as its own conversation, with the run number
+// appended to the title.
+func saveTimes(results []*Mods) error {
+	for i, r := range results {
+		if r.Error != nil {
+			continue
+		}
+		if err := saveNumbered(r, i+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveNumbered saves the run as a new conversation, with n appended to the
+// title.
+func saveNumbered(r *Mods, n int) error {
+	title := strings.TrimSpace(r.Config.cacheWriteToTitle)
+	if sha1reg.MatchString(title) || title == "" {
+		title = firstLine(lastPrompt(r.messages))
+	}
+	r.Config.cacheWriteToID = newConversationID()
+	r.Config.cacheWriteToTitle = fmt.Sprintf("%s (%d)", title, n)
+	return saveConversation(r)
+}
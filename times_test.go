@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimes(t *testing.T) {
+	var mu sync.Mutex
+	var temps []float32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		mu.Lock()
+		temps = append(temps, req.Temperature)
+		n := len(temps)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprintf(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"answer %d\"}}]}\n\n", n)
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(ts.Close)
+
+	for name, tc := range map[string]struct {
+		tempVary bool
+	}{
+		"same temperature": {},
+		"varied":           {tempVary: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			temps = nil
+			cfg := &Config{
+				Model:       "gpt-4o",
+				Prefix:      "the question",
+				Quiet:       true,
+				NoCache:     true,
+				Temperature: 0.5,
+				Times:       3,
+				TempVary:    tc.tempVary,
+				Models: map[string]Model{
+					"gpt-4o": {Name: "gpt-4o", API: "openai", MaxChars: 1000},
+				},
+				APIs: APIs{
+					{Name: "openai", BaseURL: ts.URL, APIKey: "fake"},
+				},
+			}
+			mods := newMods(lipgloss.DefaultRenderer(), cfg, nil, nil)
+			p := tea.NewProgram(
+				mods,
+				tea.WithInput(nil),
+				tea.WithOutput(io.Discard),
+				tea.WithoutRenderer(),
+			)
+			_, err := p.Run()
+			require.NoError(t, err)
+			require.Nil(t, mods.Error)
+			require.Len(t, temps, 3)
+			require.Equal(t, "answer 1\n---\nanswer 2\n---\nanswer 3", timesText(mods.parallel, "\n---\n"))
+			for _, temp := range temps {
+				if tc.tempVary {
+					require.GreaterOrEqual(t, temp, float32(0))
+					require.LessOrEqual(t, temp, float32(maxVariedTemperature))
+					continue
+				}
+				require.Equal(t, float32(0.5), temp)
+			}
+		})
+	}
+}
+
+func TestNumberedPath(t *testing.T) {
+	require.Equal(t, "out_1.md", numberedPath("out.md", 1))
+	require.Equal(t, "dir/out_12", numberedPath("dir/out", 12))
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const togetherBaseURL = "https://api.together.xyz/v1"
+
+// togetherBodyFields returns the Together specific request fields.
+func togetherBodyFields(cfg *Config) map[string]any {
+	fields := map[string]any{}
+	if cfg.TopK > 0 {
+		fields["top_k"] = cfg.TopK
+	}
+	return fields
+}
+
+// togetherErrorTransport rewrites Together's error responses, which don't
+// always look like OpenAI's, so the OpenAI client can make sense of them.
+type togetherErrorTransport struct {
+	base http.RoundTripper
+}
+
+func (t *togetherErrorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err //nolint:wrapcheck
+	}
+	if isFailureStatusCode(resp) {
+		bts, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+		if fixed, ok := togetherError(bts); ok {
+			bts = fixed
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(bts))
+		resp.ContentLength = int64(len(bts))
+		resp.Header.Del("Content-Length")
+		return resp, nil
+	}
+	resp.Body = &togetherStreamBody{
+		ReadCloser: resp.Body,
+		reader:     bufio.NewReader(resp.Body),
+	}
+	return resp, nil
+}
+
+// withTogetherErrors wraps the given client so that Together's errors are
+// translated into OpenAI's.
+func withTogetherErrors(doer openai.HTTPDoer) *http.Client {
+	client := cloneClient(doer)
+	client.Transport = &togetherErrorTransport{base: baseTransport(doer)}
+	return client
+}
+
+// togetherError converts an error body where the error is a plain string, or
+// a message without the error object around it, into an OpenAI error body.
+func togetherError(bts []byte) ([]byte, bool) {
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(bts, &body); err != nil {
+		return nil, false
+	}
+
+	var apiErr openai.APIError
+	if raw, ok := body["error"]; ok {
+		if err := json.Unmarshal(raw, &apiErr.Message); err != nil {
+			// already an error object.
+			return nil, false
+		}
+	} else if raw, ok := body["message"]; ok {
+		if err := json.Unmarshal(raw, &apiErr.Message); err != nil {
+			return nil, false
+		}
+	} else {
+		return nil, false
+	}
+	for _, key := range []string{"type", "type_"} {
+		if raw, ok := body[key]; ok {
+			_ = json.Unmarshal(raw, &apiErr.Type)
+		}
+	}
+
+	fixed, err := json.Marshal(openai.ErrorResponse{Error: &apiErr})
+	if err != nil {
+		return nil, false
+	}
+	return fixed, true
+}
+
+// togetherStreamBody translates the errors sent in the middle of a stream.
+type togetherStreamBody struct {
+	io.ReadCloser
+	reader *bufio.Reader
+	buf    []byte
+}
+
+func (b *togetherStreamBody) Read(p []byte) (int, error) {
+	for len(b.buf) == 0 {
+		line, err := b.reader.ReadBytes('\n')
+		if data, ok := bytes.CutPrefix(line, []byte("data: ")); ok {
+			if fixed, ok := togetherError(bytes.TrimSpace(data)); ok {
+				line = append(append([]byte("data: "), fixed...), '\n')
+			}
+		}
+		b.buf = line
+		if err != nil {
+			if len(b.buf) == 0 {
+				return 0, err //nolint:wrapcheck
+			}
+			break
+		}
+	}
+	n := copy(p, b.buf)
+	b.buf = b.buf[n:]
+	return n, nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTogetherError(t *testing.T) {
+	for name, tc := range map[string]struct {
+		body  string
+		want  string
+		fixed bool
+	}{
+		"string error": {
+			body:  `{"error":"Input validation error: inputs too long"}`,
+			want:  `{"error":{"message":"Input validation error: inputs too long","type":""}}`,
+			fixed: true,
+		},
+		"message only": {
+			body:  `{"message":"model not found","type_":"invalid_request_error"}`,
+			want:  `{"error":{"message":"model not found","type":"invalid_request_error"}}`,
+			fixed: true,
+		},
+		"openai error": {
+			body: `{"error":{"message":"nope","type":"invalid_request_error"}}`,
+		},
+		"not an error": {
+			body: `{"choices":[]}`,
+		},
+		"not json": {
+			body: `bad gateway`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got, ok := togetherError([]byte(tc.body))
+			require.Equal(t, tc.fixed, ok)
+			if tc.fixed {
+				require.JSONEq(t, tc.want, string(got))
+			}
+		})
+	}
+}
+
+func TestTogetherStream(t *testing.T) {
+	t.Run("status error", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprint(w, `{"error":"Input validation error: inputs too long"}`)
+		}))
+		t.Cleanup(ts.Close)
+
+		cfg := openai.DefaultConfig("fake")
+		cfg.BaseURL = ts.URL
+		cfg.HTTPClient = withTogetherErrors(&http.Client{})
+		_, err := openai.NewClientWithConfig(cfg).CreateChatCompletionStream(context.Background(), openai.ChatCompletionRequest{})
+		var apiErr *openai.APIError
+		require.ErrorAs(t, err, &apiErr)
+		require.Equal(t, http.StatusBadRequest, apiErr.HTTPStatusCode)
+		require.Equal(t, "Input validation error: inputs too long", apiErr.Message)
+	})
+
+	t.Run("error in stream", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hi\"}}]}\n\n")
+			_, _ = fmt.Fprint(w, "data: {\"error\":\"model overloaded\"}\n\n")
+		}))
+		t.Cleanup(ts.Close)
+
+		cfg := openai.DefaultConfig("fake")
+		cfg.BaseURL = ts.URL
+		cfg.HTTPClient = withTogetherErrors(&http.Client{})
+		stream, err := openai.NewClientWithConfig(cfg).CreateChatCompletionStream(context.Background(), openai.ChatCompletionRequest{})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = stream.Close() })
+
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, "hi", resp.Choices[0].Delta.Content)
+
+		_, err = stream.Recv()
+		require.NotErrorIs(t, err, io.EOF)
+		var apiErr *openai.APIError
+		require.ErrorAs(t, err, &apiErr)
+		require.Equal(t, "model overloaded", apiErr.Message)
+	})
+
+	t.Run("top k", func(t *testing.T) {
+		require.Equal(t, map[string]any{"top_k": 40}, togetherBodyFields(&Config{TopK: 40}))
+		require.Empty(t, togetherBodyFields(&Config{}))
+	})
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/charmbracelet/mods"
+
+// tracingShutdownTimeout is how long mods waits for the last traces to be
+// sent before it exits.
+const tracingShutdownTimeout = 5 * time.Second
+
+// otlpEndpoint returns where to send traces: otlp-endpoint, or else the
+// standard $OTEL_EXPORTER_OTLP_ENDPOINT.
+func otlpEndpoint(cfg *Config) string {
+	if cfg.OTLPEndpoint != "" {
+		return cfg.OTLPEndpoint
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+}
+
+// setupTracing sends traces to the given OTLP/HTTP endpoint, and returns a
+// function that flushes them.
+func setupTracing(ctx context.Context, endpoint string) (func(), error) {
+	exp, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, modsError{err, "Couldn't set up tracing."}
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName("mods"))),
+	)
+	otel.SetTracerProvider(tp)
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+		_ = tp.Shutdown(ctx)
+	}, nil
+}
+
+// tracer returns the tracer of the current provider, which is a no-op one
+// unless tracing was set up.
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// requestContext returns the context requests are made in.
+func (m *Mods) requestContext() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
+}
+
+// startStreamSpan starts the llm.stream span, and sets what is now known
+// about the request on the mods.request one.
+func (m *Mods) startStreamSpan(mod Model, content string) {
+	m.endStreamSpan(nil)
+	ctx := m.requestContext()
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("llm.api", mod.API),
+		attribute.String("llm.model", mod.Name),
+		attribute.Float64("llm.temperature", float64(m.Config.Temperature)),
+		attribute.Int("llm.input_chars", len(content)),
+	)
+	m.streamCtx, m.streamSpan = tracer().Start(ctx, "llm.stream")
+}
+
+// endStreamSpan ends the llm.stream span, if one is running, recording err
+// on it.
+func (m *Mods) endStreamSpan(err error) {
+	if m.streamSpan == nil {
+		return
+	}
+	if err != nil {
+		m.streamSpan.RecordError(err)
+		m.streamSpan.SetStatus(codes.Error, err.Error())
+	}
+	m.streamSpan.End()
+	m.streamSpan = nil
+	m.streamCtx = nil
+}
+
+// streamContext returns the context of the llm.stream span, for the
+// providers to make their requests in.
+func (m *Mods) streamContext() context.Context {
+	if m.streamCtx == nil {
+		return m.requestContext()
+	}
+	return m.streamCtx
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestOTLPEndpoint(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318")
+	require.Equal(t, "http://collector:4318", otlpEndpoint(&Config{}))
+	require.Equal(t, "http://localhost:4318", otlpEndpoint(&Config{OTLPEndpoint: "http://localhost:4318"}))
+}
+
+func TestTracing(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	old := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp)))
+	t.Cleanup(func() { otel.SetTracerProvider(old) })
+
+	status := http.StatusOK
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			_, _ = fmt.Fprint(w, `{"error":{"message":"invalid key","type":"invalid_request_error"}}`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hi\"}}]}\n\n")
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(ts.Close)
+
+	run := func() *modsError {
+		cfg := &Config{
+			Model:       "gpt-4o",
+			Quiet:       true,
+			MaxRetries:  1,
+			Temperature: 0.5,
+			NoCache:     true,
+			Models: map[string]Model{
+				"gpt-4o": {Name: "gpt-4o", API: "openai", MaxChars: 1000},
+			},
+			APIs: APIs{
+				{Name: "openai", BaseURL: ts.URL, APIKey: "fake"},
+			},
+		}
+		mods := newMods(lipgloss.DefaultRenderer(), cfg, nil, nil)
+		ctx, span := tracer().Start(context.Background(), "mods.request")
+		defer span.End()
+		mods.ctx = ctx
+		return mods.runHeadless("hello")
+	}
+
+	t.Run("spans", func(t *testing.T) {
+		exp.Reset()
+		require.Nil(t, run())
+
+		spans := exp.GetSpans()
+		require.Len(t, spans, 2)
+		stream, root := spans[0], spans[1]
+		require.Equal(t, "llm.stream", stream.Name)
+		require.Equal(t, "mods.request", root.Name)
+		require.Equal(t, root.SpanContext.SpanID(), stream.Parent.SpanID())
+		require.Equal(t, codes.Unset, stream.Status.Code)
+		require.ElementsMatch(t, []attribute.KeyValue{
+			attribute.String("llm.api", "openai"),
+			attribute.String("llm.model", "gpt-4o"),
+			attribute.Float64("llm.temperature", 0.5),
+			attribute.Int("llm.input_chars", len("hello")),
+		}, root.Attributes)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		exp.Reset()
+		status = http.StatusUnauthorized
+		require.NotNil(t, run())
+
+		spans := exp.GetSpans()
+		require.Len(t, spans, 2)
+		stream := spans[0]
+		require.Equal(t, "llm.stream", stream.Name)
+		require.Equal(t, codes.Error, stream.Status.Code)
+		require.Len(t, stream.Events, 1)
+		require.Equal(t, "exception", stream.Events[0].Name)
+	})
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Truncation strategies, used when the prompt is over the input limit of the
+// model.
+const (
+	truncateTail      = "tail"
+	truncateMiddle    = "middle"
+	truncateSummarize = "summarize"
+)
+
+var truncationStrategies = []string{truncateTail, truncateMiddle, truncateSummarize}
+
+// truncationMarker replaces the text cut out by the middle strategy.
+const truncationMarker = "\n[...]\n"
+
+// truncateInput cuts prompt down to maxChars. The tail strategy drops the end
+// of the prompt, while middle keeps both its start and its end. Summarizing
+// needs the model, so here it cuts like tail; see Mods.truncate.
+func truncateInput(strategy, prompt string, maxChars int) string {
+	if maxChars < 0 || len(prompt) <= maxChars {
+		return prompt
+	}
+	if strategy == truncateMiddle && maxChars > len(truncationMarker) {
+		keep := maxChars - len(truncationMarker)
+		head := keep / 2 //nolint:mnd
+		return prompt[:head] + truncationMarker + prompt[len(prompt)-(keep-head):]
+	}
+	return prompt[:maxChars]
+}
+
+// truncate cuts content down to maxChars with the configured strategy. When
+// summarizing, it asks the model for a summary that fits, and falls back to
+// cutting the tail if that fails or on dry runs.
+func (m *Mods) truncate(content string, maxChars int) string {
+	if len(content) <= maxChars {
+		return content
+	}
+	if m.Config.TruncationStrategy != truncateSummarize || m.Config.DryRun {
+		return truncateInput(m.Config.TruncationStrategy, content, maxChars)
+	}
+	summary, err := m.summarize(content, maxChars)
+	if err != nil || len(summary) > maxChars {
+		return truncateInput(truncateTail, content, maxChars)
+	}
+	return summary
+}
+
+// summarize asks the same model for a summary of content, short enough to
+// fit in maxChars.
+func (m *Mods) summarize(content string, maxChars int) (string, error) {
+	cfg := *m.Config
+	// words are roughly 6 characters long, asking for maxChars/8 of them
+	// leaves some room to spare.
+	cfg.System = fmt.Sprintf("Summarize the following conversation in under %d words.", maxChars/8) //nolint:mnd
+	cfg.TruncationStrategy = truncateTail
+	cfg.Role = ""
+	cfg.Prefix = ""
+	cfg.Format = false
+	cfg.Times = 0
+	cfg.Parallel = nil
+	cfg.NoCache = true
+	cfg.cacheReadFromID = ""
+	cfg.cacheWriteToID = ""
+
+	sub := newMods(m.renderer, &cfg, m.db, m.cache)
+	if err := sub.runHeadless(content); err != nil {
+		return "", err
+	}
+	summary := strings.TrimSpace(sub.Output)
+	if summary == "" {
+		return "", errors.New("empty summary")
+	}
+	return summary, nil
+}
+
+// validateTruncationStrategy makes sure the configured truncation strategy
+// is one we know. Empty means tail.
+func validateTruncationStrategy(strategy string) error {
+	if strategy == "" {
+		return nil
+	}
+	for _, s := range truncationStrategies {
+		if strategy == s {
+			return nil
+		}
+	}
+	return modsError{
+		err: newUserErrorf(
+			"Use one of %s.",
+			strings.Join(truncationStrategies, ", "),
+		),
+		reason: fmt.Sprintf("Invalid truncation strategy %q.", strategy),
+	}
+}
+
+// truncateMessages drops the oldest turns of a conversation until it fits in
+// maxChars. System messages, the last keepLast user/assistant pairs and the
+// new prompt are always kept, so the result may still be over maxChars.
+func truncateMessages(messages []openai.ChatCompletionMessage, maxChars, keepLast int) []openai.ChatCompletionMessage {
+	total := 0
+	for _, msg := range messages {
+		total += messageChars(msg)
+	}
+	if maxChars <= 0 || total <= maxChars {
+		return messages
+	}
+
+	// the turns kept no matter what, counting back from the last message.
+	keep := make([]bool, len(messages))
+	for i, turns := len(messages)-1, 0; i >= 0 && turns <= keepLast*2; i-- {
+		if messages[i].Role != openai.ChatMessageRoleSystem {
+			keep[i] = true
+			turns++
+		}
+	}
+
+	result := make([]openai.ChatCompletionMessage, 0, len(messages))
+	for i, msg := range messages {
+		if total > maxChars && !keep[i] && msg.Role != openai.ChatMessageRoleSystem {
+			total -= messageChars(msg)
+			continue
+		}
+		result = append(result, msg)
+	}
+	return result
+}
+
+// messageChars returns the length of the text of a message.
+func messageChars(msg openai.ChatCompletionMessage) int {
+	n := len(msg.Content)
+	for _, part := range msg.MultiContent {
+		n += len(part.Text)
+	}
+	return n
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncateInput(t *testing.T) {
+	prompt := "the start of the prompt, some filler, and the end"
+	for name, tc := range map[string]struct {
+		strategy string
+		maxChars int
+		expected string
+	}{
+		"fits":         {truncateMiddle, 100, prompt},
+		"tail":         {truncateTail, 9, "the start"},
+		"default":      {"", 9, "the start"},
+		"middle":       {truncateMiddle, 23, "the star\n[...]\n the end"},
+		"middle small": {truncateMiddle, 5, "the s"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			out := truncateInput(tc.strategy, prompt, tc.maxChars)
+			require.Equal(t, tc.expected, out)
+			require.LessOrEqual(t, len(out), max(tc.maxChars, len(prompt)))
+		})
+	}
+}
+
+func TestValidateTruncationStrategy(t *testing.T) {
+	for _, s := range append(truncationStrategies, "") {
+		require.NoError(t, validateTruncationStrategy(s))
+	}
+	require.Error(t, validateTruncationStrategy("head"))
+}
+
+func TestTruncateSummarize(t *testing.T) {
+	var system string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		system = req.Messages[0].Content
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"a short summary\"}}]}\n\n")
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(ts.Close)
+
+	cfg := Config{
+		Model:              "model",
+		Quiet:              true,
+		MaxRetries:         1,
+		NoCache:            true,
+		TruncationStrategy: truncateSummarize,
+		Models: map[string]Model{
+			"model": {Name: "model", API: "openai", MaxChars: 1000},
+		},
+		APIs: APIs{
+			{Name: "openai", BaseURL: ts.URL, APIKey: "fake"},
+		},
+	}
+	mods := newMods(lipgloss.DefaultRenderer(), &cfg, nil, nil)
+
+	content := strings.Repeat("a long conversation ", 10)
+	require.Equal(t, "a short summary", mods.truncate(content, 80))
+	require.Equal(t, "Summarize the following conversation in under 10 words.", system)
+
+	t.Run("dry run", func(t *testing.T) {
+		cfg.DryRun = true
+		t.Cleanup(func() { cfg.DryRun = false })
+		require.Equal(t, content[:80], mods.truncate(content, 80))
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		testBreaker(t)
+		cfg.APIs[0].BaseURL = "http://127.0.0.1:0"
+		require.Equal(t, content[:80], mods.truncate(content, 80))
+	})
+}
+
+func TestTruncateMessages(t *testing.T) {
+	msg := func(role, content string) openai.ChatCompletionMessage {
+		return openai.ChatCompletionMessage{Role: role, Content: content}
+	}
+	system := msg(openai.ChatMessageRoleSystem, "be nice")
+	conversation := []openai.ChatCompletionMessage{
+		system,
+		msg(openai.ChatMessageRoleUser, "first question"),
+		msg(openai.ChatMessageRoleAssistant, "first answer"),
+		msg(openai.ChatMessageRoleUser, "second question"),
+		msg(openai.ChatMessageRoleAssistant, "second answer"),
+		msg(openai.ChatMessageRoleUser, "third question"),
+	}
+
+	t.Run("fits", func(t *testing.T) {
+		require.Equal(t, conversation, truncateMessages(conversation, 1000, 1))
+	})
+
+	t.Run("no limit", func(t *testing.T) {
+		require.Equal(t, conversation, truncateMessages(conversation, 0, 1))
+	})
+
+	t.Run("drops oldest turns", func(t *testing.T) {
+		require.Equal(t, []openai.ChatCompletionMessage{
+			system,
+			msg(openai.ChatMessageRoleAssistant, "first answer"),
+			msg(openai.ChatMessageRoleUser, "second question"),
+			msg(openai.ChatMessageRoleAssistant, "second answer"),
+			msg(openai.ChatMessageRoleUser, "third question"),
+		}, truncateMessages(conversation, 70, 1))
+	})
+
+	t.Run("keeps the last pairs", func(t *testing.T) {
+		require.Equal(t, []openai.ChatCompletionMessage{
+			system,
+			msg(openai.ChatMessageRoleUser, "second question"),
+			msg(openai.ChatMessageRoleAssistant, "second answer"),
+			msg(openai.ChatMessageRoleUser, "third question"),
+		}, truncateMessages(conversation, 10, 1))
+	})
+
+	t.Run("keeps the prompt", func(t *testing.T) {
+		require.Equal(t, []openai.ChatCompletionMessage{
+			system,
+			msg(openai.ChatMessageRoleUser, "third question"),
+		}, truncateMessages(conversation, 10, 0))
+	})
+
+	t.Run("keeps more pairs than there are", func(t *testing.T) {
+		require.Equal(t, conversation, truncateMessages(conversation, 10, 5))
+	})
+
+	t.Run("counts multi content", func(t *testing.T) {
+		require.Equal(t, 11, messageChars(openai.ChatCompletionMessage{
+			MultiContent: []openai.ChatMessagePart{
+				{Type: openai.ChatMessagePartTypeText, Text: "hello world"},
+				{Type: openai.ChatMessagePartTypeImageURL, ImageURL: &openai.ChatMessageImageURL{URL: "data:"}},
+			},
+		}))
+	})
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// latestReleaseURL is where the latest release of mods is described.
+const latestReleaseURL = "https://api.github.com/repos/charmbracelet/mods/releases/latest"
+
+// updateTimeout is how long the update check and download may take.
+const updateTimeout = 5 * time.Minute
+
+// maxUpdateSize is the largest release archive we are willing to download.
+const maxUpdateSize = 200 * 1024 * 1024
+
+const checksumsName = "checksums.txt"
+
+var errNoVersion = errors.New("this build of mods has no version to compare")
+
+type githubRelease struct {
+	TagName string        `json:"tag_name"`
+	Body    string        `json:"body"`
+	Assets  []githubAsset `json:"assets"`
+}
+
+type githubAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+func (r githubRelease) asset(name string) (githubAsset, bool) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return githubAsset{}, false
+}
+
+// selfUpdate replaces the executable at exe with the latest release, if it
+// is newer than current, printing its release notes to w. With checkOnly,
+// it only tells whether there is a newer one.
+func selfUpdate(w io.Writer, client *http.Client, releaseURL, current, exe string, checkOnly bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), updateTimeout)
+	defer cancel()
+
+	bts, err := download(ctx, client, releaseURL)
+	if err != nil {
+		return err
+	}
+	var release githubRelease
+	if err := json.Unmarshal(bts, &release); err != nil {
+		return fmt.Errorf("selfUpdate: %w", err)
+	}
+
+	newer, err := isNewerVersion(current, release.TagName)
+	if err != nil {
+		return err
+	}
+	if !newer {
+		fmt.Fprintf(w, "mods %s is the latest version.\n", current)
+		return nil
+	}
+	fmt.Fprintf(w, "mods %s is available (you have %s).\n", release.TagName, current)
+	if notes := strings.TrimSpace(release.Body); notes != "" {
+		fmt.Fprintf(w, "\n%s\n\n", notes)
+	}
+	if checkOnly {
+		return nil
+	}
+
+	name := releaseArchiveName(release.TagName, runtime.GOOS, runtime.GOARCH)
+	archive, ok := release.asset(name)
+	if !ok {
+		return fmt.Errorf("selfUpdate: no release for %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	sums, ok := release.asset(checksumsName)
+	if !ok {
+		return fmt.Errorf("selfUpdate: release has no %s", checksumsName)
+	}
+
+	checksums, err := download(ctx, client, sums.URL)
+	if err != nil {
+		return err
+	}
+	bts, err = download(ctx, client, archive.URL)
+	if err != nil {
+		return err
+	}
+	if err := verifyChecksum(bts, name, checksums); err != nil {
+		return err
+	}
+	bin, err := extractBinary(bts, name, binaryName(runtime.GOOS))
+	if err != nil {
+		return err
+	}
+	if err := replaceBinary(exe, bin, runtime.GOOS); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Updated mods to %s.\n", release.TagName)
+	return nil
+}
+
+func download(ctx context.Context, client *http.Client, u string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("download: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("download: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download: %s: %s", u, resp.Status)
+	}
+	bts, err := io.ReadAll(io.LimitReader(resp.Body, maxUpdateSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("download: %w", err)
+	}
+	if len(bts) > maxUpdateSize {
+		return nil, fmt.Errorf("download: %s is larger than %d bytes", u, maxUpdateSize)
+	}
+	return bts, nil
+}
+
+// isNewerVersion tells whether latest is a later version than current, both
+// like v1.2.3 or v1.2.3-rc1.
+func isNewerVersion(current, latest string) (bool, error) {
+	cur, ok := parseVersion(current)
+	if !ok {
+		return false, errNoVersion
+	}
+	lat, ok := parseVersion(latest)
+	if !ok {
+		return false, fmt.Errorf("isNewerVersion: invalid release version %q", latest)
+	}
+	for i := range cur {
+		if lat[i] != cur[i] {
+			return lat[i] > cur[i], nil
+		}
+	}
+	// a release is newer than its release candidates.
+	return strings.Contains(current, "-") && !strings.Contains(latest, "-"), nil
+}
+
+func parseVersion(v string) ([3]int, bool) {
+	var parts [3]int
+	v, _, _ = strings.Cut(strings.TrimPrefix(v, "v"), "-")
+	fields := strings.Split(v, ".")
+	if len(fields) != len(parts) {
+		return parts, false
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+// releaseArchiveName returns the name of the release archive for the given
+// platform, as named by the release process.
+func releaseArchiveName(tag, goos, goarch string) string {
+	arch := goarch
+	switch goarch {
+	case "amd64":
+		arch = "x86_64"
+	case "386":
+		arch = "i386"
+	case "arm":
+		arch = "armv7"
+	}
+	ext := ".tar.gz"
+	if goos == "windows" {
+		ext = ".zip"
+	}
+	return fmt.Sprintf(
+		"mods_%s_%s_%s%s",
+		strings.TrimPrefix(tag, "v"),
+		strings.ToUpper(goos[:1])+goos[1:],
+		arch,
+		ext,
+	)
+}
+
+func binaryName(goos string) string {
+	if goos == "windows" {
+		return "mods.exe"
+	}
+	return "mods"
+}
+
+// verifyChecksum checks bts against the SHA256 of name in checksums, in the
+// format of sha256sum.
+func verifyChecksum(bts []byte, name string, checksums []byte) error {
+	sum := sha256.Sum256(bts)
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || fields[1] != name { //nolint:mnd
+			continue
+		}
+		if fields[0] != hex.EncodeToString(sum[:]) {
+			return fmt.Errorf("verifyChecksum: %s does not match its checksum", name)
+		}
+		return nil
+	}
+	return fmt.Errorf("verifyChecksum: no checksum for %s", name)
+}
+
+// extractBinary returns the content of the file called bin in the given
+// .tar.gz or .zip archive.
+func extractBinary(archive []byte, name, bin string) ([]byte, error) {
+	if strings.HasSuffix(name, ".zip") {
+		zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+		if err != nil {
+			return nil, fmt.Errorf("extractBinary: %w", err)
+		}
+		for _, f := range zr.File {
+			if path.Base(f.Name) != bin {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, fmt.Errorf("extractBinary: %w", err)
+			}
+			defer rc.Close() //nolint:errcheck
+			return readBinary(rc)
+		}
+		return nil, fmt.Errorf("extractBinary: no %s in %s", bin, name)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, fmt.Errorf("extractBinary: %w", err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("extractBinary: no %s in %s", bin, name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("extractBinary: %w", err)
+		}
+		if hdr.Typeflag == tar.TypeReg && path.Base(hdr.Name) == bin {
+			return readBinary(tr)
+		}
+	}
+}
+
+func readBinary(r io.Reader) ([]byte, error) {
+	bts, err := io.ReadAll(io.LimitReader(r, maxUpdateSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("extractBinary: %w", err)
+	}
+	if len(bts) > maxUpdateSize {
+		return nil, fmt.Errorf("extractBinary: binary is larger than %d bytes", maxUpdateSize)
+	}
+	return bts, nil
+}
+
+// replaceBinary puts bin in place of exe, keeping the old one as exe.bak.
+// Windows does not let a running program be replaced, so there a script
+// does it once mods has exited.
+func replaceBinary(exe string, bin []byte, goos string) error {
+	newPath := exe + ".new"
+	if err := os.WriteFile(newPath, bin, 0o755); err != nil { //nolint:gosec,mnd
+		return fmt.Errorf("replaceBinary: %w", err)
+	}
+	if goos == "windows" {
+		return replaceBinaryLater(exe, newPath)
+	}
+	if err := os.Rename(exe, exe+".bak"); err != nil {
+		_ = os.Remove(newPath)
+		return fmt.Errorf("replaceBinary: %w", err)
+	}
+	if err := os.Rename(newPath, exe); err != nil {
+		// put the old one back.
+		_ = os.Rename(exe+".bak", exe)
+		_ = os.Remove(newPath)
+		return fmt.Errorf("replaceBinary: %w", err)
+	}
+	return nil
+}
+
+// replaceBinaryLater starts a batch script that waits for mods to exit
+// before moving newPath over exe.
+func replaceBinaryLater(exe, newPath string) error {
+	script := filepath.Join(filepath.Dir(exe), "mods-update.bat")
+	content := fmt.Sprintf(
+		"@echo off\r\n"+
+			":wait\r\n"+
+			"timeout /t 1 /nobreak >nul\r\n"+
+			"move /y \"%[1]s\" \"%[1]s.bak\" >nul 2>&1 || goto wait\r\n"+
+			"move /y \"%[2]s\" \"%[1]s\" >nul\r\n"+
+			"del \"%%~f0\"\r\n",
+		exe,
+		newPath,
+	)
+	if err := os.WriteFile(script, []byte(content), 0o600); err != nil { //nolint:mnd
+		return fmt.Errorf("replaceBinary: %w", err)
+	}
+	cmd := exec.Command("cmd", "/C", "start", "/b", "", script)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("replaceBinary: %w", err)
+	}
+	return cmd.Process.Release() //nolint:wrapcheck
+}
+
+//nolint:mnd
+func isUpdateCmd(args []string) bool {
+	if len(args) < 2 || args[1] != "update" {
+		return false
+	}
+	for _, arg := range args[2:] {
+		if !strings.HasPrefix(arg, "-") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsNewerVersion(t *testing.T) {
+	for _, tc := range []struct {
+		current, latest string
+		newer           bool
+	}{
+		{"v1.6.0", "v1.7.0", true},
+		{"v1.7.0", "v1.7.0", false},
+		{"v1.10.0", "v1.9.9", false},
+		{"1.7.0", "v1.7.1", true},
+		{"v1.7.0-rc1", "v1.7.0", true},
+		{"v1.7.0", "v1.7.1-rc1", true},
+	} {
+		newer, err := isNewerVersion(tc.current, tc.latest)
+		require.NoError(t, err)
+		require.Equal(t, tc.newer, newer, "%s -> %s", tc.current, tc.latest)
+	}
+
+	_, err := isNewerVersion("unknown (built from source)", "v1.7.0")
+	require.ErrorIs(t, err, errNoVersion)
+	_, err = isNewerVersion("v1.7.0", "nightly")
+	require.Error(t, err)
+}
+
+func TestReleaseArchiveName(t *testing.T) {
+	require.Equal(t, "mods_1.7.0_Linux_x86_64.tar.gz", releaseArchiveName("v1.7.0", "linux", "amd64"))
+	require.Equal(t, "mods_1.7.0_Darwin_arm64.tar.gz", releaseArchiveName("v1.7.0", "darwin", "arm64"))
+	require.Equal(t, "mods_1.7.0_Windows_i386.zip", releaseArchiveName("v1.7.0", "windows", "386"))
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	sum := sha256.Sum256([]byte("archive"))
+	checksums := []byte(hex.EncodeToString(sum[:]) + "  mods_1.7.0_Linux_x86_64.tar.gz\n")
+	require.NoError(t, verifyChecksum([]byte("archive"), "mods_1.7.0_Linux_x86_64.tar.gz", checksums))
+	require.Error(t, verifyChecksum([]byte("tampered"), "mods_1.7.0_Linux_x86_64.tar.gz", checksums))
+	require.Error(t, verifyChecksum([]byte("archive"), "mods_1.7.0_Darwin_arm64.tar.gz", checksums))
+}
+
+func TestExtractBinary(t *testing.T) {
+	var tgz bytes.Buffer
+	gz := gzip.NewWriter(&tgz)
+	tw := tar.NewWriter(gz)
+	for name, content := range map[string]string{
+		"mods_1.7.0_Linux_x86_64/README.md": "readme",
+		"mods_1.7.0_Linux_x86_64/mods":      "new binary",
+	} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o755, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	bin, err := extractBinary(tgz.Bytes(), "mods_1.7.0_Linux_x86_64.tar.gz", "mods")
+	require.NoError(t, err)
+	require.Equal(t, "new binary", string(bin))
+
+	var zbuf bytes.Buffer
+	zw := zip.NewWriter(&zbuf)
+	f, err := zw.Create("mods_1.7.0_Windows_x86_64/mods.exe")
+	require.NoError(t, err)
+	_, err = f.Write([]byte("new exe"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	bin, err = extractBinary(zbuf.Bytes(), "mods_1.7.0_Windows_x86_64.zip", "mods.exe")
+	require.NoError(t, err)
+	require.Equal(t, "new exe", string(bin))
+
+	_, err = extractBinary(zbuf.Bytes(), "mods_1.7.0_Windows_x86_64.zip", "mods")
+	require.Error(t, err)
+}
+
+func TestSelfUpdate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("replaced by a script on Windows")
+	}
+
+	name := releaseArchiveName("v1.7.0", runtime.GOOS, runtime.GOARCH)
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "mods", Mode: 0o755, Size: 10, Typeflag: tar.TypeReg}))
+	_, err := tw.Write([]byte("new binary"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	sum := sha256.Sum256(archive.Bytes())
+
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest":
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"tag_name": "v1.7.0",
+				"body":     "- New things.",
+				"assets": []map[string]string{
+					{"name": name, "browser_download_url": ts.URL + "/archive"},
+					{"name": checksumsName, "browser_download_url": ts.URL + "/checksums"},
+				},
+			})
+		case "/archive":
+			_, _ = w.Write(archive.Bytes())
+		case "/checksums":
+			_, _ = fmt.Fprintf(w, "%x  %s\n", sum, name)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	exe := filepath.Join(t.TempDir(), "mods")
+	require.NoError(t, os.WriteFile(exe, []byte("old binary"), 0o755))
+
+	t.Run("latest", func(t *testing.T) {
+		var b strings.Builder
+		require.NoError(t, selfUpdate(&b, ts.Client(), ts.URL+"/latest", "v1.7.0", exe, false))
+		require.Equal(t, "mods v1.7.0 is the latest version.\n", b.String())
+	})
+
+	t.Run("check only", func(t *testing.T) {
+		var b strings.Builder
+		require.NoError(t, selfUpdate(&b, ts.Client(), ts.URL+"/latest", "v1.6.0", exe, true))
+		require.Equal(t, "mods v1.7.0 is available (you have v1.6.0).\n\n- New things.\n\n", b.String())
+		bts, err := os.ReadFile(exe)
+		require.NoError(t, err)
+		require.Equal(t, "old binary", string(bts))
+	})
+
+	t.Run("update", func(t *testing.T) {
+		var b strings.Builder
+		require.NoError(t, selfUpdate(&b, ts.Client(), ts.URL+"/latest", "v1.6.0", exe, false))
+		require.True(t, strings.HasSuffix(b.String(), "Updated mods to v1.7.0.\n"))
+		bts, err := os.ReadFile(exe)
+		require.NoError(t, err)
+		require.Equal(t, "new binary", string(bts))
+		bts, err = os.ReadFile(exe + ".bak")
+		require.NoError(t, err)
+		require.Equal(t, "old binary", string(bts))
+	})
+}
+
+func TestIsUpdateCmd(t *testing.T) {
+	require.True(t, isUpdateCmd([]string{"mods", "update"}))
+	require.True(t, isUpdateCmd([]string{"mods", "update", "--check-update"}))
+	require.False(t, isUpdateCmd([]string{"mods", "update", "the", "readme"}))
+	require.False(t, isUpdateCmd([]string{"mods"}))
+}
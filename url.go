@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// urlTimeout is how long we wait for each --url to be fetched.
+const urlTimeout = 30 * time.Second
+
+// maxURLSize is the largest page we are willing to read with --url.
+const maxURLSize = 10 * 1024 * 1024
+
+// fetchURLs fetches the given URLs, through proxy if set, and returns their
+// text content.
+func fetchURLs(urls []string, proxy string) (string, error) {
+	client := &http.Client{}
+	if proxy != "" {
+		var err error
+		if client, err = newProxyClient(proxy); err != nil {
+			return "", fmt.Errorf("fetchURLs: %w", err)
+		}
+	}
+	client.Timeout = urlTimeout
+	parts := make([]string, 0, len(urls))
+	for _, u := range urls {
+		text, err := fetchURL(client, u)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, text)
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
+func fetchURL(client *http.Client, u string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), urlTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", fmt.Errorf("fetchURL: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetchURL: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetchURL: %s: %s", u, resp.Status)
+	}
+
+	bts, err := io.ReadAll(io.LimitReader(resp.Body, maxURLSize+1))
+	if err != nil {
+		return "", fmt.Errorf("fetchURL: %w", err)
+	}
+	if len(bts) > maxURLSize {
+		return "", fmt.Errorf("fetchURL: %s is larger than %d bytes", u, maxURLSize)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(bts)
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("fetchURL: %w", err)
+	}
+	if !isTextMediaType(mediaType) {
+		return "", fmt.Errorf("fetchURL: %s has unsupported type %s", u, mediaType)
+	}
+
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return string(bts), nil
+	}
+	text, err := htmlText(bytes.NewReader(bts))
+	if err != nil {
+		return "", fmt.Errorf("fetchURL: %w", err)
+	}
+	return text, nil
+}
+
+// isTextMediaType reports whether the given media type is something we can
+// put in a prompt.
+func isTextMediaType(mediaType string) bool {
+	if strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "+json") ||
+		strings.HasSuffix(mediaType, "+xml") {
+		return true
+	}
+	switch mediaType {
+	case "application/json",
+		"application/xml",
+		"application/javascript",
+		"application/x-yaml",
+		"application/yaml",
+		"application/toml":
+		return true
+	}
+	return false
+}
+
+// htmlText returns the visible text of an HTML document, one line per text
+// node.
+func htmlText(r io.Reader) (string, error) {
+	var lines []string
+	skip := 0
+	z := html.NewTokenizer(r)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				return "", err //nolint:wrapcheck
+			}
+			return strings.Join(lines, "\n"), nil
+		case html.StartTagToken:
+			if name, _ := z.TagName(); isInvisibleTag(string(name)) {
+				skip++
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); isInvisibleTag(string(name)) && skip > 0 {
+				skip--
+			}
+		case html.TextToken:
+			if skip > 0 {
+				continue
+			}
+			if text := strings.Join(strings.Fields(string(z.Text())), " "); text != "" {
+				lines = append(lines, text)
+			}
+		}
+	}
+}
+
+func isInvisibleTag(name string) bool {
+	switch name {
+	case "script", "style", "noscript", "template", "head", "svg":
+		return true
+	}
+	return false
+}
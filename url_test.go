@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTMLText(t *testing.T) {
+	text, err := htmlText(strings.NewReader(`<!DOCTYPE html>
+<html>
+<head><title>Ignored</title><style>body { color: red }</style></head>
+<body>
+  <h1>Hello   world</h1>
+  <script>alert("nope")</script>
+  <p>Some <b>bold</b> text.</p>
+</body>
+</html>`))
+	require.NoError(t, err)
+	require.Equal(t, "Hello world\nSome\nbold\ntext.", text)
+}
+
+func TestFetchURLs(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write([]byte("<p>from html</p>"))
+		case "/plain":
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write([]byte("<p>kept as is</p>"))
+		case "/binary":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = w.Write([]byte{0, 1, 2})
+		case "/huge":
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write(bytes.Repeat([]byte("a"), maxURLSize+1))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	t.Run("ok", func(t *testing.T) {
+		text, err := fetchURLs([]string{ts.URL + "/page", ts.URL + "/plain"}, "")
+		require.NoError(t, err)
+		require.Equal(t, "from html\n\n<p>kept as is</p>", text)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := fetchURLs([]string{ts.URL + "/page", ts.URL + "/missing"}, "")
+		require.ErrorContains(t, err, "404")
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		_, err := fetchURLs([]string{ts.URL + "/binary"}, "")
+		require.ErrorContains(t, err, "unsupported type application/octet-stream")
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		_, err := fetchURLs([]string{ts.URL + "/huge"}, "")
+		require.ErrorContains(t, err, "larger than")
+	})
+
+	t.Run("proxy", func(t *testing.T) {
+		var proxied string
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			proxied = r.URL.String()
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write([]byte("from the proxy"))
+		}))
+		t.Cleanup(proxy.Close)
+
+		text, err := fetchURLs([]string{"http://mods.invalid/page"}, proxy.URL)
+		require.NoError(t, err)
+		require.Equal(t, "from the proxy", text)
+		require.Equal(t, "http://mods.invalid/page", proxied)
+	})
+}
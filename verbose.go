@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// printVerbose prints what --verbose shows about the request to w.
+func printVerbose(w io.Writer, mods *Mods) {
+	messages := mods.messages
+	if n := len(messages); n > 0 && messages[n-1].Role == openai.ChatMessageRoleAssistant {
+		// leave the response out.
+		messages = messages[:n-1]
+	}
+
+	styles := stderrStyles()
+	line := func(label string, value any) {
+		fmt.Fprintf(w, "%s %v\n", styles.FlagDesc.Render(label), value)
+	}
+
+	fmt.Fprintln(w)
+	line("API:", mods.model.API)
+	line("Model:", mods.model.Name)
+	line("Messages:", len(messages))
+	line("Prompt size:", fmt.Sprintf("~%d tokens", estimateTokens(messagesText(messages))))
+	if !mods.firstTokenAt.IsZero() {
+		line("Time to first token:", mods.firstTokenAt.Sub(mods.startedAt).Round(time.Millisecond))
+	}
+	if !mods.finishedAt.IsZero() {
+		line("Total time:", mods.finishedAt.Sub(mods.startedAt).Round(time.Millisecond))
+	}
+	if hasCost(mods.model) && !mods.finishedAt.IsZero() {
+		line("Cost:", fmt.Sprintf("$%.4f", mods.cost))
+	}
+	if mods.Config != nil && mods.Config.PromptCaching && mods.model.API == "anthropic" && !mods.finishedAt.IsZero() {
+		hit := "miss"
+		if mods.cacheHit {
+			hit = "hit"
+		}
+		line("Prompt cache:", hit)
+	}
+}
+
+// printTokenUsage prints the tokens the request used, as the API returned
+// them, or estimated, and marked with a ~, when it did not.
+func printTokenUsage(w io.Writer, mods *Mods) {
+	if mods.finishedAt.IsZero() {
+		return
+	}
+	input, output := mods.usage()
+	approx := ""
+	if mods.promptTokens == 0 && mods.completionTokens == 0 {
+		approx = "~"
+	}
+	fmt.Fprintf(
+		w,
+		"%s %s%dp / %s%dc / %s%dt\n",
+		stderrStyles().FlagDesc.Render("Tokens:"),
+		approx, input,
+		approx, output,
+		approx, input+output,
+	)
+}
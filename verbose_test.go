@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintVerbose(t *testing.T) {
+	start := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	mods := &Mods{
+		model: Model{Name: "gpt-4o", API: "openai"},
+		messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: "be brief"},
+			{Role: openai.ChatMessageRoleUser, Content: "what is the answer?"},
+			{Role: openai.ChatMessageRoleAssistant, Content: "42"},
+		},
+		startedAt:    start,
+		firstTokenAt: start.Add(300 * time.Millisecond),
+		finishedAt:   start.Add(2 * time.Second),
+	}
+
+	var b bytes.Buffer
+	printVerbose(&b, mods)
+	out := b.String()
+	require.Contains(t, out, "API: openai")
+	require.Contains(t, out, "Model: gpt-4o")
+	require.Contains(t, out, "Messages: 2")
+	require.Contains(t, out, "Prompt size: ~7 tokens")
+	require.Contains(t, out, "Time to first token: 300ms")
+	require.Contains(t, out, "Total time: 2s")
+	require.NotContains(t, out, "Prompt cache")
+
+	t.Run("prompt caching", func(t *testing.T) {
+		var b bytes.Buffer
+		printVerbose(&b, &Mods{
+			Config:     &Config{PromptCaching: true},
+			model:      Model{Name: "claude-3-5-sonnet-latest", API: "anthropic"},
+			cacheHit:   true,
+			startedAt:  start,
+			finishedAt: start.Add(time.Second),
+		})
+		require.Contains(t, b.String(), "Prompt cache: hit")
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		var b bytes.Buffer
+		printVerbose(&b, &Mods{model: mods.model, messages: mods.messages[:2]})
+		require.Contains(t, b.String(), "Messages: 2")
+		require.NotContains(t, b.String(), "Total time")
+	})
+}
+
+func TestPrintTokenUsage(t *testing.T) {
+	mods := &Mods{
+		messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleUser, Content: strings.Repeat("a", 40)},
+			{Role: openai.ChatMessageRoleAssistant, Content: strings.Repeat("b", 20)},
+		},
+		Output: strings.Repeat("b", 20),
+	}
+
+	var b strings.Builder
+	printTokenUsage(&b, mods)
+	require.Empty(t, b.String(), "nothing before the request finished")
+
+	mods.finishedAt = time.Now()
+	printTokenUsage(&b, mods)
+	require.Equal(t, "Tokens: ~10p / ~5c / ~15t\n", b.String())
+
+	b.Reset()
+	mods.promptTokens, mods.completionTokens = 12, 34
+	printTokenUsage(&b, mods)
+	require.Equal(t, "Tokens: 12p / 34c / 46t\n", b.String())
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"runtime"
+	"runtime/debug"
+)
+
+// versionInfo is what --version-json prints.
+type versionInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuiltWith string `json:"built_with"`
+	GOOS      string `json:"goos"`
+	GOARCH    string `json:"goarch"`
+	Major     *int   `json:"major,omitempty"`
+	Minor     *int   `json:"minor,omitempty"`
+	Patch     *int   `json:"patch,omitempty"`
+}
+
+// newVersionInfo describes the build, taking the commit from the build info
+// when it wasn't set at link time.
+func newVersionInfo(version, commit string, info *debug.BuildInfo) versionInfo {
+	v := versionInfo{
+		Version:   version,
+		Commit:    commit,
+		BuiltWith: runtime.Version(),
+		GOOS:      runtime.GOOS,
+		GOARCH:    runtime.GOARCH,
+	}
+	if info != nil {
+		v.BuiltWith = info.GoVersion
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if v.Commit == "" {
+					v.Commit = s.Value
+				}
+			case "GOOS":
+				v.GOOS = s.Value
+			case "GOARCH":
+				v.GOARCH = s.Value
+			}
+		}
+	}
+	if parts, ok := parseVersion(version); ok {
+		v.Major, v.Minor, v.Patch = &parts[0], &parts[1], &parts[2]
+	}
+	return v
+}
+
+func printVersionJSON(w io.Writer) error {
+	info, _ := debug.ReadBuildInfo()
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(newVersionInfo(Version, CommitSHA, info)); err != nil {
+		return modsError{err, "Couldn't print the version."}
+	}
+	return nil
+}
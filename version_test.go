@@ -0,0 +1,43 @@
+package main
+
+import (
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewVersionInfo(t *testing.T) {
+	info := &debug.BuildInfo{
+		GoVersion: "go1.23.4",
+		Settings: []debug.BuildSetting{
+			{Key: "GOOS", Value: "darwin"},
+			{Key: "GOARCH", Value: "arm64"},
+			{Key: "vcs.revision", Value: "abc123"},
+		},
+	}
+
+	t.Run("semver", func(t *testing.T) {
+		v := newVersionInfo("v1.2.3", "", info)
+		require.Equal(t, "abc123", v.Commit)
+		require.Equal(t, "go1.23.4", v.BuiltWith)
+		require.Equal(t, "darwin", v.GOOS)
+		require.Equal(t, "arm64", v.GOARCH)
+		require.Equal(t, 1, *v.Major)
+		require.Equal(t, 2, *v.Minor)
+		require.Equal(t, 3, *v.Patch)
+	})
+
+	t.Run("link time commit", func(t *testing.T) {
+		v := newVersionInfo("v1.2.3", "def456", info)
+		require.Equal(t, "def456", v.Commit)
+	})
+
+	t.Run("not semver", func(t *testing.T) {
+		v := newVersionInfo("unknown (built from source)", "", nil)
+		require.Nil(t, v.Major)
+		require.Nil(t, v.Minor)
+		require.Nil(t, v.Patch)
+		require.NotEmpty(t, v.BuiltWith)
+	})
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	defaultVertexAILocation = "us-central1"
+	vertexAIExpressURL      = "https://aiplatform.googleapis.com"
+)
+
+// DefaultVertexAIConfig returns the configuration for the Vertex AI API,
+// authenticated with an OAuth2 access token.
+func DefaultVertexAIConfig(baseURL, project, location, model, token string) GoogleClientConfig {
+	if location == "" {
+		location = defaultVertexAILocation
+	}
+	if baseURL == "" {
+		baseURL = fmt.Sprintf("https://%s-aiplatform.googleapis.com", location)
+	}
+	return GoogleClientConfig{
+		BaseURL: fmt.Sprintf(
+			"%s/v1/projects/%s/locations/%s/publishers/google/models/%s:streamGenerateContent?alt=sse",
+			strings.TrimSuffix(baseURL, "/"), project, location, model,
+		),
+		AuthToken:          token,
+		HTTPClient:         &http.Client{},
+		EmptyMessagesLimit: defaultEmptyMessagesLimit,
+	}
+}
+
+// DefaultVertexAIKeyConfig returns the configuration for the Vertex AI API,
+// authenticated with an API key.
+func DefaultVertexAIKeyConfig(baseURL, model, key string) GoogleClientConfig {
+	if baseURL == "" {
+		baseURL = vertexAIExpressURL
+	}
+	return GoogleClientConfig{
+		BaseURL: fmt.Sprintf(
+			"%s/v1/publishers/google/models/%s:streamGenerateContent?alt=sse&key=%s",
+			strings.TrimSuffix(baseURL, "/"), model, key,
+		),
+		HTTPClient:         &http.Client{},
+		EmptyMessagesLimit: defaultEmptyMessagesLimit,
+	}
+}
+
+// hasAPIKey reports whether the API has an API key explicitly configured.
+func hasAPIKey(api API) bool {
+	return api.APIKey != "" || api.APIKeyEnv != "" || api.APIKeyCmd != "" || api.OPItem != "" ||
+		api.APIKeySecretARN != ""
+}
+
+// vertexAIProject returns the Google Cloud project to use.
+func vertexAIProject(api API) string {
+	if api.Project != "" {
+		return api.Project
+	}
+	return os.Getenv("GOOGLE_CLOUD_PROJECT")
+}
+
+// vertexAIToken gets an access token from the Application Default
+// Credentials, as set up by `gcloud auth application-default login`.
+func vertexAIToken() (string, error) {
+	out, err := exec.Command("gcloud", "auth", "application-default", "print-access-token").Output()
+	if err != nil {
+		return "", fmt.Errorf("vertexAIToken: %w", err)
+	}
+	token := strings.TrimSpace(string(out))
+	if token == "" {
+		return "", errors.New("vertexAIToken: empty access token")
+	}
+	return token, nil
+}
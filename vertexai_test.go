@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVertexAIConfig(t *testing.T) {
+	t.Run("access token", func(t *testing.T) {
+		var auth, path string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth = r.Header.Get("Authorization")
+			path = r.URL.String()
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = io.WriteString(w, `data: {"candidates":[{"content":{"parts":[{"text":"hi"}],"role":"model"}}]}`+"\n\n")
+		}))
+		t.Cleanup(ts.Close)
+
+		cfg := DefaultVertexAIConfig(ts.URL, "my-project", "", "gemini-1.5-pro-002", "ya29.token")
+		stream, err := NewGoogleClientWithConfig(cfg).CreateChatCompletionStream(context.Background(), GoogleMessageCompletionRequest{})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = stream.Close() })
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, "hi", resp.Choices[0].Delta.Content)
+
+		require.Equal(t, "Bearer ya29.token", auth)
+		require.Equal(t, "/v1/projects/my-project/locations/us-central1/publishers/google/models/gemini-1.5-pro-002:streamGenerateContent?alt=sse", path)
+	})
+
+	t.Run("default host", func(t *testing.T) {
+		cfg := DefaultVertexAIConfig("", "my-project", "europe-west4", "gemini-1.5-pro-002", "ya29.token")
+		require.Equal(t, "https://europe-west4-aiplatform.googleapis.com/v1/projects/my-project/locations/europe-west4/publishers/google/models/gemini-1.5-pro-002:streamGenerateContent?alt=sse", cfg.BaseURL)
+	})
+
+	t.Run("api key", func(t *testing.T) {
+		cfg := DefaultVertexAIKeyConfig("", "gemini-1.5-pro-002", "the-key")
+		require.Equal(t, "https://aiplatform.googleapis.com/v1/publishers/google/models/gemini-1.5-pro-002:streamGenerateContent?alt=sse&key=the-key", cfg.BaseURL)
+		require.Empty(t, cfg.AuthToken)
+		require.True(t, hasAPIKey(API{APIKeyEnv: "VERTEX_KEY"}))
+		require.False(t, hasAPIKey(API{}))
+	})
+}
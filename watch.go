@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/glamour"
+)
+
+// watchStdinPath is reopened by --watch to wait for new input.
+var watchStdinPath = "/dev/stdin"
+
+// watch runs the prompt again each time new input comes in, or every
+// --watch-interval, replacing the previous response. The first response is
+// the one in mods, which was already printed.
+func watch(w io.Writer, mods *Mods, printed string) error {
+	tty := isOutputTTY() && !config.Raw
+	// saving numbers the title, keep the original one for the next runs.
+	title := mods.Config.cacheWriteToTitle
+	if config.WatchSave {
+		if err := saveNumbered(mods, 1); err != nil {
+			return err
+		}
+	}
+
+	stdin := mods.stdin
+	for i := 2; ; i++ {
+		next, ok, err := nextWatchInput(config.WatchInterval, stdin)
+		if err != nil {
+			return modsError{err, "Unable to read stdin."}
+		}
+		if !ok {
+			return nil
+		}
+		stdin = next
+
+		cfg := *mods.Config
+		cfg.cacheWriteToTitle = title
+		sub := newMods(mods.renderer, &cfg, mods.db, mods.cache)
+		switch msg := sub.gatherInput(stdin).(type) {
+		case completionInput:
+			sub.Input = removeWhitespace(msg.content)
+			sub.Error = sub.runHeadless(msg.content)
+		case modsError:
+			sub.Error = &msg
+		}
+
+		out := watchText(sub, tty)
+		if tty {
+			fmt.Fprint(w, clearLines(strings.Count(printed, "\n")))
+		} else {
+			fmt.Fprint(w, defaultAppendSeparator)
+		}
+		fmt.Fprint(w, out)
+		printed = out
+
+		if sub.Error != nil {
+			continue
+		}
+		if err := writeResponse(sub); err != nil {
+			return err
+		}
+		if config.WatchSave {
+			if err := saveNumbered(sub, i); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// nextWatchInput waits for the next input: after interval, with the same
+// input as before, or else until STDIN, e.g. a named pipe, is written to and
+// closed again. It returns false once STDIN has nothing more to give.
+func nextWatchInput(interval time.Duration, stdin string) (string, bool, error) {
+	if interval > 0 {
+		time.Sleep(interval)
+		return stdin, true, nil
+	}
+	f, err := os.Open(watchStdinPath)
+	if err != nil {
+		return "", false, fmt.Errorf("nextWatchInput: %w", err)
+	}
+	defer f.Close() //nolint:errcheck
+	// a file reads the same each time, and would run the prompt forever.
+	if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+		return "", false, nil
+	}
+	bts, err := io.ReadAll(f)
+	if err != nil {
+		return "", false, fmt.Errorf("nextWatchInput: %w", err)
+	}
+	return string(bts), len(bts) > 0, nil
+}
+
+// watchText is the response of a --watch run, rendered as markdown on a TTY.
+func watchText(r *Mods, tty bool) string {
+	content := parallelResult(r) + "\n"
+	if !tty || r.Error != nil {
+		return content
+	}
+	gr, err := glamour.NewTermRenderer(
+		glamour.WithEnvironmentConfig(),
+		glamour.WithWordWrap(r.Config.WordWrap),
+	)
+	if err != nil {
+		return content
+	}
+	out, err := gr.Render(content)
+	if err != nil {
+		return content
+	}
+	return out
+}
+
+// clearLines moves the cursor up n lines and clears everything below it.
+func clearLines(n int) string {
+	if n <= 0 {
+		return "\r\x1b[J"
+	}
+	return fmt.Sprintf("\x1b[%dF\x1b[J", n)
+}
+
+// validateWatch makes sure --watch has something to wait for.
+func validateWatch() error {
+	if !config.Watch || config.WatchInterval > 0 || !isInputTTY() {
+		return nil
+	}
+	return modsError{
+		err: newUserErrorf(
+			"Pipe the input into mods, or set %s.",
+			stdoutStyles().InlineCode.Render("--watch-interval"),
+		),
+		reason: fmt.Sprintf(
+			"%s needs new input to wait for.",
+			stdoutStyles().InlineCode.Render("--watch"),
+		),
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextWatchInput(t *testing.T) {
+	t.Run("interval", func(t *testing.T) {
+		input, ok, err := nextWatchInput(time.Millisecond, "same input")
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, "same input", input)
+	})
+
+	t.Run("regular file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "input")
+		require.NoError(t, os.WriteFile(path, []byte("data"), 0o644))
+		oldPath := watchStdinPath
+		t.Cleanup(func() { watchStdinPath = oldPath })
+		watchStdinPath = path
+
+		_, ok, err := nextWatchInput(0, "data")
+		require.NoError(t, err)
+		require.False(t, ok)
+	})
+}
+
+func TestClearLines(t *testing.T) {
+	require.Equal(t, "\r\x1b[J", clearLines(0))
+	require.Equal(t, "\x1b[3F\x1b[J", clearLines(3))
+}
+
+func TestWatch(t *testing.T) {
+	if _, err := exec.LookPath("mkfifo"); err != nil {
+		t.Skip("mkfifo not available")
+	}
+	fifo := filepath.Join(t.TempDir(), "stdin")
+	require.NoError(t, exec.Command("mkfifo", fifo).Run())
+	oldPath := watchStdinPath
+	t.Cleanup(func() { watchStdinPath = oldPath })
+	watchStdinPath = fifo
+
+	requested := make(chan struct{}, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested <- struct{}{}
+		var req openai.ChatCompletionRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		w.Header().Set("Content-Type", "text/event-stream")
+		prompt := strings.TrimSpace(req.Messages[len(req.Messages)-1].Content)
+		_, _ = fmt.Fprintf(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":%q}}]}\n\n", "got "+prompt)
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(ts.Close)
+
+	output := filepath.Join(t.TempDir(), "output.md")
+	oldConfig, oldDB, oldCache := config, db, cache
+	t.Cleanup(func() { config, db, cache = oldConfig, oldDB, oldCache })
+	config = Config{
+		Quiet:      true,
+		Raw:        true,
+		MaxRetries: 1,
+		Watch:      true,
+		WatchSave:  true,
+		OutputFile: output,
+		Model:      "gpt-4o",
+		Models: map[string]Model{
+			"gpt-4o": {Name: "gpt-4o", API: "openai", MaxChars: 1000},
+		},
+		APIs: APIs{
+			{Name: "openai", BaseURL: ts.URL, APIKey: "fake"},
+		},
+	}
+	db = testDB(t)
+	cache = newCache(t.TempDir())
+
+	mods := newMods(lipgloss.DefaultRenderer(), &config, db, cache)
+	mods.stdin = "first"
+	mods.Output = "got first"
+	mods.messages = []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleUser, Content: "first"},
+		{Role: openai.ChatMessageRoleAssistant, Content: "got first"},
+	}
+
+	// writes input to the named pipe, which watch reads as its STDIN.
+	write := func(input string) {
+		f, err := os.OpenFile(fifo, os.O_WRONLY, 0)
+		if err != nil {
+			return
+		}
+		_, _ = f.WriteString(input)
+		_ = f.Close()
+	}
+	go func() {
+		write("second")
+		// closing it without writing anything ends the watch.
+		<-requested
+		write("")
+	}()
+
+	var b strings.Builder
+	require.NoError(t, watch(&b, mods, "got first\n"))
+	require.Equal(t, defaultAppendSeparator+"got second\n", b.String())
+
+	convos, err := db.List()
+	require.NoError(t, err)
+	require.Len(t, convos, 2)
+	titles := []string{convos[0].Title, convos[1].Title}
+	require.ElementsMatch(t, []string{"first (1)", "second (2)"}, titles)
+
+	// the first response is written before watching.
+	bts, err := os.ReadFile(output)
+	require.NoError(t, err)
+	require.Equal(t, "got second", string(bts))
+}
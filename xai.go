@@ -0,0 +1,11 @@
+package main
+
+import "strings"
+
+const xaiBaseURL = "https://api.x.ai/v1"
+
+// xaiSupportsImages reports whether the given Grok model accepts images.
+// Only the vision models do.
+func xaiSupportsImages(model string) bool {
+	return strings.Contains(model, "vision")
+}
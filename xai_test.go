@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXAIImages(t *testing.T) {
+	var body struct {
+		Model    string `json:"model"`
+		Messages []struct {
+			Content []struct {
+				Type     string `json:"type"`
+				ImageURL struct {
+					URL string `json:"url"`
+				} `json:"image_url"`
+			} `json:"content"`
+		} `json:"messages"`
+	}
+	var auth string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"a pixel\"}}]}\n\n")
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(ts.Close)
+
+	newXAIMods := func(model string) *Mods {
+		return newMods(lipgloss.DefaultRenderer(), &Config{
+			Model:   model,
+			Quiet:   true,
+			NoLimit: true,
+			Images:  []string{filepath.Join("testdata", "image.png")},
+			Models: map[string]Model{
+				"grok-beta":        {Name: "grok-beta", API: "xai"},
+				"grok-vision-beta": {Name: "grok-vision-beta", API: "xai"},
+			},
+			APIs: APIs{
+				{Name: "xai", BaseURL: ts.URL, APIKey: "xai-key"},
+			},
+		}, nil, nil)
+	}
+
+	t.Run("vision model", func(t *testing.T) {
+		mods := newXAIMods("grok-vision-beta")
+		require.Nil(t, mods.runHeadless("what is this?"))
+		require.Equal(t, "a pixel", mods.Output)
+		require.Equal(t, "Bearer xai-key", auth)
+		require.Equal(t, "grok-vision-beta", body.Model)
+		require.Len(t, body.Messages, 1)
+		require.Len(t, body.Messages[0].Content, 2)
+		require.Equal(t, "image_url", body.Messages[0].Content[1].Type)
+		require.Contains(t, body.Messages[0].Content[1].ImageURL.URL, "data:image/png;base64,")
+	})
+
+	t.Run("text model", func(t *testing.T) {
+		mods := newXAIMods("grok-beta")
+		err := mods.runHeadless("what is this?")
+		require.NotNil(t, err)
+		require.Contains(t, err.reason, "does not support")
+	})
+}